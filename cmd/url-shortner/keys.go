@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/config"
+	"url-shortener/internal/http-server/router"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+)
+
+const keysUsage = `usage: keys issue -name <name> -scopes <scope,...> [-ttl <duration>]
+       keys list
+       keys revoke <id>`
+
+// keysCreatedBy автор ключей, выпущенных из командной строки
+const keysCreatedBy = "cli"
+
+var (
+	errKeysUsage = errors.New(keysUsage)
+)
+
+// runKeys выполняет подкоманду keys и возвращает код выхода.
+func runKeys(ctx context.Context, log *slog.Logger, cfg *config.Config, args []string) int {
+	if cfg.Storage.Driver == driverMemory {
+		log.Error("api keys are not kept by the memory storage driver between runs")
+		return 1
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+
+	storage, closeStorage, err := setUpStorage(ctx, log, cfg)
+	if err != nil {
+		log.Error("failed to init storage", xslog.Err(err))
+		return 1
+	}
+	defer closeStorage()
+
+	err = keys(ctx, log, storage, args)
+	if errors.Is(err, errKeysUsage) {
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+	if err != nil {
+		log.Error("keys command failed", xslog.Err(err))
+		return 1
+	}
+	return 0
+}
+
+func keys(ctx context.Context, log *slog.Logger, strg router.Storage, args []string) error {
+	switch args[0] {
+	case "issue":
+		flags := flag.NewFlagSet("keys issue", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		name := flags.String("name", "", "key name")
+		scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(apikey.Scopes, ","))
+		ttl := flags.Duration("ttl", 0, "key lifetime, 0 - never expires")
+		if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 0 || *name == "" || *scopes == "" {
+			return errKeysUsage
+		}
+
+		key, saved, err := apikey.New(log, strg, time.Minute).Issue(ctx, apikey.IssueOptions{
+			Name:      *name,
+			Scopes:    strings.Split(*scopes, ","),
+			TTL:       *ttl,
+			CreatedBy: keysCreatedBy,
+		})
+		if err != nil {
+			return err
+		}
+		// Ключ печатается один раз, в хранилище остается только хеш
+		fmt.Fprintf(os.Stderr, "api key %d %q issued, save it now:\n", saved.Id, saved.Name)
+		fmt.Fprintln(os.Stdout, key)
+	case "list":
+		if len(args) != 1 {
+			return errKeysUsage
+		}
+		saved, err := strg.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Id\tName\tPrefix\tScopes\tCreated By\tExpires At\tLast Used At\tStatus")
+		for _, key := range saved {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.Id, key.Name, key.Prefix,
+				strings.Join(key.Scopes, ","), key.CreatedBy, formatTime(key.ExpiresAt, "Never"),
+				formatTime(key.LastUsedAt, "Never"), keyStatus(key, now))
+		}
+		return w.Flush()
+	case "revoke":
+		if len(args) != 2 {
+			return errKeysUsage
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id <= 0 {
+			return errKeysUsage
+		}
+		revoked, err := strg.RevokeAPIKey(ctx, id, time.Now())
+		if err != nil {
+			return err
+		}
+		log.Info("api key revoked", slog.Int("id", revoked.Id), slog.String("name", revoked.Name))
+	default:
+		return errKeysUsage
+	}
+	return nil
+}
+
+func formatTime(t *time.Time, empty string) string {
+	if t == nil {
+		return empty
+	}
+	return t.Format(time.RFC3339)
+}
+
+func keyStatus(key storage.APIKey, now time.Time) string {
+	switch {
+	case key.RevokedAt != nil:
+		return "Revoked"
+	case storage.IsExpired(key.ExpiresAt, now):
+		return "Expired"
+	default:
+		return "Active"
+	}
+}
@@ -8,17 +8,24 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"url-shortener/internal/clicks"
 	"url-shortener/internal/config"
-	"url-shortener/internal/http-server/handlers/redirect"
-	"url-shortener/internal/http-server/handlers/url/delete"
-	"url-shortener/internal/http-server/handlers/url/save"
-	mwLogger "url-shortener/internal/http-server/middleware/logger"
+	"url-shortener/internal/http-server/router"
+	"url-shortener/internal/lib/aliasrule"
 	"url-shortener/internal/lib/logger/handlers/slogpretty"
 	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/purge"
+	"url-shortener/internal/storage/memory"
 	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
+	"url-shortener/internal/users"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
 )
 
@@ -27,6 +34,12 @@ const (
 	endProd  = "prod"
 )
 
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+	driverMemory   = "memory"
+)
+
 func main() {
 	ctx := context.Background()
 	var env string
@@ -51,38 +64,125 @@ func main() {
 	log.Info("starting url-shortener", slog.String("env", config.Env))
 	log.Debug("debug messages are enabled")
 
-	storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	defer cancel(*storage)
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrate(ctx, log, config, flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "keys" {
+		os.Exit(runKeys(ctx, log, config, flag.Args()[1:]))
+	}
+
+	os.Exit(run(ctx, log, config))
+}
 
+// run запускает сервер и фоновые задачи и блокируется до SIGINT/SIGTERM
+// или ошибки сервера. Остановка идет в обратном порядке: сначала
+// сервер дожидается текущих запросов, затем останавливаются фоновые
+// задачи, сохраняются накопленные переходы и закрывается хранилище.
+// Возвращает код выхода процесса.
+func run(ctx context.Context, log *slog.Logger, config *config.Config) (exitCode int) {
+	storage, closeStorage, err := setUpStorage(ctx, log, config)
 	if err != nil {
 		log.Error("failed to init storage", xslog.Err(err))
-		os.Exit(1)
-	}
-	log.Info("Storage init success. Create table and index")
-
-	router := chi.NewRouter()
-	fmt.Println(1 + 1)
-	// Добавляет request id к каждому запросу
-	router.Use(middleware.RequestID)
-	// Добавляет ip пользователя
-	router.Use(middleware.RealIP)
-	// Логирует входящие запросы
-	router.Use(mwLogger.New(log))
-	// При панике, чтобы не падало все приложение из-за одного запроса
-	router.Use(middleware.Recoverer)
-	// Фишка chi. Позволяет писать такие роуты: /articles/{id} и потом
-	// получать этот id в хендлере
-	router.Use(middleware.URLFormat)
-
-	router.Get("/{alias}", redirect.New(ctx, log, storage))
-
-	router.Route("/url", func(r chi.Router) {
-		r.Use(middleware.BasicAuth("url-shortener", map[string]string{
-			config.HTTPServer.UserName: config.HTTPServer.Password,
-		}))
-		r.Post("/", save.New(ctx, log, storage))
-		r.Delete("/{alias}", delete.New(ctx, log, storage))
+		return 1
+	}
+	defer func() {
+		closeStorage()
+		log.Info("storage closed")
+	}()
+	log.Info("Storage init success", slog.String("driver", config.Storage.Driver))
+
+	// Фоновые задачи живут в своем контексте, чтобы остановить
+	// их только после того, как сервер обработает текущие запросы
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	var background sync.WaitGroup
+	defer func() {
+		stopBackground()
+		background.Wait()
+		log.Info("background jobs stopped")
+	}()
+
+	if config.Purge.Interval > 0 {
+		purger, err := purge.New(log, storage, config.Purge.Interval, config.Purge.Mode)
+		if err != nil {
+			log.Error("failed to init purge", xslog.Err(err))
+			return 1
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			purger.Run(bgCtx)
+		}()
+	}
+
+	clickPipeline := clicks.New(log, storage, config.Clicks)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Clicks.SaveTimeout)
+		defer cancel()
+		if err := clickPipeline.Close(ctx); err != nil {
+			log.Error("failed to flush clicks", xslog.Err(err))
+			exitCode = 1
+		}
+	}()
+	if config.Clicks.StatsInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			logStats(bgCtx, log, config.Clicks.StatsInterval, "click pipeline stats", "clicks", func() slog.LogValuer {
+				return clickPipeline.Stats()
+			})
+		}()
+	}
+
+	aliases, err := random.NewAliases(random.AliasesOptions{
+		Strategy: config.Alias.Strategy,
+		Retries:  config.Alias.Retries,
+		Random: random.Options{
+			Alphabet:  config.Alias.Alphabet,
+			Length:    config.Alias.Length,
+			MaxLength: config.Alias.MaxLength,
+			GrowAfter: config.Alias.GrowAfter,
+		},
+		HashidsSalt:      config.Alias.HashidsSalt,
+		HashidsMinLength: config.Alias.HashidsMinLength,
+	})
+	if err != nil {
+		log.Error("failed to init alias generators", xslog.Err(err))
+		return 1
+	}
+	rules, err := aliasrule.New(aliasrule.Options{
+		Charset:   config.Alias.Rules.Charset,
+		MinLength: config.Alias.Rules.MinLength,
+		MaxLength: config.Alias.Rules.MaxLength,
+		Pattern:   config.Alias.Rules.Pattern,
+		Reserved:  config.Alias.Rules.Reserved,
+		Fold:      config.Alias.Rules.Fold,
 	})
+	if err != nil {
+		log.Error("failed to init alias rules", xslog.Err(err))
+		return 1
+	}
+
+	apiUsers, err := setUpUsers(log, config)
+	if err != nil {
+		log.Error("failed to init users", xslog.Err(err))
+		return 1
+	}
+	if config.HTTPServer.UsersFile != "" {
+		if config.HTTPServer.UsersReloadInterval > 0 {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				apiUsers.Run(bgCtx, config.HTTPServer.UsersReloadInterval)
+			}()
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			reloadOnSignal(bgCtx, log, apiUsers)
+		}()
+	}
+
+	router := router.New(log, config, storage, clickPipeline, aliases, rules, apiUsers)
 
 	log.Info("starting server", "address", config.Address)
 	server := &http.Server{
@@ -93,12 +193,176 @@ func main() {
 		IdleTimeout:  config.HTTPServer.IddleTimeout,
 	}
 
-	if err := server.ListenAndServe(); err != nil {
+	signalCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Error("failed to start server", xslog.Err(err))
+		return 1
+	case <-signalCtx.Done():
+		// Повторный сигнал завершит процесс сразу
+		stopSignals()
+		log.Info("shutting down server", slog.String("drain_timeout", config.HTTPServer.ShutdownTimeout.String()))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.HTTPServer.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to drain in-flight requests", xslog.Err(err))
+		exitCode = 1
+	} else {
+		log.Info("server stopped")
+	}
+
+	return exitCode
+}
+
+// appStorage хранилище, нужное хендлерам и фоновым задачам.
+type appStorage interface {
+	router.Storage
+	clicks.ClickSaver
+	purge.ExpiredURLRemover
+}
+
+// setUpStorage создает хранилище согласно config.Storage.Driver.
+// Вторым значением возвращается функция закрытия хранилища, которая
+// сначала останавливает фоновые задачи самого хранилища.
+func setUpStorage(ctx context.Context, log *slog.Logger, cfg *config.Config) (appStorage, func(), error) {
+	switch cfg.Storage.Driver {
+	case driverPostgres:
+		storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgresOptions(cfg.Storage.Postgres))
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfg.Storage.Postgres.MigrateOnStartup {
+			applied, err := storage.MigrateUp(ctx)
+			if err != nil {
+				cancel(*storage)
+				return nil, nil, err
+			}
+			log.Info("migrations applied", slog.Int("count", applied))
+			if err := canonicalizeURLs(ctx, log, storage, cfg); err != nil {
+				cancel(*storage)
+				return nil, nil, err
+			}
+		}
+		statsCtx, stopStats := context.WithCancel(ctx)
+		var stats sync.WaitGroup
+		if cfg.Storage.Postgres.StatsInterval > 0 {
+			stats.Add(1)
+			go func() {
+				defer stats.Done()
+				logStats(statsCtx, log, cfg.Storage.Postgres.StatsInterval, "postgres pool stats", "pool", func() slog.LogValuer {
+					return storage.Stats()
+				})
+			}()
+		}
+		return storage, func() {
+			stopStats()
+			stats.Wait()
+			cancel(*storage)
+		}, nil
+	case driverSQLite:
+		storage, err := sqlite.New(cfg.Storage.SQLite.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := canonicalizeURLs(ctx, log, storage, cfg); err != nil {
+			_ = storage.Close()
+			return nil, nil, err
+		}
+		return storage, func() {
+			if err := storage.Close(); err != nil {
+				log.Error("failed to close storage", xslog.Err(err))
+			}
+		}, nil
+	case driverMemory:
+		return memory.New(), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("undefined storage driver %s", cfg.Storage.Driver)
+	}
+}
+
+// postgresOptions переносит настройки пула из конфига.
+func postgresOptions(cfg config.Postgres) postgres.Options {
+	return postgres.Options{
+		MaxConns:          cfg.MaxConns,
+		MinConns:          cfg.MinConns,
+		MaxConnLifetime:   cfg.MaxConnLifetime,
+		MaxConnIdleTime:   cfg.MaxConnIdleTime,
+		HealthCheckPeriod: cfg.HealthCheckPeriod,
+		AcquireTimeout:    cfg.AcquireTimeout,
+	}
+}
+
+// canonicalizeBatchSize сколько ссылок обрабатывать в одной транзакции
+// при построении канонической формы URL
+const canonicalizeBatchSize = 1000
+
+type urlCanonicalizer interface {
+	CanonicalizeURLs(ctx context.Context, canonical func(rawURL string) string, batchSize int) (int, error)
+}
+
+// canonicalizeURLs строит каноническую форму URL для ссылок, у которых
+// ее еще нет, тем же нормализатором, что и хендлеры. Выполняется после
+// миграций: SQL-миграции не умеют нормализовать URL.
+func canonicalizeURLs(ctx context.Context, log *slog.Logger, storage urlCanonicalizer, cfg *config.Config) error {
+	processed, err := storage.CanonicalizeURLs(ctx, urlnorm.New(cfg.Save.StripParams).Canonical, canonicalizeBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize urls: %w", err)
+	}
+	if processed > 0 {
+		log.Info("canonical urls built", slog.Int("count", processed))
+	}
+	return nil
+}
+
+// setUpUsers загружает пользователей API из config.HTTPServer.UsersFile,
+// а если файл не задан - берет единственного пользователя из конфига.
+func setUpUsers(log *slog.Logger, cfg *config.Config) (*users.Store, error) {
+	if cfg.HTTPServer.UsersFile != "" {
+		return users.New(log, cfg.HTTPServer.UsersFile)
 	}
+	return users.NewStatic(log, map[string]string{cfg.HTTPServer.UserName: cfg.HTTPServer.Password})
+}
 
-	log.Error("server stopped")
+// reloadOnSignal перечитывает пользователей по SIGHUP до отмены ctx.
+func reloadOnSignal(ctx context.Context, log *slog.Logger, apiUsers *users.Store) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			if err := apiUsers.Reload(); err != nil {
+				log.Error("failed to reload users", xslog.Err(err))
+			}
+		}
+	}
+}
+
+// logStats периодически пишет в лог снимок статистики, который возвращает stats.
+func logStats(ctx context.Context, log *slog.Logger, interval time.Duration, msg string, key string, stats func() slog.LogValuer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Info(msg, slog.Any(key, stats()))
+		}
+	}
 }
 
 func setUpLogger(env string) *slog.Logger {
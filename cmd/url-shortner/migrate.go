@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage/postgres"
+)
+
+const migrateUsage = "usage: migrate up|down|status|to <version>"
+
+// canonicalPendingVersion миграция, после которой можно строить
+// каноническую форму URL старых ссылок
+const canonicalPendingVersion = 20261018170000
+
+var (
+	errMigrateUsage = errors.New(migrateUsage)
+)
+
+// runMigrate выполняет подкоманду migrate и возвращает код выхода.
+func runMigrate(ctx context.Context, log *slog.Logger, cfg *config.Config, args []string) int {
+	if cfg.Storage.Driver != driverPostgres {
+		log.Info("migrations are applied automatically for this storage driver", slog.String("driver", cfg.Storage.Driver))
+		return 0
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgresOptions(cfg.Storage.Postgres))
+	if err != nil {
+		log.Error("failed to init storage", xslog.Err(err))
+		return 1
+	}
+	defer cancel(*storage)
+
+	err = migrate(ctx, log, cfg, storage, args)
+	if errors.Is(err, errMigrateUsage) {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		log.Error("migration failed", xslog.Err(err))
+		return 1
+	}
+	return 0
+}
+
+func migrate(ctx context.Context, log *slog.Logger, cfg *config.Config, storage *postgres.Storage, args []string) error {
+	switch args[0] {
+	case "up":
+		applied, err := storage.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations applied", slog.Int("count", applied))
+		return canonicalizeURLs(ctx, log, storage, cfg)
+	case "down":
+		if err := storage.MigrateDown(ctx); err != nil {
+			return err
+		}
+		log.Info("last migration rolled back")
+	case "to":
+		if len(args) != 2 {
+			return errMigrateUsage
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errMigrateUsage
+		}
+		changed, err := storage.MigrateTo(ctx, version)
+		if err != nil {
+			return err
+		}
+		log.Info("schema migrated", slog.Int64("version", version), slog.Int("changed", changed))
+		if version >= canonicalPendingVersion {
+			return canonicalizeURLs(ctx, log, storage, cfg)
+		}
+	case "status":
+		statuses, err := storage.MigrationsStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Applied At\tMigration")
+		for _, status := range statuses {
+			appliedAt := "Pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%d_%s\n", appliedAt, status.Version, status.Name)
+		}
+		return w.Flush()
+	default:
+		return errMigrateUsage
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package db содержит миграции схемы PostgreSQL в формате goose,
+// встроенные в бинарник.
+package db
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+const (
+	annotationUp             = "-- +goose Up"
+	annotationDown           = "-- +goose Down"
+	annotationStatementBegin = "-- +goose StatementBegin"
+	annotationStatementEnd   = "-- +goose StatementEnd"
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations возвращает встроенные миграции, отсортированные по версии.
+func Migrations() ([]Migration, error) {
+	return Load(migrationsFS, "migrations")
+}
+
+// Load читает миграции формата goose из директории dir.
+// Имя файла должно иметь вид <version>_<name>.sql.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	const operationPlace = "db.Load"
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		migration, err := parseName(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		if other, ok := seen[migration.Version]; ok {
+			return nil, fmt.Errorf("%s: %w: version %d in %s and %s", operationPlace, ErrInvalidMigration, migration.Version, other, entry.Name())
+		}
+		seen[migration.Version] = entry.Name()
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		migration.Up, migration.Down, err = parseContent(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", operationPlace, entry.Name(), err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func parseName(fileName string) (Migration, error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+	rawVersion, name, found := strings.Cut(base, "_")
+	if !found {
+		return Migration{}, fmt.Errorf("%w: bad file name %s", ErrInvalidMigration, fileName)
+	}
+	version, err := strconv.ParseInt(rawVersion, 10, 64)
+	if err != nil || version <= 0 {
+		return Migration{}, fmt.Errorf("%w: bad version in %s", ErrInvalidMigration, fileName)
+	}
+	return Migration{Version: version, Name: name}, nil
+}
+
+// parseContent делит файл на up и down части. Маркеры
+// StatementBegin/StatementEnd отбрасываются: каждая часть
+// выполняется целиком одним запросом.
+func parseContent(content string) (string, string, error) {
+	var up, down strings.Builder
+	var current *strings.Builder
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, annotationUp):
+			current = &up
+			continue
+		case strings.HasPrefix(trimmed, annotationDown):
+			current = &down
+			continue
+		case strings.HasPrefix(trimmed, annotationStatementBegin),
+			strings.HasPrefix(trimmed, annotationStatementEnd):
+			continue
+		}
+		if current == nil {
+			if trimmed != "" {
+				return "", "", fmt.Errorf("%w: statement before %q", ErrInvalidMigration, annotationUp)
+			}
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+
+	if strings.TrimSpace(up.String()) == "" {
+		return "", "", fmt.Errorf("%w: empty up section", ErrInvalidMigration)
+	}
+
+	return strings.TrimSpace(up.String()), strings.TrimSpace(down.String()), nil
+}
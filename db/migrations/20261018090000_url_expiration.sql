@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table url add column if not exists expires_at timestamptz;
+create index if not exists url_expires_at_idx on url(expires_at) where expires_at is not null;
+create table if not exists url_archive (
+    url_id bigint primary key,
+    alias text not null,
+    url text not null,
+    expires_at timestamptz,
+    archived_at timestamptz not null default now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists url_archive;
+drop index if exists url_expires_at_idx;
+alter table url drop column if exists expires_at;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists click (
+    click_id bigint generated always as identity primary key,
+    url_id bigint not null references url(url_id) on delete cascade,
+    clicked_at timestamptz not null,
+    referrer text not null default '',
+    user_agent text not null default '',
+    request_id text not null default '',
+    ip text not null default ''
+);
+create index if not exists click_url_id_clicked_at_idx on click(url_id, clicked_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists click;
+-- +goose StatementEnd
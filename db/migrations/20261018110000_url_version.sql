@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table url add column if not exists version bigint not null default 1;
+alter table url add column if not exists created_at timestamptz not null default now();
+alter table url add column if not exists updated_at timestamptz not null default now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table url drop column if exists updated_at;
+alter table url drop column if exists created_at;
+alter table url drop column if exists version;
+-- +goose StatementEnd
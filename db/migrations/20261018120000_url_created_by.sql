@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table url add column if not exists created_by text not null default '';
+alter table url add column if not exists alias_generated boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table url drop column if exists alias_generated;
+alter table url drop column if exists created_by;
+-- +goose StatementEnd
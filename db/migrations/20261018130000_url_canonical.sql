@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table url add column if not exists canonical_url text;
+create index if not exists url_canonical_url_idx on url(canonical_url) where alias_generated;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index if exists url_canonical_url_idx;
+alter table url drop column if exists canonical_url;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+update url set canonical_url = url where canonical_url is null;
+alter table url alter column canonical_url set not null;
+drop index if exists url_canonical_url_idx;
+create index if not exists url_canonical_url_idx on url(canonical_url);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index if exists url_canonical_url_idx;
+create index if not exists url_canonical_url_idx on url(canonical_url) where alias_generated;
+alter table url alter column canonical_url drop not null;
+-- +goose StatementEnd
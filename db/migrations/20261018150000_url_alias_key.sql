@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table url add column if not exists alias_key text;
+update url set alias_key = alias where alias_key is null;
+alter table url alter column alias_key set not null;
+create unique index if not exists url_alias_key_idx on url(alias_key);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index if exists url_alias_key_idx;
+alter table url drop column if exists alias_key;
+-- +goose StatementEnd
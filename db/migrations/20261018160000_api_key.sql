@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+create table if not exists api_key (
+    api_key_id bigint generated always as identity primary key,
+    name text not null,
+    prefix text not null unique,
+    key_hash bytea not null,
+    scopes text[] not null default '{}',
+    created_at timestamptz not null default now(),
+    created_by text not null default '',
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table if exists api_key;
+-- +goose StatementEnd
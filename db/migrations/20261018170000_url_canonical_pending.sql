@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- canonical_url старых ссылок заполнен исходным URL, каноническую
+-- форму для них строит приложение, см. CanonicalizeURLs
+alter table url add column if not exists canonical_pending boolean not null default false;
+update url set canonical_pending = true where canonical_url = url;
+create index if not exists url_canonical_pending_idx on url(url_id) where canonical_pending;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index if exists url_canonical_pending_idx;
+alter table url drop column if exists canonical_pending;
+-- +goose StatementEnd
@@ -0,0 +1,85 @@
+//go:build smoke
+
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"url-shortener/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEmbeddedMigrations проверяет, что все встроенные
+// миграции разбираются и отсортированы по версии.
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := db.Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.NotEmpty(t, migration.Up, migration.Name)
+		assert.NotEmpty(t, migration.Down, migration.Name)
+		assert.NotContains(t, migration.Up, "+goose")
+		if i > 0 {
+			assert.Greater(t, migration.Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		caseName string
+		files    fstest.MapFS
+		err      error
+		up       string
+		down     string
+	}{
+		{
+			caseName: "Up and down",
+			files: fstest.MapFS{
+				"m/1_init.sql": {Data: []byte("-- +goose Up\ncreate table t();\n-- +goose Down\ndrop table t;\n")},
+			},
+			up:   "create table t();",
+			down: "drop table t;",
+		},
+		{
+			caseName: "Bad file name",
+			files: fstest.MapFS{
+				"m/init.sql": {Data: []byte("-- +goose Up\ncreate table t();\n")},
+			},
+			err: db.ErrInvalidMigration,
+		},
+		{
+			caseName: "Duplicate version",
+			files: fstest.MapFS{
+				"m/1_a.sql": {Data: []byte("-- +goose Up\nselect 1;\n")},
+				"m/1_b.sql": {Data: []byte("-- +goose Up\nselect 1;\n")},
+			},
+			err: db.ErrInvalidMigration,
+		},
+		{
+			caseName: "No up section",
+			files: fstest.MapFS{
+				"m/1_init.sql": {Data: []byte("-- +goose Down\ndrop table t;\n")},
+			},
+			err: db.ErrInvalidMigration,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			migrations, err := db.Load(tc.files, "m")
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "unexpected error: %v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, migrations, 1)
+			assert.Equal(t, tc.up, migrations[0].Up)
+			assert.Equal(t, tc.down, migrations[0].Down)
+		})
+	}
+}
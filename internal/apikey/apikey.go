@@ -0,0 +1,199 @@
+// Package apikey выпускает и проверяет ключи API для сервисов и CI.
+// В хранилище лежат только SHA-256 хеши ключей: ключи длинные и
+// случайные, поэтому медленный хеш вроде bcrypt им не нужен.
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/storage"
+)
+
+// Права ключей API.
+const (
+	ScopeLinksCreate = "links:create"
+	ScopeLinksDelete = "links:delete"
+	ScopeLinksRead   = "links:read"
+	ScopeStatsRead   = "stats:read"
+)
+
+// Scopes все права в порядке, в котором они хранятся у ключа.
+var Scopes = []string{ScopeLinksCreate, ScopeLinksDelete, ScopeLinksRead, ScopeStatsRead}
+
+// Ключ имеет вид usk_<prefix>_<secret>. По prefix ключ ищется
+// в хранилище, prefix и secret состоят из base62.
+const (
+	keyStart     = "usk_"
+	prefixLength = 10
+	secretLength = 32
+	alphabet     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid api key")
+	ErrKeyRevoked = errors.New("api key revoked")
+	ErrKeyExpired = errors.New("api key expired")
+	// ErrInvalidOptions ключ нельзя выпустить с такими параметрами
+	ErrInvalidOptions = errors.New("invalid api key options")
+)
+
+type KeyStorage interface {
+	SaveAPIKey(ctx context.Context, key storage.APIKeyToSave) (storage.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (storage.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error
+}
+
+// IssueOptions параметры нового ключа.
+type IssueOptions struct {
+	Name   string
+	Scopes []string
+	// TTL срок жизни ключа, 0 - бессрочный
+	TTL       time.Duration
+	CreatedBy string
+}
+
+// Manager выпускает и проверяет ключи API.
+type Manager struct {
+	log     *slog.Logger
+	storage KeyStorage
+	// touchInterval как часто обновлять время последнего использования
+	touchInterval time.Duration
+}
+
+// New возвращает Manager. Время последнего использования ключа
+// сохраняется не чаще раза в touchInterval, чтобы не писать в
+// хранилище на каждый запрос.
+func New(log *slog.Logger, keyStorage KeyStorage, touchInterval time.Duration) *Manager {
+	return &Manager{
+		log:           log.With(slog.String("component", "apikey")),
+		storage:       keyStorage,
+		touchInterval: touchInterval,
+	}
+}
+
+// Issue выпускает ключ. Сам ключ возвращается только здесь,
+// в хранилище остается его хеш.
+func (m *Manager) Issue(ctx context.Context, opts IssueOptions) (string, storage.APIKey, error) {
+	const operationPlace = "apikey.Issue"
+
+	scopes, err := normalizeScopes(opts.Scopes)
+	if err != nil {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if strings.TrimSpace(opts.Name) == "" {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w: empty name", operationPlace, ErrInvalidOptions)
+	}
+	if opts.TTL < 0 {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w: negative ttl", operationPlace, ErrInvalidOptions)
+	}
+
+	prefix := random.NewString(alphabet, prefixLength)
+	key := keyStart + prefix + "_" + random.NewString(alphabet, secretLength)
+	toSave := storage.APIKeyToSave{
+		Name:      opts.Name,
+		Prefix:    prefix,
+		Hash:      hash(key),
+		Scopes:    scopes,
+		CreatedBy: opts.CreatedBy,
+	}
+	if opts.TTL > 0 {
+		expiresAt := time.Now().Add(opts.TTL)
+		toSave.ExpiresAt = &expiresAt
+	}
+
+	saved, err := m.storage.SaveAPIKey(ctx, toSave)
+	if err != nil {
+		return "", storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	m.log.Info("api key issued", slog.Int("id", saved.Id), slog.String("name", saved.Name),
+		slog.Any("scopes", saved.Scopes), slog.String("created_by", saved.CreatedBy))
+	return key, saved, nil
+}
+
+// Authenticate возвращает действующий ключ. Для неизвестных и
+// испорченных ключей возвращается ErrInvalidKey, для отозванных -
+// ErrKeyRevoked, для истекших - ErrKeyExpired.
+func (m *Manager) Authenticate(ctx context.Context, key string) (storage.APIKey, error) {
+	const operationPlace = "apikey.Authenticate"
+
+	prefix, ok := parse(key)
+	if !ok {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, ErrInvalidKey)
+	}
+	saved, err := m.storage.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, ErrInvalidKey)
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if subtle.ConstantTimeCompare(hash(key), saved.Hash) != 1 {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, ErrInvalidKey)
+	}
+
+	now := time.Now()
+	if saved.RevokedAt != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, ErrKeyRevoked)
+	}
+	if storage.IsExpired(saved.ExpiresAt, now) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, ErrKeyExpired)
+	}
+
+	if saved.LastUsedAt == nil || now.Sub(*saved.LastUsedAt) >= m.touchInterval {
+		// Без времени использования ключ все равно действителен
+		if err := m.storage.TouchAPIKey(ctx, saved.Id, now); err != nil {
+			m.log.Error("failed to save api key usage", slog.Int("id", saved.Id), xslog.Err(err))
+		} else {
+			saved.LastUsedAt = &now
+		}
+	}
+	return saved, nil
+}
+
+// normalizeScopes проверяет права и упорядочивает их как в Scopes.
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: no scopes", ErrInvalidOptions)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidOptions, scope)
+		}
+	}
+
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range Scopes {
+		if slices.Contains(scopes, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
+
+// parse возвращает prefix ключа, если ключ имеет нужный вид.
+func parse(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, keyStart)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != prefixLength || len(secret) != secretLength {
+		return "", false
+	}
+	return prefix, true
+}
+
+func hash(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
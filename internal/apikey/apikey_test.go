@@ -0,0 +1,120 @@
+//go:build smoke
+
+package apikey_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIssue проверяет параметры выпущенного ключа и отказ
+// выпускать ключи с неверными параметрами.
+func TestIssue(t *testing.T) {
+	ctx := context.Background()
+	manager := apikey.New(slogdiscard.NewDiscardLogger(), memory.New(), time.Minute)
+
+	key, saved, err := manager.Issue(ctx, apikey.IssueOptions{
+		Name:      "ci",
+		Scopes:    []string{apikey.ScopeLinksRead, apikey.ScopeLinksCreate, apikey.ScopeLinksRead},
+		TTL:       time.Hour,
+		CreatedBy: "admin",
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "usk_"+saved.Prefix+"_"))
+	assert.NotContains(t, string(saved.Hash), key)
+	assert.Equal(t, []string{apikey.ScopeLinksCreate, apikey.ScopeLinksRead}, saved.Scopes)
+	require.NotNil(t, saved.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *saved.ExpiresAt, time.Minute)
+
+	_, forever, err := manager.Issue(ctx, apikey.IssueOptions{Name: "bot", Scopes: []string{apikey.ScopeStatsRead}})
+	require.NoError(t, err)
+	assert.Nil(t, forever.ExpiresAt)
+	assert.NotEqual(t, saved.Prefix, forever.Prefix)
+
+	for _, opts := range []apikey.IssueOptions{
+		{Name: "ci"},
+		{Name: "ci", Scopes: []string{"links:write"}},
+		{Name: " ", Scopes: []string{apikey.ScopeLinksRead}},
+		{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}, TTL: -time.Hour},
+	} {
+		_, _, err := manager.Issue(ctx, opts)
+		assert.ErrorIs(t, err, apikey.ErrInvalidOptions, "%+v", opts)
+	}
+}
+
+// TestAuthenticate проверяет, что принимаются только выпущенные,
+// не отозванные и не истекшие ключи.
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	strg := memory.New()
+	manager := apikey.New(slogdiscard.NewDiscardLogger(), strg, time.Minute)
+
+	key, saved, err := manager.Issue(ctx, apikey.IssueOptions{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}})
+	require.NoError(t, err)
+	expiredKey, _, err := manager.Issue(ctx, apikey.IssueOptions{Name: "old", Scopes: []string{apikey.ScopeLinksRead}, TTL: time.Nanosecond})
+	require.NoError(t, err)
+	revokedKey, revoked, err := manager.Issue(ctx, apikey.IssueOptions{Name: "leaked", Scopes: []string{apikey.ScopeLinksRead}})
+	require.NoError(t, err)
+	_, err = strg.RevokeAPIKey(ctx, revoked.Id, time.Now())
+	require.NoError(t, err)
+
+	found, err := manager.Authenticate(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, saved.Id, found.Id)
+	assert.Equal(t, []string{apikey.ScopeLinksRead}, found.Scopes)
+
+	wrongSecret := key[:len(key)-1] + "0"
+	if strings.HasSuffix(key, "0") {
+		wrongSecret = key[:len(key)-1] + "1"
+	}
+
+	cases := []struct {
+		caseName string
+		key      string
+		err      error
+	}{
+		{"Empty", "", apikey.ErrInvalidKey},
+		{"Wrong format", "secret", apikey.ErrInvalidKey},
+		{"Wrong secret", wrongSecret, apikey.ErrInvalidKey},
+		{"Unknown prefix", "usk_0000000000_" + strings.Repeat("0", 32), apikey.ErrInvalidKey},
+		{"Expired", expiredKey, apikey.ErrKeyExpired},
+		{"Revoked", revokedKey, apikey.ErrKeyRevoked},
+	}
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			_, err := manager.Authenticate(ctx, tc.key)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+// TestAuthenticateTouch проверяет, что время использования ключа
+// сохраняется не чаще раза в touchInterval.
+func TestAuthenticateTouch(t *testing.T) {
+	ctx := context.Background()
+	strg := memory.New()
+	manager := apikey.New(slogdiscard.NewDiscardLogger(), strg, time.Hour)
+
+	key, saved, err := manager.Issue(ctx, apikey.IssueOptions{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}})
+	require.NoError(t, err)
+	assert.Nil(t, saved.LastUsedAt)
+
+	first, err := manager.Authenticate(ctx, key)
+	require.NoError(t, err)
+	require.NotNil(t, first.LastUsedAt)
+
+	_, err = manager.Authenticate(ctx, key)
+	require.NoError(t, err)
+	stored, err := strg.GetAPIKeyByPrefix(ctx, saved.Prefix)
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastUsedAt)
+	assert.True(t, first.LastUsedAt.Equal(*stored.LastUsedAt))
+}
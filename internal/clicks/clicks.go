@@ -0,0 +1,228 @@
+// Package clicks сохраняет переходы по коротким ссылкам
+// в фоне, чтобы не задерживать редирект.
+//
+// Хендлер кладет переход в ограниченную очередь, воркеры забирают
+// переходы из очереди и сохраняют их пачками: когда набралось
+// BatchSize переходов или прошло FlushInterval. Если очередь
+// заполнена дольше EnqueueTimeout, переход отбрасывается.
+package clicks
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+)
+
+const (
+	DefaultBufferSize    = 10000
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = time.Second
+	DefaultWorkers       = 2
+	DefaultSaveTimeout   = 5 * time.Second
+)
+
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) (int, error)
+}
+
+// Stats счетчики пайплайна с момента запуска.
+type Stats struct {
+	// Enqueued переходы, попавшие в очередь
+	Enqueued uint64
+	// Dropped переходы, отброшенные из-за заполненной очереди
+	Dropped uint64
+	// Saved переходы, сохраненные в хранилище
+	Saved uint64
+	// Skipped переходы по ссылкам, удаленным до сохранения
+	Skipped uint64
+	// Failed переходы, которые не удалось сохранить
+	Failed uint64
+	// Queued переходы, ожидающие сохранения
+	Queued int
+}
+
+func (s Stats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Uint64("enqueued", s.Enqueued),
+		slog.Uint64("dropped", s.Dropped),
+		slog.Uint64("saved", s.Saved),
+		slog.Uint64("skipped", s.Skipped),
+		slog.Uint64("failed", s.Failed),
+		slog.Int("queued", s.Queued),
+	)
+}
+
+type Pipeline struct {
+	log   *slog.Logger
+	saver ClickSaver
+	cfg   config.Clicks
+
+	// mu защищает закрытие events от конкурентной записи
+	mu     sync.RWMutex
+	closed bool
+	events chan storage.Click
+	wg     sync.WaitGroup
+
+	enqueued atomic.Uint64
+	dropped  atomic.Uint64
+	saved    atomic.Uint64
+	skipped  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+// New создает пайплайн и запускает воркеры. Нулевые значения
+// в cfg заменяются значениями по умолчанию.
+func New(log *slog.Logger, saver ClickSaver, cfg config.Clicks) *Pipeline {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.SaveTimeout <= 0 {
+		cfg.SaveTimeout = DefaultSaveTimeout
+	}
+
+	p := &Pipeline{
+		log:    log.With(slog.String("component", "clicks")),
+		saver:  saver,
+		cfg:    cfg,
+		events: make(chan storage.Click, cfg.BufferSize),
+	}
+	for range cfg.Workers {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	return p
+}
+
+// RecordClick ставит переход в очередь на сохранение. Если очередь
+// заполнена, ждет не дольше EnqueueTimeout и отбрасывает переход.
+func (p *Pipeline) RecordClick(click storage.Click) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.dropped.Add(1)
+		return
+	}
+
+	select {
+	case p.events <- click:
+		p.enqueued.Add(1)
+		return
+	default:
+	}
+
+	if p.cfg.EnqueueTimeout > 0 {
+		timer := time.NewTimer(p.cfg.EnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case p.events <- click:
+			p.enqueued.Add(1)
+			return
+		case <-timer.C:
+		}
+	}
+
+	p.dropped.Add(1)
+	p.log.Debug("click dropped, queue is full", slog.String("alias", click.Alias))
+}
+
+// Close перестает принимать переходы и ждет, пока воркеры сохранят
+// все, что осталось в очереди. Если ctx завершится раньше,
+// возвращается ctx.Err(), а несохраненные переходы теряются.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.log.Info("click pipeline stopped", slog.Any("clicks", p.Stats()))
+		return nil
+	case <-ctx.Done():
+		p.log.Error("click pipeline stopped before queue flushed", slog.Any("clicks", p.Stats()))
+		return ctx.Err()
+	}
+}
+
+func (p *Pipeline) Stats() Stats {
+	return Stats{
+		Enqueued: p.enqueued.Load(),
+		Dropped:  p.dropped.Load(),
+		Saved:    p.saved.Load(),
+		Skipped:  p.skipped.Load(),
+		Failed:   p.failed.Load(),
+		Queued:   len(p.events),
+	}
+}
+
+// worker копит переходы из очереди и сохраняет их пачками.
+// Завершается, когда очередь закрыта и вычитана.
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, p.cfg.BatchSize)
+	for {
+		select {
+		case click, ok := <-p.events:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (p *Pipeline) flush(batch []storage.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.SaveTimeout)
+	defer cancel()
+
+	saved, err := p.saver.SaveClicks(ctx, batch)
+	if err != nil {
+		p.failed.Add(uint64(len(batch)))
+		p.log.Error("failed to save clicks", slog.Int("count", len(batch)), xslog.Err(err))
+		return
+	}
+	p.saved.Add(uint64(saved))
+	p.skipped.Add(uint64(len(batch) - saved))
+}
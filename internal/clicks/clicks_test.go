@@ -0,0 +1,128 @@
+//go:build smoke
+
+package clicks_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/clicks"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSaver запоминает размеры сохраненных пачек. Пока block
+// не закрыт, сохранение ждет.
+type fakeSaver struct {
+	mu      sync.Mutex
+	batches []int
+	block   chan struct{}
+}
+
+func (s *fakeSaver) SaveClicks(_ context.Context, clicks []storage.Click) (int, error) {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, len(clicks))
+	return len(clicks), nil
+}
+
+func (s *fakeSaver) Batches() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.batches...)
+}
+
+// TestFlushBySize проверяет, что полная пачка сохраняется
+// сразу, не дожидаясь FlushInterval.
+func TestFlushBySize(t *testing.T) {
+	saver := &fakeSaver{}
+	pipeline := clicks.New(slogdiscard.NewDiscardLogger(), saver, config.Clicks{BatchSize: 3, Workers: 1, FlushInterval: time.Hour})
+	defer pipeline.Close(context.Background())
+
+	for range 3 {
+		pipeline.RecordClick(storage.Click{Alias: "alias"})
+	}
+
+	require.Eventually(t, func() bool {
+		return len(saver.Batches()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{3}, saver.Batches())
+}
+
+// TestFlushByInterval проверяет, что неполная пачка
+// сохраняется по истечении FlushInterval.
+func TestFlushByInterval(t *testing.T) {
+	saver := &fakeSaver{}
+	pipeline := clicks.New(slogdiscard.NewDiscardLogger(), saver, config.Clicks{BatchSize: 100, Workers: 1, FlushInterval: 10 * time.Millisecond})
+	defer pipeline.Close(context.Background())
+
+	pipeline.RecordClick(storage.Click{Alias: "alias"})
+
+	require.Eventually(t, func() bool {
+		return pipeline.Stats().Saved == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestDropWhenQueueFull проверяет, что при заполненной очереди
+// переходы отбрасываются и попадают в счетчик Dropped, а после
+// Close все принятые переходы сохранены.
+func TestDropWhenQueueFull(t *testing.T) {
+	saver := &fakeSaver{block: make(chan struct{})}
+	pipeline := clicks.New(slogdiscard.NewDiscardLogger(), saver, config.Clicks{BufferSize: 2, BatchSize: 1, Workers: 1, FlushInterval: time.Hour})
+
+	// Первый переход забирает воркер и зависает на сохранении,
+	// следующие два заполняют очередь
+	pipeline.RecordClick(storage.Click{Alias: "alias"})
+	require.Eventually(t, func() bool {
+		return pipeline.Stats().Queued == 0
+	}, time.Second, time.Millisecond)
+	for range 4 {
+		pipeline.RecordClick(storage.Click{Alias: "alias"})
+	}
+
+	stats := pipeline.Stats()
+	assert.Equal(t, uint64(3), stats.Enqueued)
+	assert.Equal(t, uint64(2), stats.Dropped)
+
+	close(saver.block)
+	require.NoError(t, pipeline.Close(context.Background()))
+	assert.Equal(t, uint64(3), pipeline.Stats().Saved)
+
+	pipeline.RecordClick(storage.Click{Alias: "alias"})
+	assert.Equal(t, uint64(3), pipeline.Stats().Dropped)
+}
+
+// TestCloseFlushesQueue проверяет, что Close сохраняет
+// переходы, которые еще не набрали пачку.
+func TestCloseFlushesQueue(t *testing.T) {
+	saver := &fakeSaver{}
+	pipeline := clicks.New(slogdiscard.NewDiscardLogger(), saver, config.Clicks{BatchSize: 100, Workers: 2, FlushInterval: time.Hour})
+
+	for range 10 {
+		pipeline.RecordClick(storage.Click{Alias: "alias"})
+	}
+	require.NoError(t, pipeline.Close(context.Background()))
+
+	assert.Equal(t, uint64(10), pipeline.Stats().Saved)
+}
+
+// TestCloseTimeout проверяет, что Close не ждет
+// зависшее хранилище дольше ctx.
+func TestCloseTimeout(t *testing.T) {
+	saver := &fakeSaver{block: make(chan struct{})}
+	defer close(saver.block)
+	pipeline := clicks.New(slogdiscard.NewDiscardLogger(), saver, config.Clicks{Workers: 1})
+	pipeline.RecordClick(storage.Click{Alias: "alias"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pipeline.Close(ctx), context.DeadlineExceeded)
+}
@@ -11,14 +11,146 @@ import (
 type Config struct {
 	Env        string `yaml:"env" env-required:"true"`
 	HTTPServer `yaml:"http_server"`
+	Storage    Storage `yaml:"storage"`
+	Purge      Purge   `yaml:"purge"`
+	Clicks     Clicks  `yaml:"clicks"`
+	Batch      Batch   `yaml:"batch"`
+	Save       Save    `yaml:"save"`
+	Alias      Alias   `yaml:"alias"`
+	APIKeys    APIKeys `yaml:"api_keys"`
 }
 
 type HTTPServer struct {
 	Address      string        `yaml:"address" env-default:"localhost:8080"`
 	Timeout      time.Duration `yaml:"timeout" env-default:"4s"`
 	IddleTimeout time.Duration `yaml:"iddle_timeout" env-default:"60s"`
-	UserName     string        `yaml:"username" env-required:"true"`
-	Password     string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	// ShutdownTimeout сколько ждать завершения текущих запросов при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	// UserName и Password единственный пользователь API, если не задан UsersFile
+	UserName string `yaml:"username"`
+	Password string `yaml:"password" env:"HTTP_SERVER_PASSWORD"`
+	// UsersFile файл пользователей API в формате htpasswd с bcrypt-хешами
+	UsersFile string `yaml:"users_file" env:"HTTP_SERVER_USERS_FILE"`
+	// UsersReloadInterval как часто проверять, изменился ли UsersFile,
+	// 0 - только по SIGHUP
+	UsersReloadInterval time.Duration `yaml:"users_reload_interval" env-default:"30s"`
+}
+
+type Storage struct {
+	// Driver - postgres, sqlite или memory
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER" env-default:"postgres"`
+	// QueryTimeout сколько ждать ответа хранилища на один запрос хендлера
+	QueryTimeout time.Duration `yaml:"query_timeout" env-default:"3s"`
+	Postgres     Postgres      `yaml:"postgres"`
+	SQLite       SQLite        `yaml:"sqlite"`
+}
+
+// Postgres настройки пула соединений. Нулевые значения
+// означают значения по умолчанию из pgxpool.
+type Postgres struct {
+	MaxConns          int32         `yaml:"max_conns" env-default:"10"`
+	MinConns          int32         `yaml:"min_conns" env-default:"2"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env-default:"1h"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env-default:"30m"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env-default:"1m"`
+	AcquireTimeout    time.Duration `yaml:"acquire_timeout" env-default:"3s"`
+	StatsInterval     time.Duration `yaml:"stats_interval" env-default:"0s"`
+	// MigrateOnStartup применяет встроенные миграции при старте сервера
+	MigrateOnStartup bool `yaml:"migrate_on_startup" env:"MIGRATE_ON_STARTUP" env-default:"false"`
+}
+
+// Purge настройки фоновой очистки ссылок с истекшим сроком жизни.
+type Purge struct {
+	// Interval период очистки, 0 - очистка выключена
+	Interval time.Duration `yaml:"interval" env-default:"1h"`
+	// Mode - delete (удалить) или archive (перенести в архив)
+	Mode string `yaml:"mode" env-default:"delete"`
+}
+
+// Clicks настройки фонового сохранения переходов.
+type Clicks struct {
+	// BufferSize размер очереди переходов
+	BufferSize int `yaml:"buffer_size" env-default:"10000"`
+	// BatchSize сколько переходов сохранять за раз
+	BatchSize int `yaml:"batch_size" env-default:"500"`
+	// FlushInterval как часто сохранять неполную пачку
+	FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"`
+	Workers       int           `yaml:"workers" env-default:"2"`
+	SaveTimeout   time.Duration `yaml:"save_timeout" env-default:"5s"`
+	// EnqueueTimeout сколько ждать места в заполненной очереди,
+	// 0 - сразу отбрасывать переход
+	EnqueueTimeout time.Duration `yaml:"enqueue_timeout" env-default:"0s"`
+	// StatsInterval период логирования счетчиков, 0 - выключено
+	StatsInterval time.Duration `yaml:"stats_interval" env-default:"0s"`
+}
+
+// Save настройки создания ссылок.
+type Save struct {
+	// Dedupe - по умолчанию возвращать существующий сгенерированный
+	// алиас вместо создания новой ссылки на тот же URL
+	Dedupe bool `yaml:"dedupe" env-default:"false"`
+	// StripParams параметры query, которые не учитываются при сравнении
+	// URL, "utm_*" - все параметры с префиксом utm_
+	StripParams []string `yaml:"strip_params" env-default:"utm_*,fbclid,gclid,yclid"`
+}
+
+// Alias настройки генерации алиасов.
+type Alias struct {
+	// Strategy стратегия по умолчанию: random, base62, hashids или words
+	Strategy string `yaml:"strategy" env-default:"random"`
+	// Alphabet символы сгенерированных алиасов
+	Alphabet string `yaml:"alphabet" env-default:"abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"`
+	// Length начальная длина сгенерированного алиаса
+	Length int `yaml:"length" env-default:"6"`
+	// MaxLength до какой длины алиасы могут удлиняться
+	MaxLength int `yaml:"max_length" env-default:"12"`
+	// GrowAfter после скольких коллизий подряд удлинять алиасы
+	GrowAfter int `yaml:"grow_after" env-default:"3"`
+	// Retries сколько раз пробовать новый алиас, если сгенерированный занят
+	Retries int `yaml:"retries" env-default:"3"`
+	// HashidsSalt соль стратегии hashids, от нее зависит вид алиасов
+	HashidsSalt string `yaml:"hashids_salt" env:"ALIAS_HASHIDS_SALT"`
+	// HashidsMinLength минимальная длина алиасов стратегии hashids
+	HashidsMinLength int `yaml:"hashids_min_length" env-default:"6"`
+	// Suggestions сколько свободных вариантов предлагать вместо занятого алиаса
+	Suggestions int `yaml:"suggestions" env-default:"5"`
+	// Rules правила для алиасов, которые задают пользователи
+	Rules AliasRules `yaml:"rules"`
+}
+
+// AliasRules правила для пользовательских алиасов.
+type AliasRules struct {
+	// Charset допустимые символы
+	Charset   string `yaml:"charset" env-default:"abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"`
+	MinLength int    `yaml:"min_length" env-default:"3"`
+	MaxLength int    `yaml:"max_length" env-default:"64"`
+	// Pattern регулярное выражение для всего алиаса, пустое - не проверяется
+	Pattern string `yaml:"pattern"`
+	// Reserved слова, которые нельзя занять. Пути роутера
+	// резервируются сами
+	Reserved []string `yaml:"reserved" env-default:"api,admin,static,health,metrics"`
+	// Fold - сравнивать алиасы без учета регистра и похожих символов
+	// (0 и O, 1, l и I) при создании и редиректе
+	Fold bool `yaml:"fold" env-default:"false"`
+}
+
+// APIKeys настройки ключей API.
+type APIKeys struct {
+	// Admins пользователи, которые могут выпускать и отзывать ключи,
+	// пустой - ключами нельзя управлять через API
+	Admins []string `yaml:"admins"`
+	// TouchInterval как часто сохранять время последнего использования ключа
+	TouchInterval time.Duration `yaml:"touch_interval" env-default:"1m"`
+}
+
+// Batch настройки массового создания ссылок.
+type Batch struct {
+	// MaxItems сколько ссылок можно передать в одном запросе
+	MaxItems int `yaml:"max_items" env-default:"500"`
+}
+
+type SQLite struct {
+	Path string `yaml:"path" env:"SQLITE_PATH" env-default:"./storage.db"`
 }
 
 func MustLoad() *Config {
@@ -36,6 +168,9 @@ func MustLoad() *Config {
 	if err := cleanenv.ReadConfig(configPath, &config); err != nil {
 		log.Fatalf("cannot read cofnig file: (%v)", err)
 	}
+	if config.HTTPServer.UsersFile == "" && (config.HTTPServer.UserName == "" || config.HTTPServer.Password == "") {
+		log.Fatal("http_server.users_file or http_server.username with http_server.password must be set")
+	}
 
 	return &config
 }
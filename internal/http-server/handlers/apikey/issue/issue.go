@@ -0,0 +1,97 @@
+package issue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/http-server/handlers/apikey/list"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+const ErrMsgFailedIssue = "failed to issue api key"
+
+type KeyIssuer interface {
+	Issue(ctx context.Context, opts apikey.IssueOptions) (string, storage.APIKey, error)
+}
+
+type Request struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=links:create links:delete links:read stats:read"`
+	// TTL срок жизни ключа в секундах, не больше 100 лет, 0 - бессрочный
+	TTL int64 `json:"ttl,omitempty" validate:"gte=0,lte=3153600000"`
+}
+
+type Response struct {
+	response.Response
+	// Key сам ключ, больше его нигде не получить
+	Key    string     `json:"key,omitempty"`
+	APIKey *list.Item `json:"api_key,omitempty"`
+}
+
+var requestValidator = validate.New()
+
+// New выпускает ключ API от имени того, кто выполняет запрос.
+func New(log *slog.Logger, keyIssuer KeyIssuer, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.apikey.issue.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var request Request
+		if err := render.DecodeJSON(r.Body, &request); err != nil {
+			log.Error("failed to decode request body", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "failed to decode request"))
+			return
+		}
+		if err := requestValidator.Struct(request); err != nil {
+			log.Info("invalid request", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(err.(validator.ValidationErrors)))
+			return
+		}
+
+		// Хендлер вызывается только после middleware auth
+		principal, _ := auth.PrincipalFrom(r.Context())
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		key, saved, err := keyIssuer.Issue(ctx, apikey.IssueOptions{
+			Name:      request.Name,
+			Scopes:    request.Scopes,
+			TTL:       time.Duration(request.TTL) * time.Second,
+			CreatedBy: principal.Name,
+		})
+		if errors.Is(err, apikey.ErrInvalidOptions) {
+			log.Info("invalid api key options", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeValidation, err.Error()))
+			return
+		}
+		if err != nil {
+			log.Error(ErrMsgFailedIssue, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedIssue)
+			return
+		}
+
+		log.Info("api key issued", slog.Int("id", saved.Id), slog.String("name", saved.Name))
+		item := list.NewItem(saved, time.Now())
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Key:      key,
+			APIKey:   &item,
+		})
+	}
+}
@@ -0,0 +1,119 @@
+//go:build smoke
+
+package issue_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/http-server/handlers/apikey/issue"
+	"url-shortener/internal/http-server/handlers/apikey/issue/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIssue проверяет выпуск ключа и валидацию запроса.
+func TestIssue(t *testing.T) {
+	cases := []struct {
+		caseName   string
+		body       string
+		callMock   bool
+		opts       apikey.IssueOptions
+		mockError  error
+		httpStatus int
+		respCode   string
+	}{
+		{
+			caseName:   "Success",
+			body:       `{"name":"ci","scopes":["links:create","links:read"],"ttl":3600}`,
+			callMock:   true,
+			opts:       apikey.IssueOptions{Name: "ci", Scopes: []string{"links:create", "links:read"}, TTL: time.Hour, CreatedBy: "admin"},
+			httpStatus: http.StatusOK,
+		},
+		{
+			caseName:   "Without scopes",
+			body:       `{"name":"ci","scopes":[]}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName:   "Unknown scope",
+			body:       `{"name":"ci","scopes":["links:write"]}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName:   "Without name",
+			body:       `{"scopes":["links:read"]}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName:   "Negative ttl",
+			body:       `{"name":"ci","scopes":["links:read"],"ttl":-1}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName:   "Too large ttl",
+			body:       `{"name":"ci","scopes":["links:read"],"ttl":9300000000}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName:   "Broken body",
+			body:       `{"name":`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeBadRequest,
+		},
+		{
+			caseName:   "Storage unavailable",
+			body:       `{"name":"ci","scopes":["links:read"]}`,
+			callMock:   true,
+			opts:       apikey.IssueOptions{Name: "ci", Scopes: []string{"links:read"}, CreatedBy: "admin"},
+			mockError:  storage.ErrUnavailable,
+			httpStatus: http.StatusServiceUnavailable,
+			respCode:   response.CodeStorageUnavailable,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			keyIssuerMock := mocks.NewKeyIssuer(t)
+			if tc.callMock {
+				saved := storage.APIKey{Id: 3, Name: tc.opts.Name, Prefix: "abcdefghij", Scopes: tc.opts.Scopes, CreatedBy: "admin"}
+				keyIssuerMock.On("Issue", mock.Anything, tc.opts).Return("usk_abcdefghij_secret", saved, tc.mockError).Once()
+			}
+
+			handler := issue.New(slogdiscard.NewDiscardLogger(), keyIssuerMock, time.Second)
+			req := httptest.NewRequest(http.MethodPost, "/admin/keys", strings.NewReader(tc.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: "admin"}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp issue.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			if tc.httpStatus != http.StatusOK {
+				assert.Empty(t, resp.Key)
+				return
+			}
+			assert.Equal(t, "usk_abcdefghij_secret", resp.Key)
+			require.NotNil(t, resp.APIKey)
+			assert.Equal(t, 3, resp.APIKey.Id)
+			assert.Equal(t, tc.opts.Scopes, resp.APIKey.Scopes)
+			assert.True(t, resp.APIKey.Active)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	apikey "url-shortener/internal/apikey"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// KeyIssuer is an autogenerated mock type for the KeyIssuer type
+type KeyIssuer struct {
+	mock.Mock
+}
+
+// Issue provides a mock function with given fields: ctx, opts
+func (_m *KeyIssuer) Issue(ctx context.Context, opts apikey.IssueOptions) (string, storage.APIKey, error) {
+	ret := _m.Called(ctx, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Issue")
+	}
+
+	var r0 string
+	var r1 storage.APIKey
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, apikey.IssueOptions) (string, storage.APIKey, error)); ok {
+		return rf(ctx, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, apikey.IssueOptions) string); ok {
+		r0 = rf(ctx, opts)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, apikey.IssueOptions) storage.APIKey); ok {
+		r1 = rf(ctx, opts)
+	} else {
+		r1 = ret.Get(1).(storage.APIKey)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, apikey.IssueOptions) error); ok {
+		r2 = rf(ctx, opts)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewKeyIssuer creates a new instance of KeyIssuer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewKeyIssuer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *KeyIssuer {
+	mock := &KeyIssuer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
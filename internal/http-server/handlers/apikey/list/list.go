@@ -0,0 +1,89 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const ErrMsgFailedList = "failed to list api keys"
+
+type KeyLister interface {
+	ListAPIKeys(ctx context.Context) ([]storage.APIKey, error)
+}
+
+// Item ключ API без хеша.
+type Item struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Active ключ не отозван и не истек
+	Active bool `json:"active"`
+}
+
+// NewItem возвращает ключ в виде для ответа, now - момент,
+// на который определяется Active.
+func NewItem(key storage.APIKey, now time.Time) Item {
+	return Item{
+		Id:         key.Id,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		CreatedBy:  key.CreatedBy,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		Active:     key.RevokedAt == nil && !storage.IsExpired(key.ExpiresAt, now),
+	}
+}
+
+type Response struct {
+	response.Response
+	Items []Item `json:"items"`
+}
+
+// New возвращает все ключи API, включая отозванные и истекшие.
+func New(log *slog.Logger, keyLister KeyLister, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.apikey.list.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		keys, err := keyLister.ListAPIKeys(ctx)
+		if err != nil {
+			log.Error(ErrMsgFailedList, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedList)
+			return
+		}
+
+		now := time.Now()
+		items := make([]Item, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, NewItem(key, now))
+		}
+
+		log.Info("api keys listed", slog.Int("count", len(items)))
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Items:    items,
+		})
+	}
+}
@@ -0,0 +1,61 @@
+//go:build smoke
+
+package list_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/apikey/list"
+	"url-shortener/internal/http-server/handlers/apikey/list/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestList проверяет, что ключи возвращаются без хешей и с
+// признаком действующего ключа.
+func TestList(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	keys := []storage.APIKey{
+		{Id: 1, Name: "ci", Prefix: "aaaaaaaaaa", Hash: []byte("hash"), Scopes: []string{"links:read"}},
+		{Id: 2, Name: "old", Prefix: "bbbbbbbbbb", Hash: []byte("hash"), Scopes: []string{"links:read"}, ExpiresAt: &past},
+		{Id: 3, Name: "leaked", Prefix: "cccccccccc", Hash: []byte("hash"), Scopes: []string{"links:read"}, RevokedAt: &past},
+	}
+	keyListerMock := mocks.NewKeyLister(t)
+	keyListerMock.On("ListAPIKeys", mock.Anything).Return(keys, nil).Once()
+
+	handler := list.New(slogdiscard.NewDiscardLogger(), keyListerMock, time.Second)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "hash")
+	var resp list.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Items, 3)
+	assert.True(t, resp.Items[0].Active)
+	assert.False(t, resp.Items[1].Active)
+	assert.False(t, resp.Items[2].Active)
+	assert.NotNil(t, resp.Items[2].RevokedAt)
+}
+
+func TestListStorageError(t *testing.T) {
+	keyListerMock := mocks.NewKeyLister(t)
+	keyListerMock.On("ListAPIKeys", mock.Anything).Return(nil, storage.ErrUnavailable).Once()
+
+	handler := list.New(slogdiscard.NewDiscardLogger(), keyListerMock, time.Second)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var resp list.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, response.CodeStorageUnavailable, resp.Code)
+}
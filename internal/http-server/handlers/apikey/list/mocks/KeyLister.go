@@ -0,0 +1,60 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// KeyLister is an autogenerated mock type for the KeyLister type
+type KeyLister struct {
+	mock.Mock
+}
+
+// ListAPIKeys provides a mock function with given fields: ctx
+func (_m *KeyLister) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAPIKeys")
+	}
+
+	var r0 []storage.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]storage.APIKey, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []storage.APIKey); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewKeyLister creates a new instance of KeyLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewKeyLister(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *KeyLister {
+	mock := &KeyLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,60 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+
+	time "time"
+)
+
+// KeyRevoker is an autogenerated mock type for the KeyRevoker type
+type KeyRevoker struct {
+	mock.Mock
+}
+
+// RevokeAPIKey provides a mock function with given fields: ctx, id, now
+func (_m *KeyRevoker) RevokeAPIKey(ctx context.Context, id int, now time.Time) (storage.APIKey, error) {
+	ret := _m.Called(ctx, id, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAPIKey")
+	}
+
+	var r0 storage.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) (storage.APIKey, error)); ok {
+		return rf(ctx, id, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) storage.APIKey); ok {
+		r0 = rf(ctx, id, now)
+	} else {
+		r0 = ret.Get(0).(storage.APIKey)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Time) error); ok {
+		r1 = rf(ctx, id, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewKeyRevoker creates a new instance of KeyRevoker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewKeyRevoker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *KeyRevoker {
+	mock := &KeyRevoker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,76 @@
+package revoke
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/http-server/handlers/apikey/list"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgInvalidId    = "id must be a positive number"
+	ErrMsgKeyNotFound  = "no api key with this id"
+	ErrMsgFailedRevoke = "failed to revoke api key"
+)
+
+type KeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, id int, now time.Time) (storage.APIKey, error)
+}
+
+type Response struct {
+	response.Response
+	APIKey *list.Item `json:"api_key,omitempty"`
+}
+
+// New отзывает ключ API с id из пути. Запросы с отозванным ключом
+// сразу перестают проходить, повторный отзыв ничего не меняет.
+func New(log *slog.Logger, keyRevoker KeyRevoker, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.apikey.revoke.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil || id < 1 {
+			log.Info("invalid api key id", slog.String("id", chi.URLParam(r, "id")))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgInvalidId))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		now := time.Now()
+		key, err := keyRevoker.RevokeAPIKey(ctx, id, now)
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Info("api key not found", slog.Int("id", id))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrMsgKeyNotFound))
+			return
+		}
+		if err != nil {
+			log.Error(ErrMsgFailedRevoke, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedRevoke)
+			return
+		}
+
+		log.Info("api key revoked", slog.Int("id", key.Id), slog.String("name", key.Name))
+		item := list.NewItem(key, now)
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			APIKey:   &item,
+		})
+	}
+}
@@ -0,0 +1,64 @@
+//go:build smoke
+
+package revoke_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/apikey/revoke"
+	"url-shortener/internal/http-server/handlers/apikey/revoke/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevoke(t *testing.T) {
+	revokedAt := time.Now()
+	cases := []struct {
+		caseName   string
+		id         string
+		callMock   bool
+		mockError  error
+		httpStatus int
+		respCode   string
+	}{
+		{caseName: "Success", id: "3", callMock: true, httpStatus: http.StatusOK},
+		{caseName: "Not found", id: "3", callMock: true, mockError: storage.ErrAPIKeyNotFound, httpStatus: http.StatusNotFound, respCode: response.CodeNotFound},
+		{caseName: "Not a number", id: "ci", httpStatus: http.StatusBadRequest, respCode: response.CodeBadRequest},
+		{caseName: "Zero id", id: "0", httpStatus: http.StatusBadRequest, respCode: response.CodeBadRequest},
+		{caseName: "Storage unavailable", id: "3", callMock: true, mockError: storage.ErrUnavailable, httpStatus: http.StatusServiceUnavailable, respCode: response.CodeStorageUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			keyRevokerMock := mocks.NewKeyRevoker(t)
+			if tc.callMock {
+				keyRevokerMock.On("RevokeAPIKey", mock.Anything, 3, mock.AnythingOfType("time.Time")).
+					Return(storage.APIKey{Id: 3, Name: "ci", RevokedAt: &revokedAt}, tc.mockError).Once()
+			}
+
+			r := chi.NewRouter()
+			r.Delete("/admin/keys/{id}", revoke.New(slogdiscard.NewDiscardLogger(), keyRevokerMock, time.Second))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/keys/"+tc.id, nil))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp revoke.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			if tc.httpStatus == http.StatusOK {
+				require.NotNil(t, resp.APIKey)
+				assert.Equal(t, 3, resp.APIKey.Id)
+				assert.False(t, resp.APIKey.Active)
+			}
+		})
+	}
+}
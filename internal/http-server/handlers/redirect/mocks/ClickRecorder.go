@@ -0,0 +1,33 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// ClickRecorder is an autogenerated mock type for the ClickRecorder type
+type ClickRecorder struct {
+	mock.Mock
+}
+
+// RecordClick provides a mock function with given fields: click
+func (_m *ClickRecorder) RecordClick(click storage.Click) {
+	_m.Called(click)
+}
+
+// NewClickRecorder creates a new instance of ClickRecorder. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClickRecorder(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClickRecorder {
+	mock := &ClickRecorder{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,4 +1,4 @@
-// Code generated by mockery v2.45.0. DO NOT EDIT.
+// Code generated by mockery v2.53.7. DO NOT EDIT.
 
 package mocks
 
@@ -13,9 +13,9 @@ type URLGetter struct {
 	mock.Mock
 }
 
-// GetURLByAlias provides a mock function with given fields: ctx, alias
-func (_m *URLGetter) GetURLByAlias(ctx context.Context, alias string) (string, error) {
-	ret := _m.Called(ctx, alias)
+// GetURLByAlias provides a mock function with given fields: ctx, aliasKey
+func (_m *URLGetter) GetURLByAlias(ctx context.Context, aliasKey string) (string, error) {
+	ret := _m.Called(ctx, aliasKey)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetURLByAlias")
@@ -24,16 +24,16 @@ func (_m *URLGetter) GetURLByAlias(ctx context.Context, alias string) (string, e
 	var r0 string
 	var r1 error
 	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
-		return rf(ctx, alias)
+		return rf(ctx, aliasKey)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
-		r0 = rf(ctx, alias)
+		r0 = rf(ctx, aliasKey)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, alias)
+		r1 = rf(ctx, aliasKey)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/anonymize"
 	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/xslog"
 	"url-shortener/internal/storage"
@@ -15,15 +18,24 @@ import (
 )
 
 type URLGetter interface {
-	GetURLByAlias(ctx context.Context, alias string) (string, error)
+	GetURLByAlias(ctx context.Context, aliasKey string) (string, error)
+}
+
+// ClickRecorder сохраняет переход. Не должен блокировать редирект.
+type ClickRecorder interface {
+	RecordClick(click storage.Click)
 }
 
 const (
 	ErrMsgGetURL          = "failed to get URL"
 	ErrMsgRedirectNoAlias = "no url on this alias"
+	ErrMsgURLExpired      = "url on this alias expired"
 )
 
-func New(ctx context.Context, log *slog.Logger, getURL URLGetter) http.HandlerFunc {
+// New перенаправляет по алиасу. Ссылка ищется сначала по самому
+// алиасу, затем по ключу алиаса, который строит rules. Переход
+// сохраняется по ключу, по которому нашлась ссылка.
+func New(log *slog.Logger, getURL URLGetter, rules *aliasrule.Rules, clickRecorder ClickRecorder, queryTimeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const operationPlace = "handlers.redirect.New"
 		log = log.With(
@@ -34,25 +46,52 @@ func New(ctx context.Context, log *slog.Logger, getURL URLGetter) http.HandlerFu
 
 		if alias == "" {
 			log.Info("alias is empty")
-			render.JSON(w, r, response.Error("empty alias"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "empty alias"))
 			return
 		}
 
-		url, err := getURL.GetURLByAlias(ctx, alias)
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		// У ссылок, созданных до включения сравнения похожих алиасов,
+		// ключом остался сам алиас, и такие ссылки могут отличаться
+		// только регистром. Точное совпадение поэтому проверяется первым
+		aliasKey := alias
+		url, err := getURL.GetURLByAlias(ctx, aliasKey)
+		if key := rules.Key(alias); errors.Is(err, storage.ErrURLNotFound) && key != alias {
+			aliasKey = key
+			url, err = getURL.GetURLByAlias(ctx, aliasKey)
+		}
 
 		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("no url on this alias", "alias", alias)
-			render.JSON(w, r, response.Error(ErrMsgRedirectNoAlias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrMsgRedirectNoAlias))
+			return
+		}
+
+		if errors.Is(err, storage.ErrURLExpired) {
+			log.Info("url on this alias expired", "alias", alias)
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, response.Error(response.CodeExpired, ErrMsgURLExpired))
 			return
 		}
 
 		if err != nil {
 			log.Error(ErrMsgGetURL, xslog.Err(err))
-			render.JSON(w, r, response.Error("internal error"))
+			response.RenderStorageError(w, r, err, response.ErrMsgInternal)
 			return
 		}
 
 		log.Info("find url by alias", "alias", alias)
+		clickRecorder.RecordClick(storage.Click{
+			Alias:     aliasKey,
+			ClickedAt: time.Now(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        anonymize.IP(r.RemoteAddr),
+		})
 		http.Redirect(w, r, url, http.StatusFound)
 
 	}
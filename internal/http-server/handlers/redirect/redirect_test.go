@@ -6,15 +6,19 @@ import (
 	"context"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	"url-shortener/internal/lib/aliasrule"
 	"url-shortener/internal/lib/api"
+	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,7 +27,20 @@ type testData struct {
 	alias     string
 	url       string
 	respError string
+	respCode  string
 	mockError error
+	// fold режим сравнения алиасов без учета регистра и похожих символов
+	fold bool
+	// key ключ, по которому ищется ссылка, "" - сам алиас
+	key string
+	// legacy ссылка создана до включения fold и находится по самому алиасу
+	legacy bool
+}
+
+func newRules(t *testing.T, fold bool) *aliasrule.Rules {
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 1, MaxLength: 64, Fold: fold})
+	require.NoError(t, err)
+	return rules
 }
 
 func TestRedirectSuccess(t *testing.T) {
@@ -33,14 +50,49 @@ func TestRedirectSuccess(t *testing.T) {
 			alias:    "zxc",
 			url:      "http://google.com",
 		},
+		{
+			caseName: "Exact mode keeps case",
+			alias:    "ZXC",
+			url:      "http://google.com",
+		},
+		{
+			caseName: "Fold mode",
+			alias:    "ZXC0-I1",
+			url:      "http://google.com",
+			fold:     true,
+			key:      "zxco-ll",
+		},
+		{
+			caseName: "Fold mode finds link created before it",
+			alias:    "ZXC0",
+			url:      "http://google.com",
+			fold:     true,
+			key:      "zxco",
+			legacy:   true,
+		},
 	}
 	for _, testCase := range cases {
 		t.Run(testCase.caseName, func(t *testing.T) {
 			urlGetterMock := mocks.NewURLGetter(t)
-			ctx := context.Background()
-			urlGetterMock.On("GetURLByAlias", ctx, testCase.alias).Return(testCase.url, testCase.mockError).Once()
+			// Хендлер должен ограничивать запрос к хранилищу по времени
+			hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+				_, ok := ctx.Deadline()
+				return ok
+			})
+			key := testCase.key
+			if key == "" || testCase.legacy {
+				key = testCase.alias
+			} else {
+				// Сначала ссылка ищется по самому алиасу
+				urlGetterMock.On("GetURLByAlias", hasDeadline, testCase.alias).Return("", storage.ErrURLNotFound).Once()
+			}
+			urlGetterMock.On("GetURLByAlias", hasDeadline, key).Return(testCase.url, nil).Once()
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			clickRecorderMock.On("RecordClick", mock.MatchedBy(func(click storage.Click) bool {
+				return click.Alias == key && click.IP == "127.0.0.0" && !click.ClickedAt.IsZero()
+			})).Once()
 			r := chi.NewRouter()
-			r.Get("/{alias}", redirect.New(ctx, slogdiscard.NewDiscardLogger(), urlGetterMock))
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newRules(t, testCase.fold), clickRecorderMock, time.Second))
 			server := httptest.NewServer(r)
 			defer server.Close()
 
@@ -52,6 +104,29 @@ func TestRedirectSuccess(t *testing.T) {
 	}
 }
 
+// TestRedirectLegacyCaseVariants проверяет, что ссылки, созданные до
+// включения fold и отличающиеся только регистром, открываются каждая
+// по своему алиасу.
+func TestRedirectLegacyCaseVariants(t *testing.T) {
+	urls := map[string]string{"abc": "http://lower.ru", "ABC": "http://upper.ru"}
+	urlGetterMock := mocks.NewURLGetter(t)
+	for alias, url := range urls {
+		urlGetterMock.On("GetURLByAlias", mock.Anything, alias).Return(url, nil).Once()
+	}
+	clickRecorderMock := mocks.NewClickRecorder(t)
+	clickRecorderMock.On("RecordClick", mock.Anything).Times(len(urls))
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newRules(t, true), clickRecorderMock, time.Second))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for alias, url := range urls {
+		redirect, err := api.GetRedirect(server.URL + "/" + alias)
+		require.NoError(t, err)
+		assert.Equal(t, url, redirect, alias)
+	}
+}
+
 func TestRedirectNegative(t *testing.T) {
 	cases := []testData{
 		{
@@ -59,23 +134,46 @@ func TestRedirectNegative(t *testing.T) {
 			alias:     "qwe",
 			url:       "http://qwe.ru",
 			respError: redirect.ErrMsgRedirectNoAlias,
+			respCode:  response.CodeNotFound,
 			mockError: storage.ErrURLNotFound,
 		},
+		{
+			caseName:  "Url on alias expired",
+			alias:     "expired",
+			respError: redirect.ErrMsgURLExpired,
+			respCode:  response.CodeExpired,
+			mockError: storage.ErrURLExpired,
+		},
+		{
+			caseName:  "Storage timeout",
+			alias:     "slow",
+			respError: response.ErrMsgStorageTimeout,
+			respCode:  response.CodeStorageTimeout,
+			mockError: context.DeadlineExceeded,
+		},
+		{
+			caseName:  "Storage unavailable",
+			alias:     "busy",
+			respError: response.ErrMsgStorageUnavailable,
+			respCode:  response.CodeStorageUnavailable,
+			mockError: storage.ErrUnavailable,
+		},
 	}
 
 	for _, testCase := range cases {
 		t.Run(testCase.caseName, func(t *testing.T) {
 			urlGetterMock := mocks.NewURLGetter(t)
-			ctx := context.Background()
-			urlGetterMock.On("GetURLByAlias", ctx, testCase.alias).Return(testCase.url, testCase.mockError).Once()
+			urlGetterMock.On("GetURLByAlias", mock.Anything, testCase.alias).Return(testCase.url, testCase.mockError).Once()
+			clickRecorderMock := mocks.NewClickRecorder(t)
 			r := chi.NewRouter()
-			r.Get("/{alias}", redirect.New(ctx, slogdiscard.NewDiscardLogger(), urlGetterMock))
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newRules(t, false), clickRecorderMock, time.Second))
 			server := httptest.NewServer(r)
 			defer server.Close()
 
 			resp, err := api.SendGet(server.URL + "/" + testCase.alias)
 			require.NoError(t, err)
 			assert.Equal(t, resp.Error, testCase.respError)
+			assert.Equal(t, testCase.respCode, resp.Code)
 
 		})
 	}
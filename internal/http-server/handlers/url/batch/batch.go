@@ -0,0 +1,268 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgNoItems         = "items must not be empty"
+	ErrMsgTooManyItems    = "too many items in one request"
+	ErrMsgBatchAborted    = "batch was not saved because some items failed"
+	ErrMsgItemAborted     = "not saved because another item of the batch failed"
+	ErrMsgFailedSaveBatch = "failed to save urls"
+)
+
+type Request struct {
+	Items []save.Request `json:"items"`
+	// Atomic - сохранить либо все ссылки, либо ни одной.
+	// По умолчанию сохраняются все корректные ссылки.
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// ItemResult результат сохранения одной ссылки. Index - ее
+// позиция в запросе, ошибки описываются так же, как в POST /url.
+type ItemResult struct {
+	response.Response
+	Index     int        `json:"index"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Existing - вернулась уже существующая ссылка, см. POST /url
+	Existing bool `json:"existing,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	Saved  int          `json:"saved"`
+	Failed int          `json:"failed"`
+	Items  []ItemResult `json:"items,omitempty"`
+}
+
+type URLBatchSaver interface {
+	save.URLIdReserver
+	save.URLFinder
+	SaveURLs(ctx context.Context, urls []storage.URLToSave, atomic bool) ([]storage.SaveResult, error)
+}
+
+// New сохраняет до maxItems ссылок за один запрос в одной транзакции.
+// Каждая ссылка проверяется по тем же правилам, что и в POST /url, и
+// получает свой результат. Если атомарную пачку не удалось сохранить
+// целиком, возвращается 422 и не сохраняется ни одна ссылка. Занятые
+// сгенерированные алиасы заменяются новыми, как в POST /url. Дедупликация
+// работает для каждой ссылки так же, как в POST /url, по умолчанию через dedupe.
+func New(log *slog.Logger, urlBatchSaver URLBatchSaver, aliases *random.Aliases, rules *aliasrule.Rules,
+	normalizer *urlnorm.Normalizer, dedupe bool, maxItems int, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.batch.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var request Request
+		err := render.DecodeJSON(r.Body, &request)
+		if err != nil {
+			log.Error("failed to decode request body", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "failed to decode request"))
+			return
+		}
+
+		if len(request.Items) == 0 {
+			log.Info("empty batch")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeValidation, ErrMsgNoItems))
+			return
+		}
+		if len(request.Items) > maxItems {
+			log.Info("batch is too big", slog.Int("items", len(request.Items)), slog.Int("max_items", maxItems))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error(response.CodeValidation, ErrMsgTooManyItems))
+			return
+		}
+		log.Info("request body decoded", slog.Int("items", len(request.Items)), slog.Bool("atomic", request.Atomic))
+
+		// Хендлер вызывается только после middleware auth
+		principal, _ := auth.PrincipalFrom(r.Context())
+		createdBy := principal.Name
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+
+		results := make([]ItemResult, len(request.Items))
+		// prepared ссылки, которые нужно сохранить, positions - их индексы в запросе
+		prepared := make([]save.Prepared, 0, len(request.Items))
+		positions := make([]int, 0, len(request.Items))
+		invalid := 0
+		for i, item := range request.Items {
+			results[i].Index = i
+			urlToSave, errResp := save.Prepare(item, createdBy, aliases, rules, normalizer)
+			if errResp != nil {
+				results[i].Response = *errResp
+				invalid++
+				continue
+			}
+			record, err := save.FindExisting(ctx, urlBatchSaver, item, urlToSave, dedupe)
+			if err == nil {
+				results[i].Response = response.OK()
+				results[i].Alias = record.Alias
+				results[i].Existing = true
+				continue
+			}
+			if !errors.Is(err, storage.ErrURLNotFound) {
+				log.Error(ErrMsgFailedSaveBatch, xslog.Err(err))
+				response.RenderStorageError(w, r, err, ErrMsgFailedSaveBatch)
+				return
+			}
+			prepared = append(prepared, urlToSave)
+			positions = append(positions, i)
+		}
+
+		saveResults := make([]storage.SaveResult, len(prepared))
+		if request.Atomic && invalid > 0 {
+			// Пачка все равно не сохранится, хранилище не нужно
+			storage.AbortSaveResults(saveResults)
+		} else if len(prepared) > 0 {
+			saveResults, err = saveWithRetry(ctx, urlBatchSaver, rules, aliases.Retries(), prepared, request.Atomic)
+			if err != nil {
+				log.Error(ErrMsgFailedSaveBatch, xslog.Err(err))
+				response.RenderStorageError(w, r, err, ErrMsgFailedSaveBatch)
+				return
+			}
+		}
+
+		for j, saveResult := range saveResults {
+			result := &results[positions[j]]
+			switch {
+			case saveResult.Err == nil:
+				result.Response = response.OK()
+				result.Alias = prepared[j].Alias
+				result.ExpiresAt = prepared[j].ExpiresAt
+			case errors.Is(saveResult.Err, storage.ErrAliasExists) && prepared[j].AliasGenerated:
+				result.Response = response.Error(response.CodeNoFreeAlias, save.ErrMsgNoFreeAlias)
+			case errors.Is(saveResult.Err, storage.ErrAliasExists):
+				result.Response = response.Error(response.CodeAliasExists, save.ErrMsgAliasExists)
+				result.Alias = prepared[j].Alias
+			case errors.Is(saveResult.Err, storage.ErrBatchAborted):
+				result.Response = response.Error(response.CodeBatchAborted, ErrMsgItemAborted)
+			default:
+				result.Response = response.Error(response.CodeInternal, save.ErrMsgFailedAddUrl)
+			}
+		}
+
+		resp := Response{Response: response.OK(), Items: results}
+		for _, result := range results {
+			if result.Status == response.StatusOK {
+				resp.Saved++
+			} else {
+				resp.Failed++
+			}
+		}
+
+		if request.Atomic && resp.Failed > 0 {
+			log.Info("batch aborted", slog.Int("failed", resp.Failed))
+			resp.Response = response.Error(response.CodeBatchAborted, ErrMsgBatchAborted)
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		log.Info("urls added", slog.Int("saved", resp.Saved), slog.Int("failed", resp.Failed))
+		render.JSON(w, r, resp)
+	}
+}
+
+// saveWithRetry сохраняет пачку, генерируя алиасы ссылкам без алиаса.
+// Ссылки, сгенерированный алиас которых оказался занят, получают новые
+// алиасы и сохраняются еще раз, всего до retries раз. Атомарная пачка
+// при этом сохраняется заново целиком, если в ней не занят ни один
+// алиас, заданный пользователем.
+func saveWithRetry(ctx context.Context, urlBatchSaver URLBatchSaver, rules *aliasrule.Rules, retries int, urls []save.Prepared,
+	atomic bool) ([]storage.SaveResult, error) {
+	generated := make([]*save.Prepared, 0, len(urls))
+	for i := range urls {
+		if urls[i].AliasGenerated {
+			generated = append(generated, &urls[i])
+		}
+	}
+	if err := save.GenerateAliases(ctx, urlBatchSaver, rules, generated); err != nil {
+		return nil, err
+	}
+
+	results, err := urlBatchSaver.SaveURLs(ctx, toSave(urls), atomic)
+	for attempt := 0; err == nil; attempt++ {
+		// collided индексы ссылок с занятым сгенерированным алиасом
+		var collided []int
+		customExists := false
+		for i, result := range results {
+			if !errors.Is(result.Err, storage.ErrAliasExists) {
+				continue
+			}
+			if urls[i].AliasGenerated {
+				collided = append(collided, i)
+				urls[i].Generator.Collided()
+			} else {
+				customExists = true
+			}
+		}
+		if len(collided) == 0 || attempt >= retries || (atomic && customExists) {
+			break
+		}
+
+		regenerated := make([]*save.Prepared, 0, len(collided))
+		for _, i := range collided {
+			regenerated = append(regenerated, &urls[i])
+		}
+		if err = save.GenerateAliases(ctx, urlBatchSaver, rules, regenerated); err != nil {
+			break
+		}
+
+		if atomic {
+			results, err = urlBatchSaver.SaveURLs(ctx, toSave(urls), true)
+			continue
+		}
+		retry := make([]storage.URLToSave, 0, len(regenerated))
+		for _, prepared := range regenerated {
+			retry = append(retry, prepared.URLToSave)
+		}
+		var retryResults []storage.SaveResult
+		retryResults, err = urlBatchSaver.SaveURLs(ctx, retry, false)
+		if err != nil {
+			break
+		}
+		for j, i := range collided {
+			results[i] = retryResults[j]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		if result.Err == nil && urls[i].AliasGenerated {
+			urls[i].Generator.Saved()
+		}
+	}
+	return results, nil
+}
+
+func toSave(urls []save.Prepared) []storage.URLToSave {
+	result := make([]storage.URLToSave, 0, len(urls))
+	for _, prepared := range urls {
+		result = append(result, prepared.URLToSave)
+	}
+	return result
+}
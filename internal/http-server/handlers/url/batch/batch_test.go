@@ -0,0 +1,265 @@
+//go:build smoke
+
+package batch_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/batch"
+	"url-shortener/internal/http-server/handlers/url/batch/mocks"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const maxItems = 3
+
+func doBatch(t *testing.T, urlBatchSaver batch.URLBatchSaver, request batch.Request) (int, batch.Response) {
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	aliases, err := random.NewAliases(random.AliasesOptions{
+		Strategy:         random.StrategyRandom,
+		Retries:          1,
+		Random:           random.Options{Alphabet: random.DefaultAlphabet, Length: 6, MaxLength: 8, GrowAfter: 3},
+		HashidsMinLength: 6,
+	})
+	require.NoError(t, err)
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 3, MaxLength: 16})
+	require.NoError(t, err)
+	handler := batch.New(slogdiscard.NewDiscardLogger(), urlBatchSaver, aliases, rules, urlnorm.New(urlnorm.DefaultStripParams), false, maxItems, time.Second)
+	req := httptest.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader(body))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: "admin"}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp batch.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return rr.Code, resp
+}
+
+// itemCodes возвращает коды результатов по порядку, "" - ссылка сохранена.
+func itemCodes(resp batch.Response) []string {
+	codes := make([]string, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		codes = append(codes, item.Code)
+	}
+	return codes
+}
+
+// TestBatch проверяет результаты сохранения пачки ссылок.
+func TestBatch(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		request     batch.Request
+		callMock    bool
+		wantToSave  int
+		mockResults []storage.SaveResult
+		mockErr     error
+		httpStatus  int
+		respCode    string
+		saved       int
+		codes       []string
+	}{
+		{
+			caseName: "Best effort",
+			request: batch.Request{Items: []save.Request{
+				{URL: "http://qwe.ru", Alias: "qwe"},
+				{URL: "not a url"},
+				{URL: "http://asd.ru", Alias: "taken"},
+			}},
+			callMock:    true,
+			wantToSave:  2,
+			mockResults: []storage.SaveResult{{Id: 1}, {Err: storage.ErrAliasExists}},
+			httpStatus:  http.StatusOK,
+			saved:       1,
+			codes:       []string{"", response.CodeValidation, response.CodeAliasExists},
+		},
+		{
+			caseName: "Atomic with invalid item",
+			request: batch.Request{Atomic: true, Items: []save.Request{
+				{URL: "http://qwe.ru"},
+				{URL: "http://asd.ru", TTL: 60, ExpiresAt: &time.Time{}},
+			}},
+			httpStatus: http.StatusUnprocessableEntity,
+			respCode:   response.CodeBatchAborted,
+			codes:      []string{response.CodeBatchAborted, response.CodeValidation},
+		},
+		{
+			caseName: "Atomic with taken alias",
+			request: batch.Request{Atomic: true, Items: []save.Request{
+				{URL: "http://qwe.ru"},
+				{URL: "http://asd.ru", Alias: "taken"},
+			}},
+			callMock:    true,
+			wantToSave:  2,
+			mockResults: []storage.SaveResult{{Err: storage.ErrBatchAborted}, {Err: storage.ErrAliasExists}},
+			httpStatus:  http.StatusUnprocessableEntity,
+			respCode:    response.CodeBatchAborted,
+			codes:       []string{response.CodeBatchAborted, response.CodeAliasExists},
+		},
+		{
+			caseName:   "Empty batch",
+			request:    batch.Request{},
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName: "Too many items",
+			request: batch.Request{Items: []save.Request{
+				{URL: "http://qwe.ru"}, {URL: "http://qwe.ru"}, {URL: "http://qwe.ru"}, {URL: "http://qwe.ru"},
+			}},
+			httpStatus: http.StatusRequestEntityTooLarge,
+			respCode:   response.CodeValidation,
+		},
+		{
+			caseName:   "Storage error",
+			request:    batch.Request{Items: []save.Request{{URL: "http://qwe.ru"}}},
+			callMock:   true,
+			wantToSave: 1,
+			mockErr:    errors.New("unexpected error"),
+			httpStatus: http.StatusInternalServerError,
+			respCode:   response.CodeInternal,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlBatchSaverMock := mocks.NewURLBatchSaver(t)
+			if tc.callMock {
+				urlBatchSaverMock.On("SaveURLs", mock.Anything, mock.MatchedBy(func(urls []storage.URLToSave) bool {
+					for _, urlToSave := range urls {
+						if urlToSave.Alias == "" || urlToSave.CreatedBy != "admin" {
+							return false
+						}
+					}
+					return len(urls) == tc.wantToSave
+				}), tc.request.Atomic).
+					Return(tc.mockResults, tc.mockErr).
+					Once()
+			}
+
+			status, resp := doBatch(t, urlBatchSaverMock, tc.request)
+			require.Equal(t, tc.httpStatus, status)
+			assert.Equal(t, tc.respCode, resp.Code)
+			assert.Equal(t, tc.saved, resp.Saved)
+			if tc.codes != nil {
+				assert.Equal(t, tc.codes, itemCodes(resp))
+				assert.Equal(t, len(tc.codes)-tc.saved, resp.Failed)
+			}
+		})
+	}
+}
+
+// TestBatchAliasRetry проверяет, что ссылки с занятым сгенерированным
+// алиасом сохраняются повторно с новым алиасом: без atomic - только
+// они, с atomic - вся пачка.
+func TestBatchAliasRetry(t *testing.T) {
+	items := []save.Request{{URL: "http://qwe.ru", Alias: "qwe"}, {URL: "http://asd.ru"}}
+	isBatch := func(size int) interface{} {
+		return mock.MatchedBy(func(urls []storage.URLToSave) bool { return len(urls) == size })
+	}
+	cases := []struct {
+		caseName   string
+		atomic     bool
+		retrySize  int
+		retry      []storage.SaveResult
+		httpStatus int
+		saved      int
+		codes      []string
+	}{
+		{
+			caseName:   "Best effort",
+			retrySize:  1,
+			retry:      []storage.SaveResult{{Id: 2}},
+			httpStatus: http.StatusOK,
+			saved:      2,
+			codes:      []string{"", ""},
+		},
+		{
+			caseName:   "Best effort without free alias",
+			retrySize:  1,
+			retry:      []storage.SaveResult{{Err: storage.ErrAliasExists}},
+			httpStatus: http.StatusOK,
+			saved:      1,
+			codes:      []string{"", response.CodeNoFreeAlias},
+		},
+		{
+			caseName:   "Atomic",
+			atomic:     true,
+			retrySize:  2,
+			retry:      []storage.SaveResult{{Id: 1}, {Id: 2}},
+			httpStatus: http.StatusOK,
+			saved:      2,
+			codes:      []string{"", ""},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			first := []storage.SaveResult{{Id: 1}, {Err: storage.ErrAliasExists}}
+			if tc.atomic {
+				first = []storage.SaveResult{{Err: storage.ErrBatchAborted}, {Err: storage.ErrAliasExists}}
+			}
+			urlBatchSaverMock := mocks.NewURLBatchSaver(t)
+			urlBatchSaverMock.On("SaveURLs", mock.Anything, isBatch(2), tc.atomic).Return(first, nil).Once()
+			urlBatchSaverMock.On("SaveURLs", mock.Anything, isBatch(tc.retrySize), tc.atomic).Return(tc.retry, nil).Once()
+
+			status, resp := doBatch(t, urlBatchSaverMock, batch.Request{Atomic: tc.atomic, Items: items})
+			require.Equal(t, tc.httpStatus, status)
+			assert.Equal(t, tc.saved, resp.Saved)
+			assert.Equal(t, tc.codes, itemCodes(resp))
+			assert.Equal(t, "qwe", resp.Items[0].Alias)
+		})
+	}
+}
+
+// TestBatchDedupe проверяет, что ссылки с dedupe получают существующий
+// сгенерированный алиас так же, как в POST /url, и не сохраняются заново.
+func TestBatchDedupe(t *testing.T) {
+	dedupe := true
+	items := []save.Request{
+		{URL: "http://qwe.ru/?utm_source=tg", Dedupe: &dedupe},
+		{URL: "http://asd.ru", Dedupe: &dedupe},
+		{URL: "http://zxc.ru", Alias: "zxc", Dedupe: &dedupe},
+	}
+
+	urlBatchSaverMock := mocks.NewURLBatchSaver(t)
+	urlBatchSaverMock.On("FindGeneratedURL", mock.Anything, "http://qwe.ru").
+		Return(storage.URLRecord{Id: 7, Alias: "old", URL: "http://qwe.ru"}, nil).Once()
+	urlBatchSaverMock.On("FindGeneratedURL", mock.Anything, "http://asd.ru").
+		Return(storage.URLRecord{}, storage.ErrURLNotFound).Once()
+	urlBatchSaverMock.On("SaveURLs", mock.Anything, mock.MatchedBy(func(urls []storage.URLToSave) bool { return len(urls) == 2 }), false).
+		Return([]storage.SaveResult{{Id: 8}, {Id: 9}}, nil).Once()
+
+	status, resp := doBatch(t, urlBatchSaverMock, batch.Request{Items: items})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, resp.Saved)
+	require.Len(t, resp.Items, 3)
+	assert.Equal(t, "old", resp.Items[0].Alias)
+	assert.True(t, resp.Items[0].Existing)
+	for _, item := range resp.Items[1:] {
+		assert.False(t, item.Existing)
+	}
+
+	failingMock := mocks.NewURLBatchSaver(t)
+	failingMock.On("FindGeneratedURL", mock.Anything, "http://qwe.ru").
+		Return(storage.URLRecord{}, storage.ErrUnavailable).Once()
+	status, resp = doBatch(t, failingMock, batch.Request{Items: items[:1]})
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, response.CodeStorageUnavailable, resp.Code)
+}
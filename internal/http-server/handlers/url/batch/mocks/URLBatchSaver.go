@@ -0,0 +1,117 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// URLBatchSaver is an autogenerated mock type for the URLBatchSaver type
+type URLBatchSaver struct {
+	mock.Mock
+}
+
+// FindGeneratedURL provides a mock function with given fields: ctx, canonicalURL
+func (_m *URLBatchSaver) FindGeneratedURL(ctx context.Context, canonicalURL string) (storage.URLRecord, error) {
+	ret := _m.Called(ctx, canonicalURL)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindGeneratedURL")
+	}
+
+	var r0 storage.URLRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (storage.URLRecord, error)); ok {
+		return rf(ctx, canonicalURL)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) storage.URLRecord); ok {
+		r0 = rf(ctx, canonicalURL)
+	} else {
+		r0 = ret.Get(0).(storage.URLRecord)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, canonicalURL)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReserveURLIds provides a mock function with given fields: ctx, n
+func (_m *URLBatchSaver) ReserveURLIds(ctx context.Context, n int) ([]int, error) {
+	ret := _m.Called(ctx, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReserveURLIds")
+	}
+
+	var r0 []int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]int, error)); ok {
+		return rf(ctx, n)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []int); ok {
+		r0 = rf(ctx, n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveURLs provides a mock function with given fields: ctx, urls, atomic
+func (_m *URLBatchSaver) SaveURLs(ctx context.Context, urls []storage.URLToSave, atomic bool) ([]storage.SaveResult, error) {
+	ret := _m.Called(ctx, urls, atomic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveURLs")
+	}
+
+	var r0 []storage.SaveResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.URLToSave, bool) ([]storage.SaveResult, error)); ok {
+		return rf(ctx, urls, atomic)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.URLToSave, bool) []storage.SaveResult); ok {
+		r0 = rf(ctx, urls, atomic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.SaveResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []storage.URLToSave, bool) error); ok {
+		r1 = rf(ctx, urls, atomic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLBatchSaver creates a new instance of URLBatchSaver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLBatchSaver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLBatchSaver {
+	mock := &URLBatchSaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -5,13 +5,14 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/jackc/pgx/v5"
 )
 
 type URLDeleter interface {
@@ -28,7 +29,7 @@ const (
 	ErrNothingToDelete = "nothing to delete"
 )
 
-func New(ctx context.Context, log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
+func New(log *slog.Logger, urlDeleter URLDeleter, queryTimeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const operationPlace = "handlers.url.delete.New"
 		log = log.With(
@@ -39,21 +40,25 @@ func New(ctx context.Context, log *slog.Logger, urlDeleter URLDeleter) http.Hand
 
 		if alias == "" {
 			log.Info("alias is empty")
-			render.JSON(w, r, response.Error("empty alias"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "empty alias"))
 			return
 		}
 
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
 		deletedId, err := urlDeleter.DeleteURLByAlias(ctx, alias)
 
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Error("no url on", "alias", alias)
-			render.JSON(w, r, response.Error("nothing to delete"))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrNothingToDelete))
 			return
 		}
 
 		if err != nil {
 			log.Error("failed to delete row", xslog.Err(err))
-			render.JSON(w, r, response.Error("failed to delete row"))
+			response.RenderStorageError(w, r, err, "failed to delete row")
 			return
 		}
 
@@ -9,14 +9,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 	"url-shortener/internal/http-server/handlers/url/delete"
 	"url-shortener/internal/http-server/handlers/url/delete/mocks"
 	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,12 +30,14 @@ func TestDeleteQuery(t *testing.T) {
 		respStatus string
 		respError  string
 		mockError  error
+		httpStatus int
 	}{
 		{
 			caseName:   "Success delete row",
 			alias:      "qwe",
 			deletedId:  1,
 			respStatus: response.StatusOK,
+			httpStatus: http.StatusOK,
 		},
 		{
 			caseName:   "No row with this alias",
@@ -41,16 +45,32 @@ func TestDeleteQuery(t *testing.T) {
 			deletedId:  0,
 			respStatus: response.StatusError,
 			respError:  "nothing to delete",
-			mockError:  pgx.ErrNoRows,
+			mockError:  storage.ErrURLNotFound,
+			httpStatus: http.StatusNotFound,
+		},
+		{
+			caseName:   "Storage timeout",
+			alias:      "qwe",
+			respStatus: response.StatusError,
+			respError:  response.ErrMsgStorageTimeout,
+			mockError:  context.DeadlineExceeded,
+			httpStatus: http.StatusGatewayTimeout,
+		},
+		{
+			caseName:   "Storage unavailable",
+			alias:      "qwe",
+			respStatus: response.StatusError,
+			respError:  response.ErrMsgStorageUnavailable,
+			mockError:  storage.ErrUnavailable,
+			httpStatus: http.StatusServiceUnavailable,
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
-			ctx := context.Background()
 			urlDeleterMock := mocks.NewURLDeleter(t)
-			urlDeleterMock.On("DeleteURLByAlias", ctx, tc.alias).Return(tc.deletedId, tc.mockError)
-			handler := delete.New(ctx, slogdiscard.NewDiscardLogger(), urlDeleterMock)
+			urlDeleterMock.On("DeleteURLByAlias", mock.Anything, tc.alias).Return(tc.deletedId, tc.mockError)
+			handler := delete.New(slogdiscard.NewDiscardLogger(), urlDeleterMock, time.Second)
 			r := chi.NewRouter()
 			r.Delete("/{alias}", handler)
 			ts := httptest.NewServer(r)
@@ -71,6 +91,7 @@ func TestDeleteQuery(t *testing.T) {
 			err = json.Unmarshal(body, &respBody)
 			require.NoError(t, err)
 
+			assert.Equal(t, tc.httpStatus, resp.StatusCode)
 			assert.Equal(t, tc.respStatus, respBody.Status)
 			assert.Equal(t, tc.deletedId, respBody.DeletedId)
 			assert.Equal(t, tc.respError, respBody.Error)
@@ -0,0 +1,108 @@
+package deletebyurl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/lookup"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgNothingToDelete  = "nothing to delete"
+	ErrMsgInvalidDryRun    = "dry_run must be true or false"
+	ErrMsgFailedDeleteURLs = "failed to delete urls"
+)
+
+type URLsByURLDeleter interface {
+	GetURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error)
+	DeleteURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error)
+}
+
+type Response struct {
+	response.Response
+	URL          string `json:"url,omitempty"`
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	DryRun       bool   `json:"dry_run,omitempty"`
+	// Items удаленные алиасы, а при dry_run - те, что были бы удалены
+	Items []lookup.Item `json:"items,omitempty"`
+}
+
+// New удаляет все алиасы, указывающие на URL из query-параметра url.
+// URL сравниваются в канонической форме, которую строит normalizer.
+// С dry_run=true ничего не удаляется, а возвращается то, что было бы удалено.
+func New(log *slog.Logger, urlsDeleter URLsByURLDeleter, normalizer *urlnorm.Normalizer, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.deletebyurl.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		query := r.URL.Query()
+		url := query.Get("url")
+
+		if url == "" {
+			log.Info("url is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, lookup.ErrMsgEmptyURL))
+			return
+		}
+
+		dryRun := false
+		if raw := query.Get("dry_run"); raw != "" {
+			var err error
+			dryRun, err = strconv.ParseBool(raw)
+			if err != nil {
+				log.Info("invalid dry_run", slog.String("dry_run", raw))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgInvalidDryRun))
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		canonicalURL := normalizer.Canonical(url)
+		var records []storage.URLRecord
+		var err error
+		if dryRun {
+			records, err = urlsDeleter.GetURLsByURL(ctx, canonicalURL)
+			if err == nil && len(records) == 0 {
+				err = storage.ErrURLNotFound
+			}
+		} else {
+			records, err = urlsDeleter.DeleteURLsByURL(ctx, canonicalURL)
+		}
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("no aliases for url", slog.String("url", url))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrMsgNothingToDelete))
+			return
+		}
+
+		if err != nil {
+			log.Error(ErrMsgFailedDeleteURLs, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedDeleteURLs)
+			return
+		}
+
+		log.Info("urls deleted by url", slog.String("url", url), slog.Int("count", len(records)), slog.Bool("dry_run", dryRun))
+		render.JSON(w, r, Response{
+			Response:     response.OK(),
+			URL:          url,
+			CanonicalURL: canonicalURL,
+			DryRun:       dryRun,
+			Items:        lookup.NewItems(records),
+		})
+	}
+}
@@ -0,0 +1,121 @@
+//go:build smoke
+
+package deletebyurl_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/deletebyurl"
+	"url-shortener/internal/http-server/handlers/url/deletebyurl/mocks"
+	"url-shortener/internal/http-server/handlers/url/lookup"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeleteByURL проверяет удаление всех алиасов URL,
+// в том числе в режиме dry_run.
+func TestDeleteByURL(t *testing.T) {
+	records := []storage.URLRecord{{Id: 1, Alias: "qwe"}, {Id: 2, Alias: "asd"}}
+	cases := []struct {
+		caseName    string
+		query       string
+		mockMethod  string
+		mockRecords []storage.URLRecord
+		mockError   error
+		httpStatus  int
+		respCode    string
+		respError   string
+		dryRun      bool
+		deleted     int
+	}{
+		{
+			caseName:    "Delete",
+			query:       "url=http://qwe.ru",
+			mockMethod:  "DeleteURLsByURL",
+			mockRecords: records,
+			httpStatus:  http.StatusOK,
+			deleted:     2,
+		},
+		{
+			caseName:    "Dry run",
+			query:       "url=HTTP://QWE.ru:80/&dry_run=true",
+			mockMethod:  "GetURLsByURL",
+			mockRecords: records,
+			httpStatus:  http.StatusOK,
+			dryRun:      true,
+			deleted:     2,
+		},
+		{
+			caseName:    "Dry run with nothing to delete",
+			query:       "url=http://qwe.ru&dry_run=1",
+			mockMethod:  "GetURLsByURL",
+			mockRecords: []storage.URLRecord{},
+			httpStatus:  http.StatusNotFound,
+			respCode:    response.CodeNotFound,
+			respError:   deletebyurl.ErrMsgNothingToDelete,
+		},
+		{
+			caseName:   "Nothing to delete",
+			query:      "url=http://qwe.ru",
+			mockMethod: "DeleteURLsByURL",
+			mockError:  storage.ErrURLNotFound,
+			httpStatus: http.StatusNotFound,
+			respCode:   response.CodeNotFound,
+			respError:  deletebyurl.ErrMsgNothingToDelete,
+		},
+		{
+			caseName:   "Empty url",
+			query:      "dry_run=true",
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeBadRequest,
+			respError:  lookup.ErrMsgEmptyURL,
+		},
+		{
+			caseName:   "Invalid dry_run",
+			query:      "url=http://qwe.ru&dry_run=maybe",
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeBadRequest,
+			respError:  deletebyurl.ErrMsgInvalidDryRun,
+		},
+		{
+			caseName:   "Storage error",
+			query:      "url=http://qwe.ru",
+			mockMethod: "DeleteURLsByURL",
+			mockError:  errors.New("unexpected error"),
+			httpStatus: http.StatusInternalServerError,
+			respCode:   response.CodeInternal,
+			respError:  deletebyurl.ErrMsgFailedDeleteURLs,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlsDeleterMock := mocks.NewURLsByURLDeleter(t)
+			if tc.mockMethod != "" {
+				urlsDeleterMock.On(tc.mockMethod, mock.Anything, "http://qwe.ru").Return(tc.mockRecords, tc.mockError).Once()
+			}
+
+			handler := deletebyurl.New(slogdiscard.NewDiscardLogger(), urlsDeleterMock, urlnorm.New(urlnorm.DefaultStripParams), time.Second)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/url/by-target?"+tc.query, nil))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp deletebyurl.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			assert.Equal(t, tc.respError, resp.Error)
+			assert.Equal(t, tc.dryRun, resp.DryRun)
+			assert.Len(t, resp.Items, tc.deleted)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// URLsByURLDeleter is an autogenerated mock type for the URLsByURLDeleter type
+type URLsByURLDeleter struct {
+	mock.Mock
+}
+
+// DeleteURLsByURL provides a mock function with given fields: ctx, canonicalURL
+func (_m *URLsByURLDeleter) DeleteURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	ret := _m.Called(ctx, canonicalURL)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteURLsByURL")
+	}
+
+	var r0 []storage.URLRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]storage.URLRecord, error)); ok {
+		return rf(ctx, canonicalURL)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []storage.URLRecord); ok {
+		r0 = rf(ctx, canonicalURL)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.URLRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, canonicalURL)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetURLsByURL provides a mock function with given fields: ctx, canonicalURL
+func (_m *URLsByURLDeleter) GetURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	ret := _m.Called(ctx, canonicalURL)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetURLsByURL")
+	}
+
+	var r0 []storage.URLRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]storage.URLRecord, error)); ok {
+		return rf(ctx, canonicalURL)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []storage.URLRecord); ok {
+		r0 = rf(ctx, canonicalURL)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.URLRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, canonicalURL)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLsByURLDeleter creates a new instance of URLsByURLDeleter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLsByURLDeleter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLsByURLDeleter {
+	mock := &URLsByURLDeleter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,103 @@
+package info
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/lib/api/etag"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgNoAlias       = "no url on this alias"
+	ErrMsgFailedGetInfo = "failed to get url"
+)
+
+type URLInfoGetter interface {
+	GetURLInfo(ctx context.Context, alias string) (storage.URLListItem, error)
+}
+
+type Flags struct {
+	// Expired срок жизни ссылки истек, редирект по ней не работает
+	Expired bool `json:"expired"`
+	// Generated алиас сгенерирован, а не задан при создании
+	Generated bool `json:"generated"`
+}
+
+type Response struct {
+	response.Response
+	Id        int        `json:"id,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int64      `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	Clicks    int        `json:"clicks"`
+	Flags     *Flags     `json:"flags,omitempty"`
+}
+
+// New возвращает всю информацию о ссылке без редиректа по ней.
+// Истекшие, но еще не удаленные ссылки тоже возвращаются.
+func New(log *slog.Logger, urlInfoGetter URLInfoGetter, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.info.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		alias := chi.URLParam(r, "alias")
+
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "empty alias"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		item, err := urlInfoGetter.GetURLInfo(ctx, alias)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("no url on this alias", "alias", alias)
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrMsgNoAlias))
+			return
+		}
+
+		if err != nil {
+			log.Error(ErrMsgFailedGetInfo, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedGetInfo)
+			return
+		}
+
+		log.Info("got url info", "alias", alias)
+		w.Header().Set("ETag", etag.Format(item.Version))
+		render.JSON(w, r, Response{
+			Response:  response.OK(),
+			Id:        item.Id,
+			Alias:     item.Alias,
+			URL:       item.URL,
+			ExpiresAt: item.ExpiresAt,
+			Version:   item.Version,
+			CreatedAt: &item.CreatedAt,
+			UpdatedAt: &item.UpdatedAt,
+			CreatedBy: item.CreatedBy,
+			Clicks:    item.Clicks,
+			Flags: &Flags{
+				Expired:   storage.IsExpired(item.ExpiresAt, time.Now()),
+				Generated: item.AliasGenerated,
+			},
+		})
+	}
+}
@@ -0,0 +1,101 @@
+//go:build smoke
+
+package info_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/info"
+	"url-shortener/internal/http-server/handlers/url/info/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInfo проверяет ответ хендлера информации о ссылке.
+func TestInfo(t *testing.T) {
+	past := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	cases := []struct {
+		caseName    string
+		mockItem    storage.URLListItem
+		mockError   error
+		httpStatus  int
+		respCode    string
+		respError   string
+		etag        string
+		wantExpired bool
+	}{
+		{
+			caseName: "Success",
+			mockItem: storage.URLListItem{
+				URLRecord: storage.URLRecord{Id: 7, Alias: "qwe", URL: "http://qwe.ru", Version: 3, CreatedBy: "admin", AliasGenerated: true},
+				Clicks:    5,
+			},
+			httpStatus: http.StatusOK,
+			etag:       `"3"`,
+		},
+		{
+			caseName: "Expired",
+			mockItem: storage.URLListItem{
+				URLRecord: storage.URLRecord{Id: 7, Alias: "qwe", URL: "http://qwe.ru", Version: 1, ExpiresAt: &past},
+			},
+			httpStatus:  http.StatusOK,
+			etag:        `"1"`,
+			wantExpired: true,
+		},
+		{
+			caseName:   "No url on alias",
+			mockError:  storage.ErrURLNotFound,
+			httpStatus: http.StatusNotFound,
+			respCode:   response.CodeNotFound,
+			respError:  info.ErrMsgNoAlias,
+		},
+		{
+			caseName:   "Storage error",
+			mockError:  errors.New("unexpected error"),
+			httpStatus: http.StatusInternalServerError,
+			respCode:   response.CodeInternal,
+			respError:  info.ErrMsgFailedGetInfo,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlInfoGetterMock := mocks.NewURLInfoGetter(t)
+			urlInfoGetterMock.On("GetURLInfo", mock.Anything, "qwe").Return(tc.mockItem, tc.mockError).Once()
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", info.New(slogdiscard.NewDiscardLogger(), urlInfoGetterMock, time.Second))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/qwe", nil))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			assert.Equal(t, tc.etag, rr.Header().Get("ETag"))
+
+			var resp info.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			assert.Equal(t, tc.respError, resp.Error)
+			if tc.httpStatus != http.StatusOK {
+				assert.Nil(t, resp.Flags)
+				return
+			}
+			assert.Equal(t, tc.mockItem.Id, resp.Id)
+			assert.Equal(t, tc.mockItem.URL, resp.URL)
+			assert.Equal(t, tc.mockItem.CreatedBy, resp.CreatedBy)
+			assert.Equal(t, tc.mockItem.Clicks, resp.Clicks)
+			require.NotNil(t, resp.Flags)
+			assert.Equal(t, tc.wantExpired, resp.Flags.Expired)
+			assert.Equal(t, tc.mockItem.AliasGenerated, resp.Flags.Generated)
+		})
+	}
+}
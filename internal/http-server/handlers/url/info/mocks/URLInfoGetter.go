@@ -0,0 +1,58 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// URLInfoGetter is an autogenerated mock type for the URLInfoGetter type
+type URLInfoGetter struct {
+	mock.Mock
+}
+
+// GetURLInfo provides a mock function with given fields: ctx, alias
+func (_m *URLInfoGetter) GetURLInfo(ctx context.Context, alias string) (storage.URLListItem, error) {
+	ret := _m.Called(ctx, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetURLInfo")
+	}
+
+	var r0 storage.URLListItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (storage.URLListItem, error)); ok {
+		return rf(ctx, alias)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) storage.URLListItem); ok {
+		r0 = rf(ctx, alias)
+	} else {
+		r0 = ret.Get(0).(storage.URLListItem)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLInfoGetter creates a new instance of URLInfoGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLInfoGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLInfoGetter {
+	mock := &URLInfoGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,224 @@
+package list
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+const (
+	ErrMsgInvalidLimit   = "limit must be a number from 1 to 500"
+	ErrMsgInvalidSort    = "sort must be one of: created, alias, clicks"
+	ErrMsgInvalidOrder   = "order must be asc or desc"
+	ErrMsgInvalidCursor  = "invalid cursor"
+	ErrMsgCursorMismatch = "cursor was issued for another sort or order"
+	ErrMsgInvalidDate    = "created_from and created_to must be RFC 3339 or YYYY-MM-DD"
+	ErrMsgFailedList     = "failed to list urls"
+)
+
+type URLLister interface {
+	ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.URLListItem, error)
+}
+
+type Item struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	Clicks    int        `json:"clicks"`
+}
+
+type Response struct {
+	response.Response
+	Items []Item `json:"items"`
+	// NextCursor передается в cursor для получения следующей
+	// страницы. Пустой - страниц больше нет.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursor содержимое непрозрачного курсора. Вместе с позицией
+// хранится сортировка, чтобы курсор нельзя было применить к другой.
+type cursor struct {
+	Sort  string             `json:"sort"`
+	Desc  bool               `json:"desc"`
+	After storage.ListCursor `json:"after"`
+}
+
+// New возвращает страницу ссылок. Query-параметры:
+// limit, cursor, sort (created, alias, clicks), order (asc, desc)
+// и фильтры url, domain, alias_prefix, created_from, created_to.
+func New(log *slog.Logger, urlLister URLLister, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.list.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		query := r.URL.Query()
+
+		opts, errMsg := parseOptions(query)
+		if errMsg != "" {
+			log.Info("invalid list options", slog.String("query", r.URL.RawQuery), slog.String("error", errMsg))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, errMsg))
+			return
+		}
+		limit := opts.Limit
+		// Одна лишняя запись показывает, есть ли следующая страница
+		opts.Limit++
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		items, err := urlLister.ListURLs(ctx, opts)
+		if err != nil {
+			log.Error(ErrMsgFailedList, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedList)
+			return
+		}
+
+		resp := Response{
+			Response: response.OK(),
+			Items:    make([]Item, 0, len(items)),
+		}
+		if len(items) > limit {
+			items = items[:limit]
+			resp.NextCursor = encodeCursor(cursor{
+				Sort:  opts.SortBy,
+				Desc:  opts.Desc,
+				After: items[len(items)-1].Cursor(),
+			})
+		}
+		for _, item := range items {
+			resp.Items = append(resp.Items, Item{
+				Alias:     item.Alias,
+				URL:       item.URL,
+				ExpiresAt: item.ExpiresAt,
+				Version:   item.Version,
+				CreatedAt: item.CreatedAt,
+				UpdatedAt: item.UpdatedAt,
+				CreatedBy: item.CreatedBy,
+				Clicks:    item.Clicks,
+			})
+		}
+
+		log.Info("urls listed", slog.Int("count", len(resp.Items)))
+		render.JSON(w, r, resp)
+	}
+}
+
+// parseOptions разбирает query-параметры. Вторым значением
+// возвращается текст ошибки для клиента.
+func parseOptions(query url.Values) (storage.ListOptions, string) {
+	get := query.Get
+	opts := storage.ListOptions{
+		Limit: DefaultLimit,
+		Filter: storage.ListFilter{
+			URLContains: get("url"),
+			Domain:      get("domain"),
+			AliasPrefix: get("alias_prefix"),
+		},
+	}
+
+	if raw := get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return opts, ErrMsgInvalidLimit
+		}
+		opts.Limit = limit
+	}
+
+	opts.SortBy = get("sort")
+	switch opts.SortBy {
+	case "":
+		opts.SortBy = storage.SortCreated
+	case storage.SortCreated, storage.SortAlias, storage.SortClicks:
+	default:
+		return opts, ErrMsgInvalidSort
+	}
+
+	// По умолчанию новые и популярные ссылки идут первыми, а алиасы по алфавиту
+	opts.Desc = opts.SortBy != storage.SortAlias
+	switch get("order") {
+	case "":
+	case OrderAsc:
+		opts.Desc = false
+	case OrderDesc:
+		opts.Desc = true
+	default:
+		return opts, ErrMsgInvalidOrder
+	}
+
+	for name, target := range map[string]**time.Time{
+		"created_from": &opts.Filter.CreatedFrom,
+		"created_to":   &opts.Filter.CreatedTo,
+	} {
+		raw := get(name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseDate(raw)
+		if err != nil {
+			return opts, ErrMsgInvalidDate
+		}
+		*target = &t
+	}
+
+	if raw := get("cursor"); raw != "" {
+		c, err := decodeCursor(raw)
+		if err != nil {
+			return opts, ErrMsgInvalidCursor
+		}
+		if c.Sort != opts.SortBy || c.Desc != opts.Desc {
+			return opts, ErrMsgCursorMismatch
+		}
+		opts.After = &c.After
+	}
+
+	return opts, ""
+}
+
+func parseDate(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
@@ -0,0 +1,157 @@
+//go:build smoke
+
+package list_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/list/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func doList(t *testing.T, urlLister list.URLLister, query string) (int, list.Response) {
+	handler := list.New(slogdiscard.NewDiscardLogger(), urlLister, time.Second)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url?"+query, nil))
+
+	var resp list.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return rr.Code, resp
+}
+
+func items(aliases ...string) []storage.URLListItem {
+	result := make([]storage.URLListItem, 0, len(aliases))
+	for i, alias := range aliases {
+		result = append(result, storage.URLListItem{URLRecord: storage.URLRecord{Id: i + 1, Alias: alias, URL: "http://" + alias + ".ru"}})
+	}
+	return result
+}
+
+// TestListOptions проверяет разбор query-параметров в storage.ListOptions.
+func TestListOptions(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		caseName string
+		query    string
+		want     func(opts storage.ListOptions) bool
+	}{
+		{
+			caseName: "Defaults",
+			query:    "",
+			want: func(opts storage.ListOptions) bool {
+				return opts.Limit == list.DefaultLimit+1 && opts.SortBy == storage.SortCreated && opts.Desc && opts.After == nil
+			},
+		},
+		{
+			caseName: "Alias sort is ascending by default",
+			query:    "sort=alias",
+			want: func(opts storage.ListOptions) bool {
+				return opts.SortBy == storage.SortAlias && !opts.Desc
+			},
+		},
+		{
+			caseName: "Explicit order",
+			query:    "sort=clicks&order=asc&limit=10",
+			want: func(opts storage.ListOptions) bool {
+				return opts.SortBy == storage.SortClicks && !opts.Desc && opts.Limit == 11
+			},
+		},
+		{
+			caseName: "Filters",
+			query:    "url=google&domain=example.com&alias_prefix=ab&created_from=2026-10-01&created_to=2026-10-18T12:00:00Z",
+			want: func(opts storage.ListOptions) bool {
+				f := opts.Filter
+				return f.URLContains == "google" && f.Domain == "example.com" && f.AliasPrefix == "ab" &&
+					f.CreatedFrom.Equal(from) && f.CreatedTo.Equal(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlListerMock := mocks.NewURLLister(t)
+			urlListerMock.On("ListURLs", mock.Anything, mock.MatchedBy(tc.want)).Return(items(), nil).Once()
+
+			status, resp := doList(t, urlListerMock, tc.query)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, response.StatusOK, resp.Status)
+			assert.Empty(t, resp.Items)
+			assert.Empty(t, resp.NextCursor)
+		})
+	}
+}
+
+// TestListInvalidOptions проверяет, что некорректные
+// параметры отклоняются без запроса к хранилищу.
+func TestListInvalidOptions(t *testing.T) {
+	cases := []struct {
+		caseName  string
+		query     string
+		respError string
+	}{
+		{"Zero limit", "limit=0", list.ErrMsgInvalidLimit},
+		{"Too big limit", "limit=501", list.ErrMsgInvalidLimit},
+		{"Unknown sort", "sort=url", list.ErrMsgInvalidSort},
+		{"Unknown order", "order=up", list.ErrMsgInvalidOrder},
+		{"Invalid date", "created_from=yesterday", list.ErrMsgInvalidDate},
+		{"Invalid cursor", "cursor=!!!", list.ErrMsgInvalidCursor},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			status, resp := doList(t, mocks.NewURLLister(t), tc.query)
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Equal(t, response.CodeBadRequest, resp.Code)
+			assert.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
+
+// TestListPagination проверяет, что курсор следующей страницы
+// указывает на последнюю отданную ссылку и не подходит к другой сортировке.
+func TestListPagination(t *testing.T) {
+	urlListerMock := mocks.NewURLLister(t)
+	urlListerMock.On("ListURLs", mock.Anything, mock.MatchedBy(func(opts storage.ListOptions) bool {
+		return opts.After == nil
+	})).Return(items("a", "b", "c"), nil).Once()
+
+	status, resp := doList(t, urlListerMock, "sort=alias&limit=2")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, resp.Items, 2)
+	assert.Equal(t, "a", resp.Items[0].Alias)
+	assert.Equal(t, "b", resp.Items[1].Alias)
+	require.NotEmpty(t, resp.NextCursor)
+
+	urlListerMock.On("ListURLs", mock.Anything, mock.MatchedBy(func(opts storage.ListOptions) bool {
+		return opts.After != nil && opts.After.Alias == "b" && opts.After.Id == 2
+	})).Return(items("c"), nil).Once()
+
+	status, resp = doList(t, urlListerMock, "sort=alias&limit=2&cursor="+resp.NextCursor)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, resp.Items, 1)
+	assert.Empty(t, resp.NextCursor)
+}
+
+// TestListCursorMismatch проверяет, что курсор нельзя
+// применить к другой сортировке.
+func TestListCursorMismatch(t *testing.T) {
+	urlListerMock := mocks.NewURLLister(t)
+	urlListerMock.On("ListURLs", mock.Anything, mock.Anything).Return(items("a", "b"), nil).Once()
+
+	_, resp := doList(t, urlListerMock, "sort=alias&limit=1")
+	require.NotEmpty(t, resp.NextCursor)
+
+	status, resp := doList(t, urlListerMock, "sort=created&limit=1&cursor="+resp.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, list.ErrMsgCursorMismatch, resp.Error)
+}
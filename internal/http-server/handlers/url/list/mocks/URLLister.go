@@ -0,0 +1,60 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
+
+// URLLister is an autogenerated mock type for the URLLister type
+type URLLister struct {
+	mock.Mock
+}
+
+// ListURLs provides a mock function with given fields: ctx, opts
+func (_m *URLLister) ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.URLListItem, error) {
+	ret := _m.Called(ctx, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListURLs")
+	}
+
+	var r0 []storage.URLListItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ListOptions) ([]storage.URLListItem, error)); ok {
+		return rf(ctx, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ListOptions) []storage.URLListItem); ok {
+		r0 = rf(ctx, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.URLListItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.ListOptions) error); ok {
+		r1 = rf(ctx, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLLister creates a new instance of URLLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLLister(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLLister {
+	mock := &URLLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
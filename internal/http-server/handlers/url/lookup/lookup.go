@@ -0,0 +1,98 @@
+package lookup
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgEmptyURL       = "url query parameter is required"
+	ErrMsgFailedFindURLs = "failed to find urls"
+)
+
+type URLsByURLGetter interface {
+	GetURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error)
+}
+
+// Item алиас, указывающий на искомый URL.
+type Item struct {
+	Id    int    `json:"id"`
+	Alias string `json:"alias"`
+	// URL адрес в том виде, в котором его сохранили
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	URL string `json:"url,omitempty"`
+	// CanonicalURL каноническая форма url, по которой шел поиск
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	Items        []Item `json:"items"`
+}
+
+// NewItems переводит ссылки из хранилища в элементы ответа.
+func NewItems(records []storage.URLRecord) []Item {
+	items := make([]Item, 0, len(records))
+	for _, record := range records {
+		items = append(items, Item{
+			Id:        record.Id,
+			Alias:     record.Alias,
+			URL:       record.URL,
+			ExpiresAt: record.ExpiresAt,
+			CreatedAt: record.CreatedAt,
+			CreatedBy: record.CreatedBy,
+		})
+	}
+	return items
+}
+
+// New возвращает все алиасы, указывающие на URL из query-параметра url.
+// URL сравниваются в канонической форме, которую строит normalizer.
+// Пустой список означает, что ссылок на URL нет.
+func New(log *slog.Logger, urlsGetter URLsByURLGetter, normalizer *urlnorm.Normalizer, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.lookup.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		url := r.URL.Query().Get("url")
+
+		if url == "" {
+			log.Info("url is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgEmptyURL))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		canonicalURL := normalizer.Canonical(url)
+		records, err := urlsGetter.GetURLsByURL(ctx, canonicalURL)
+		if err != nil {
+			log.Error(ErrMsgFailedFindURLs, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedFindURLs)
+			return
+		}
+
+		log.Info("urls found", slog.String("url", url), slog.String("canonical_url", canonicalURL), slog.Int("count", len(records)))
+		render.JSON(w, r, Response{
+			Response:     response.OK(),
+			URL:          url,
+			CanonicalURL: canonicalURL,
+			Items:        NewItems(records),
+		})
+	}
+}
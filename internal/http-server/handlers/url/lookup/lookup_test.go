@@ -0,0 +1,102 @@
+//go:build smoke
+
+package lookup_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/lookup"
+	"url-shortener/internal/http-server/handlers/url/lookup/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLookup проверяет поиск алиасов по URL.
+func TestLookup(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		url         string
+		canonical   string
+		callMock    bool
+		mockRecords []storage.URLRecord
+		mockError   error
+		httpStatus  int
+		respCode    string
+		respError   string
+		aliases     []string
+	}{
+		{
+			caseName:    "Found",
+			url:         "http://QWE.ru/?b=2&utm_source=tg&a=1",
+			canonical:   "http://qwe.ru?a=1&b=2",
+			callMock:    true,
+			mockRecords: []storage.URLRecord{{Id: 1, Alias: "qwe"}, {Id: 2, Alias: "asd"}},
+			httpStatus:  http.StatusOK,
+			aliases:     []string{"qwe", "asd"},
+		},
+		{
+			caseName:    "Not found",
+			url:         "http://qwe.ru",
+			canonical:   "http://qwe.ru",
+			callMock:    true,
+			mockRecords: []storage.URLRecord{},
+			httpStatus:  http.StatusOK,
+			aliases:     []string{},
+		},
+		{
+			caseName:   "Empty url",
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeBadRequest,
+			respError:  lookup.ErrMsgEmptyURL,
+		},
+		{
+			caseName:   "Storage error",
+			url:        "http://qwe.ru",
+			canonical:  "http://qwe.ru",
+			callMock:   true,
+			mockError:  errors.New("unexpected error"),
+			httpStatus: http.StatusInternalServerError,
+			respCode:   response.CodeInternal,
+			respError:  lookup.ErrMsgFailedFindURLs,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlsGetterMock := mocks.NewURLsByURLGetter(t)
+			if tc.callMock {
+				urlsGetterMock.On("GetURLsByURL", mock.Anything, tc.canonical).Return(tc.mockRecords, tc.mockError).Once()
+			}
+
+			handler := lookup.New(slogdiscard.NewDiscardLogger(), urlsGetterMock, urlnorm.New(urlnorm.DefaultStripParams), time.Second)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/by-target?url="+url.QueryEscape(tc.url), nil))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp lookup.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			assert.Equal(t, tc.respError, resp.Error)
+			if tc.aliases != nil {
+				aliases := []string{}
+				for _, item := range resp.Items {
+					aliases = append(aliases, item.Alias)
+				}
+				assert.Equal(t, tc.aliases, aliases)
+				assert.Equal(t, tc.url, resp.URL)
+				assert.Equal(t, tc.canonical, resp.CanonicalURL)
+			}
+		})
+	}
+}
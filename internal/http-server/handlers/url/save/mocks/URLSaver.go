@@ -1,4 +1,4 @@
-// Code generated by mockery v2.45.0. DO NOT EDIT.
+// Code generated by mockery v2.53.7. DO NOT EDIT.
 
 package mocks
 
@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
 )
 
 // URLSaver is an autogenerated mock type for the URLSaver type
@@ -13,9 +15,67 @@ type URLSaver struct {
 	mock.Mock
 }
 
-// SaveURL provides a mock function with given fields: ctx, urlToSave, alias
-func (_m *URLSaver) SaveURL(ctx context.Context, urlToSave string, alias string) (int, error) {
-	ret := _m.Called(ctx, urlToSave, alias)
+// FindGeneratedURL provides a mock function with given fields: ctx, canonicalURL
+func (_m *URLSaver) FindGeneratedURL(ctx context.Context, canonicalURL string) (storage.URLRecord, error) {
+	ret := _m.Called(ctx, canonicalURL)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindGeneratedURL")
+	}
+
+	var r0 storage.URLRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (storage.URLRecord, error)); ok {
+		return rf(ctx, canonicalURL)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) storage.URLRecord); ok {
+		r0 = rf(ctx, canonicalURL)
+	} else {
+		r0 = ret.Get(0).(storage.URLRecord)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, canonicalURL)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReserveURLIds provides a mock function with given fields: ctx, n
+func (_m *URLSaver) ReserveURLIds(ctx context.Context, n int) ([]int, error) {
+	ret := _m.Called(ctx, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReserveURLIds")
+	}
+
+	var r0 []int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]int, error)); ok {
+		return rf(ctx, n)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []int); ok {
+		r0 = rf(ctx, n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveURL provides a mock function with given fields: ctx, urlToSave
+func (_m *URLSaver) SaveURL(ctx context.Context, urlToSave storage.URLToSave) (int, error) {
+	ret := _m.Called(ctx, urlToSave)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SaveURL")
@@ -23,17 +83,17 @@ func (_m *URLSaver) SaveURL(ctx context.Context, urlToSave string, alias string)
 
 	var r0 int
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) (int, error)); ok {
-		return rf(ctx, urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.URLToSave) (int, error)); ok {
+		return rf(ctx, urlToSave)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
-		r0 = rf(ctx, urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.URLToSave) int); ok {
+		r0 = rf(ctx, urlToSave)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, urlToSave, alias)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.URLToSave) error); ok {
+		r1 = rf(ctx, urlToSave)
 	} else {
 		r1 = ret.Error(1)
 	}
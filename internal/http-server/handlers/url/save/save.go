@@ -5,9 +5,14 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/api/etag"
 	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/xslog"
 	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -16,24 +21,78 @@ import (
 )
 
 const (
-	ErrMsgFailedAddUrl = "failed add url"
+	ErrMsgFailedAddUrl     = "failed add url"
+	ErrMsgAliasExists      = "alias already exists"
+	ErrMsgExpiresAtInPast  = "expires_at must be in the future"
+	ErrMsgExpiresAtWithTTL = "only one of expires_at and ttl can be set"
+	ErrMsgNoFreeAlias      = "failed to generate a free alias, try again"
+	ErrMsgUnknownStrategy  = "unknown alias_strategy"
+	ErrMsgStrategyAndAlias = "only one of alias and alias_strategy can be set"
 )
 
 type Request struct {
 	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	Alias string `json:"alias,omitempty" validate:"omitempty,alias"`
+	// ExpiresAt момент, после которого ссылка перестанет работать
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TTL время жизни ссылки в секундах, не больше 100 лет
+	TTL int64 `json:"ttl,omitempty" validate:"gte=0,lte=3153600000"`
+	// Dedupe - вернуть существующий сгенерированный алиас, если
+	// на этот URL уже есть ссылка. Не передан - берется настройка сервера.
+	Dedupe *bool `json:"dedupe,omitempty"`
+	// AliasStrategy как сгенерировать алиас, если он не передан:
+	// random, base62, hashids или words. Не передан - стратегия сервера.
+	AliasStrategy string `json:"alias_strategy,omitempty"`
 }
 
 type Response struct {
 	response.Response
-	Alias string `json:"alias,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Existing - вернулась уже существующая ссылка
+	Existing bool `json:"existing,omitempty"`
+	// Suggestions свободные варианты занятого алиаса
+	Suggestions []string `json:"suggestions,omitempty"`
 }
 
 type URLSaver interface {
-	SaveURL(ctx context.Context, urlToSave string, alias string) (int, error)
+	URLIdReserver
+	URLFinder
+	SaveURL(ctx context.Context, urlToSave storage.URLToSave) (int, error)
 }
 
-func New(ctx context.Context, log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+// URLFinder ищет ссылку, которую можно вернуть вместо новой.
+type URLFinder interface {
+	FindGeneratedURL(ctx context.Context, canonicalURL string) (storage.URLRecord, error)
+}
+
+// AliasSuggester предлагает свободные варианты занятого алиаса.
+type AliasSuggester interface {
+	Suggest(ctx context.Context, alias string) ([]string, error)
+}
+
+// URLIdReserver резервирует url_id для алиасов, которые из него строятся.
+type URLIdReserver interface {
+	ReserveURLIds(ctx context.Context, n int) ([]int, error)
+}
+
+// Prepared проверенная ссылка, готовая к сохранению.
+type Prepared struct {
+	storage.URLToSave
+	// Generator генерирует алиас, если он не задан в запросе
+	Generator random.AliasGenerator
+}
+
+// New создает ссылку. Если алиас и срок жизни не переданы, а дедупликация
+// включена запросом или по умолчанию через dedupe, то для URL, на который
+// уже есть бессрочная ссылка со сгенерированным алиасом, вернется она.
+// URL сравниваются в канонической форме, которую строит normalizer.
+// Алиасы генерирует aliases по стратегии из запроса, занятый
+// сгенерированный алиас заменяется новым, пока не кончатся попытки.
+// Алиасы из запроса должны соответствовать rules, если такой алиас
+// занят, в ответе будут варианты от aliasSuggester.
+func New(log *slog.Logger, urlSaver URLSaver, aliases *random.Aliases, rules *aliasrule.Rules, aliasSuggester AliasSuggester,
+	normalizer *urlnorm.Normalizer, dedupe bool, queryTimeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const operationPlace = "handlers.url.save.New"
 		log = log.With(
@@ -45,43 +104,215 @@ func New(ctx context.Context, log *slog.Logger, urlSaver URLSaver) http.HandlerF
 		err := render.DecodeJSON(r.Body, &request)
 		if err != nil {
 			log.Error("failed to decode request body", xslog.Err(err))
-			render.JSON(w, r, response.Error("failed to decode request"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "failed to decode request"))
 			return
 		}
 		log.Info("request body decoded", slog.Any("request", request))
 
-		err = validator.New(validator.WithRequiredStructEnabled()).Struct(request)
-
-		if err != nil {
-			validationErrors := err.(validator.ValidationErrors)
-			log.Error("invalid request data", xslog.Err(err))
-			render.JSON(w, r, response.ValidationError(validationErrors))
+		// Хендлер вызывается только после middleware auth
+		principal, _ := auth.PrincipalFrom(r.Context())
+		createdBy := principal.Name
+		urlToSave, errResp := Prepare(request, createdBy, aliases, rules, normalizer)
+		if errResp != nil {
+			log.Info("invalid request data", slog.String("error", errResp.Error), slog.Any("details", errResp.Details))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, *errResp)
 			return
 		}
 
-		alias := request.Alias
-		if alias == "" {
-			alias = random.NewRandomString(random.DefaultStringLen)
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+
+		record, err := FindExisting(ctx, urlSaver, request, urlToSave, dedupe)
+		if err == nil {
+			log.Info("existing url returned", slog.Int("id", record.Id), slog.String("alias", record.Alias))
+			w.Header().Set("ETag", etag.Format(record.Version))
+			render.JSON(w, r, Response{
+				Response: response.OK(),
+				Alias:    record.Alias,
+				Existing: true,
+			})
+			return
 		}
+		if !errors.Is(err, storage.ErrURLNotFound) {
+			log.Error(ErrMsgFailedAddUrl, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedAddUrl)
+			return
+		}
+
+		id, err := saveWithRetry(ctx, urlSaver, rules, aliases.Retries(), &urlToSave)
 
-		id, err := urlSaver.SaveURL(ctx, request.URL, alias)
+		if errors.Is(err, storage.ErrAliasExists) && urlToSave.AliasGenerated {
+			log.Error("no free alias", slog.Int("retries", aliases.Retries()), slog.String("strategy", request.AliasStrategy))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, response.Error(response.CodeNoFreeAlias, ErrMsgNoFreeAlias))
+			return
+		}
 
 		if errors.Is(err, storage.ErrAliasExists) {
 			log.Info("alias already exists", "alias", request.Alias)
-			render.JSON(w, r, response.Error("alias already exists"))
+			// Без вариантов ответ все равно полезен, поэтому ошибка только логируется
+			suggestions, err := aliasSuggester.Suggest(ctx, request.Alias)
+			if err != nil {
+				log.Error("failed to suggest aliases", xslog.Err(err))
+			}
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, Response{
+				Response:    response.Error(response.CodeAliasExists, ErrMsgAliasExists),
+				Suggestions: suggestions,
+			})
 			return
 		}
 
 		if err != nil {
 			log.Error(ErrMsgFailedAddUrl, xslog.Err(err))
-			render.JSON(w, r, response.Error(ErrMsgFailedAddUrl))
+			response.RenderStorageError(w, r, err, ErrMsgFailedAddUrl)
 			return
 		}
 
 		log.Info("url added", slog.Int("id", id))
+		// Новая ссылка всегда имеет первую версию
+		w.Header().Set("ETag", etag.Format(1))
 		render.JSON(w, r, Response{
-			Response: response.OK(),
-			Alias:    alias,
+			Response:  response.OK(),
+			Alias:     urlToSave.Alias,
+			ExpiresAt: urlToSave.ExpiresAt,
 		})
 	}
 }
+
+// Prepare проверяет запрос на сохранение и собирает из него ссылку.
+// Алиас из запроса проверяется по rules, ключ алиаса тоже строит rules.
+// Если алиас не задан, выбирает для него генератор из aliases, сам алиас
+// подставляет GenerateAliases. Каноническую форму URL строит normalizer.
+// Если запрос некорректен, вторым значением возвращается ответ с ошибкой
+// для клиента.
+func Prepare(request Request, createdBy string, aliases *random.Aliases, rules *aliasrule.Rules,
+	normalizer *urlnorm.Normalizer) (Prepared, *response.Response) {
+	err := rules.Validator().Struct(request)
+	if err != nil {
+		resp := response.ValidationError(err.(validator.ValidationErrors))
+		return Prepared{}, &resp
+	}
+
+	if request.ExpiresAt != nil && request.TTL > 0 {
+		resp := response.Error(response.CodeValidation, ErrMsgExpiresAtWithTTL)
+		return Prepared{}, &resp
+	}
+
+	expiresAt := request.ExpiresAt
+	if request.TTL > 0 {
+		ttlExpiresAt := time.Now().Add(time.Duration(request.TTL) * time.Second)
+		expiresAt = &ttlExpiresAt
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		resp := response.Error(response.CodeValidation, ErrMsgExpiresAtInPast)
+		return Prepared{}, &resp
+	}
+
+	if request.Alias != "" && request.AliasStrategy != "" {
+		resp := response.Error(response.CodeValidation, ErrMsgStrategyAndAlias)
+		return Prepared{}, &resp
+	}
+
+	prepared := Prepared{URLToSave: storage.URLToSave{
+		URL:            request.URL,
+		Alias:          request.Alias,
+		ExpiresAt:      expiresAt,
+		CreatedBy:      createdBy,
+		AliasGenerated: request.Alias == "",
+		CanonicalURL:   normalizer.Canonical(request.URL),
+		AliasKey:       rules.Key(request.Alias),
+	}}
+	if prepared.AliasGenerated {
+		prepared.Generator, err = aliases.Generator(request.AliasStrategy)
+		if err != nil {
+			resp := response.Error(response.CodeValidation, ErrMsgUnknownStrategy)
+			return Prepared{}, &resp
+		}
+	}
+
+	return prepared, nil
+}
+
+// FindExisting возвращает бессрочную ссылку со сгенерированным алиасом
+// на тот же URL, что и prepared, если дедупликация включена запросом
+// или по умолчанию через dedupe, а алиас и срок жизни не переданы.
+// Если возвращать нечего, возвращается storage.ErrURLNotFound.
+func FindExisting(ctx context.Context, urlFinder URLFinder, request Request, prepared Prepared, dedupe bool) (storage.URLRecord, error) {
+	if request.Dedupe != nil {
+		dedupe = *request.Dedupe
+	}
+	if !dedupe || !prepared.AliasGenerated || prepared.ExpiresAt != nil {
+		return storage.URLRecord{}, storage.ErrURLNotFound
+	}
+	return urlFinder.FindGeneratedURL(ctx, prepared.CanonicalURL)
+}
+
+// GenerateAliases подставляет в urls новые сгенерированные алиасы,
+// пропуская зарезервированные в rules. url_id для генераторов, которым
+// он нужен, резервируется одним запросом.
+func GenerateAliases(ctx context.Context, reserver URLIdReserver, rules *aliasrule.Rules, urls []*Prepared) error {
+	for len(urls) > 0 {
+		reserve := 0
+		for _, prepared := range urls {
+			if prepared.Generator.UsesId() {
+				reserve++
+			}
+		}
+		var ids []int
+		if reserve > 0 {
+			var err error
+			ids, err = reserver.ReserveURLIds(ctx, reserve)
+			if err != nil {
+				return err
+			}
+		}
+
+		// reserved ссылки, получившие зарезервированный алиас. Алиас из
+		// url_id не изменится, поэтому им нужен новый url_id.
+		var reserved []*Prepared
+		for _, prepared := range urls {
+			prepared.Id = 0
+			if prepared.Generator.UsesId() {
+				prepared.Id, ids = ids[0], ids[1:]
+			}
+			prepared.Alias = prepared.Generator.NewAlias(prepared.Id)
+			prepared.AliasKey = rules.Key(prepared.Alias)
+			if rules.IsReserved(prepared.Alias) {
+				reserved = append(reserved, prepared)
+			}
+		}
+		urls = reserved
+	}
+	return nil
+}
+
+// saveWithRetry сохраняет ссылку, генерируя алиас, если он не задан.
+// Если сгенерированный алиас занят, генерируется новый, всего до
+// retries раз.
+func saveWithRetry(ctx context.Context, urlSaver URLSaver, rules *aliasrule.Rules, retries int, prepared *Prepared) (int, error) {
+	if !prepared.AliasGenerated {
+		return urlSaver.SaveURL(ctx, prepared.URLToSave)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := GenerateAliases(ctx, urlSaver, rules, []*Prepared{prepared}); err != nil {
+			return 0, err
+		}
+
+		id, err := urlSaver.SaveURL(ctx, prepared.URLToSave)
+		if !errors.Is(err, storage.ErrAliasExists) {
+			if err == nil {
+				prepared.Generator.Saved()
+			}
+			return id, err
+		}
+
+		prepared.Generator.Collided()
+		if attempt >= retries {
+			return 0, err
+		}
+	}
+}
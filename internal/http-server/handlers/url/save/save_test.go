@@ -10,23 +10,60 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 	"url-shortener/internal/http-server/handlers/url/save"
 	"url-shortener/internal/http-server/handlers/url/save/mocks"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliasrule"
 	"url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+func newAliases(t *testing.T) *random.Aliases {
+	aliases, err := random.NewAliases(random.AliasesOptions{
+		Strategy:         random.StrategyRandom,
+		Retries:          2,
+		Random:           random.Options{Alphabet: random.DefaultAlphabet, Length: 6, MaxLength: 8, GrowAfter: 3},
+		HashidsMinLength: 6,
+	})
+	require.NoError(t, err)
+	return aliases
+}
+
+func newRules(t *testing.T) *aliasrule.Rules {
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 3, MaxLength: 16, Reserved: []string{"url"}})
+	require.NoError(t, err)
+	return rules
+}
+
 func TestSaveHandler(t *testing.T) {
 	cases := []struct {
 		caseName    string
 		urlToSave   string
 		aliasForURL string
 		responseErr string
-		mockErr     error
+		// respCode ожидаемый код ошибки в ответе
+		respCode string
+		// httpStatus ожидаемый HTTP-статус, 0 - 200
+		httpStatus int
+		// details ожидаемые ошибки валидации полей
+		details   []response.FieldError
+		mockErr   error
+		ttl       int64
+		expiresAt string
+		expires   bool
+		// suggestions варианты, которые вернет AliasSuggester
+		suggestions   []string
+		suggestionErr error
 	}{
 		{
 			caseName:    "Success save",
@@ -45,40 +82,179 @@ func TestSaveHandler(t *testing.T) {
 		{
 			caseName:    "empty url",
 			urlToSave:   "",
-			aliasForURL: "empty url",
-			responseErr: fmt.Sprintf("%s %s", response.ErrMSgMissingRequiredField, "URL"),
+			aliasForURL: "empty",
+			responseErr: response.ErrMsgInvalidRequest,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+			details: []response.FieldError{
+				{Field: "url", Code: response.FieldCodeRequired, Message: response.ErrMSgMissingRequiredField + " url"},
+			},
+		},
+		{
+			caseName:    "invalid url",
+			urlToSave:   "not a url",
+			aliasForURL: "invalid",
+			responseErr: response.ErrMsgInvalidRequest,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+			details: []response.FieldError{
+				{Field: "url", Code: response.FieldCodeInvalidURL, Message: response.ErrMsgInvalidUrl + " url"},
+			},
+		},
+		{
+			caseName:    "Alias with slash",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "qwe/asd",
+			responseErr: response.ErrMsgInvalidRequest,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+			details: []response.FieldError{
+				{Field: "alias", Code: response.FieldCodeInvalidCharset, Message: response.ErrMsgInvalidCharset + " alias"},
+			},
+		},
+		{
+			caseName:    "Short alias",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "qw",
+			responseErr: response.ErrMsgInvalidRequest,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+			details: []response.FieldError{
+				{Field: "alias", Code: response.FieldCodeTooShort, Message: fmt.Sprintf(response.ErrMsgTooShort, "3") + " alias"},
+			},
+		},
+		{
+			caseName:    "Reserved alias",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "URL",
+			responseErr: response.ErrMsgInvalidRequest,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+			details: []response.FieldError{
+				{Field: "alias", Code: response.FieldCodeReserved, Message: response.ErrMsgReserved + " alias"},
+			},
+		},
+		{
+			caseName:    "With ttl",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "ttl",
+			ttl:         3600,
+			expires:     true,
+		},
+		{
+			caseName:    "With expires_at",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "expires",
+			expiresAt:   time.Now().Add(time.Hour).Format(time.RFC3339),
+			expires:     true,
+		},
+		{
+			caseName:    "expires_at in the past",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "past",
+			expiresAt:   time.Now().Add(-time.Hour).Format(time.RFC3339),
+			responseErr: save.ErrMsgExpiresAtInPast,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+		},
+		{
+			caseName:    "Too large ttl",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "forever",
+			ttl:         9300000000,
+			responseErr: response.ErrMsgInvalidRequest,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
+			details: []response.FieldError{
+				{Field: "ttl", Code: response.FieldCodeInvalid, Message: response.ErrMsgUnexpected + " ttl"},
+			},
+		},
+		{
+			caseName:    "Both ttl and expires_at",
+			urlToSave:   "http://test.ru",
+			aliasForURL: "both",
+			ttl:         3600,
+			expiresAt:   time.Now().Add(time.Hour).Format(time.RFC3339),
+			responseErr: save.ErrMsgExpiresAtWithTTL,
+			respCode:    response.CodeValidation,
+			httpStatus:  http.StatusBadRequest,
 		},
 		{
 			caseName:    "SaveURL error",
 			urlToSave:   "http://qwe.ru",
 			responseErr: save.ErrMsgFailedAddUrl,
+			respCode:    response.CodeInternal,
+			httpStatus:  http.StatusInternalServerError,
 			mockErr:     errors.New("unexpected error"),
 		},
+		{
+			caseName:    "Alias exists",
+			urlToSave:   "http://qwe.ru",
+			aliasForURL: "exists",
+			responseErr: save.ErrMsgAliasExists,
+			respCode:    response.CodeAliasExists,
+			httpStatus:  http.StatusConflict,
+			mockErr:     storage.ErrAliasExists,
+			suggestions: []string{"exists-2", "my-exists"},
+		},
+		{
+			caseName:      "Alias exists, suggestions failed",
+			urlToSave:     "http://qwe.ru",
+			aliasForURL:   "exists",
+			responseErr:   save.ErrMsgAliasExists,
+			respCode:      response.CodeAliasExists,
+			httpStatus:    http.StatusConflict,
+			mockErr:       storage.ErrAliasExists,
+			suggestionErr: storage.ErrUnavailable,
+		},
 	}
 
 	for _, testCase := range cases {
 		t.Run(testCase.caseName, func(t *testing.T) {
-			ctx := context.Background()
 			t.Parallel()
 
 			urlSaverMock := mocks.NewURLSaver(t)
 
 			if testCase.responseErr == "" || testCase.mockErr != nil {
-				urlSaverMock.On("SaveURL", ctx, testCase.urlToSave, mock.AnythingOfType("string")).
+				urlSaverMock.On("SaveURL", mock.Anything, mock.MatchedBy(func(urlToSave storage.URLToSave) bool {
+					return urlToSave.URL == testCase.urlToSave &&
+						urlToSave.CanonicalURL != "" &&
+						urlToSave.Alias != "" &&
+						urlToSave.AliasGenerated == (testCase.aliasForURL == "") &&
+						urlToSave.CreatedBy == "admin" &&
+						(urlToSave.ExpiresAt != nil) == testCase.expires
+				})).
 					Return(1, testCase.mockErr).
 					Once()
 			}
 
-			handler := save.New(ctx, slogdiscard.NewDiscardLogger(), urlSaverMock)
-			dataToRequest := fmt.Sprintf(`{"url":"%s", "alias":"%s"}`, testCase.urlToSave, testCase.aliasForURL)
+			suggesterMock := mocks.NewAliasSuggester(t)
+			if errors.Is(testCase.mockErr, storage.ErrAliasExists) {
+				suggesterMock.On("Suggest", mock.Anything, testCase.aliasForURL).Return(testCase.suggestions, testCase.suggestionErr).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliases(t), newRules(t), suggesterMock, urlnorm.New(urlnorm.DefaultStripParams), false, time.Second)
+			dataToRequest := fmt.Sprintf(`{"url":"%s", "alias":"%s"`, testCase.urlToSave, testCase.aliasForURL)
+			if testCase.ttl != 0 {
+				dataToRequest += fmt.Sprintf(`, "ttl":%d`, testCase.ttl)
+			}
+			if testCase.expiresAt != "" {
+				dataToRequest += fmt.Sprintf(`, "expires_at":"%s"`, testCase.expiresAt)
+			}
+			dataToRequest += "}"
 
 			request, err := http.NewRequest(http.MethodPost, "/url", bytes.NewReader([]byte(dataToRequest)))
 			require.NoError(t, err)
+			request = request.WithContext(auth.WithPrincipal(request.Context(), auth.Principal{Name: "admin"}))
 
 			rr := httptest.NewRecorder()
 			handler.ServeHTTP(rr, request)
 
-			require.Equal(t, rr.Code, http.StatusOK)
+			httpStatus := testCase.httpStatus
+			if httpStatus == 0 {
+				httpStatus = http.StatusOK
+			}
+			require.Equal(t, httpStatus, rr.Code)
 
 			body := rr.Body.String()
 
@@ -86,6 +262,285 @@ func TestSaveHandler(t *testing.T) {
 
 			require.NoError(t, json.Unmarshal([]byte(body), &response))
 			require.Equal(t, testCase.responseErr, response.Error)
+			require.Equal(t, testCase.respCode, response.Code)
+			require.Equal(t, testCase.details, response.Details)
+			require.Equal(t, testCase.suggestions, response.Suggestions)
+			if testCase.responseErr == "" {
+				require.Equal(t, testCase.expires, response.ExpiresAt != nil)
+			}
+		})
+	}
+}
+
+// TestSaveDedupe проверяет, что при дедупликации возвращается
+// существующая ссылка, а флаг запроса важнее настройки сервера.
+func TestSaveDedupe(t *testing.T) {
+	cases := []struct {
+		caseName     string
+		serverDedupe bool
+		body         string
+		callFind     bool
+		findErr      error
+		callSave     bool
+		wantAlias    string
+		wantExisting bool
+		wantETag     string
+		httpStatus   int
+	}{
+		{
+			caseName:     "Server default finds existing",
+			serverDedupe: true,
+			body:         `{"url":"http://qwe.ru"}`,
+			callFind:     true,
+			wantAlias:    "old",
+			wantExisting: true,
+			wantETag:     `"2"`,
+			httpStatus:   http.StatusOK,
+		},
+		{
+			caseName:     "Request flag enables dedupe",
+			body:         `{"url":"http://qwe.ru","dedupe":true}`,
+			callFind:     true,
+			wantAlias:    "old",
+			wantExisting: true,
+			wantETag:     `"2"`,
+			httpStatus:   http.StatusOK,
+		},
+		{
+			caseName:     "Request flag disables dedupe",
+			serverDedupe: true,
+			body:         `{"url":"http://qwe.ru","dedupe":false}`,
+			callSave:     true,
+			wantETag:     `"1"`,
+			httpStatus:   http.StatusOK,
+		},
+		{
+			caseName:     "Nothing to reuse",
+			serverDedupe: true,
+			body:         `{"url":"http://qwe.ru"}`,
+			callFind:     true,
+			findErr:      storage.ErrURLNotFound,
+			callSave:     true,
+			wantETag:     `"1"`,
+			httpStatus:   http.StatusOK,
+		},
+		{
+			caseName:     "Custom alias is not deduplicated",
+			serverDedupe: true,
+			body:         `{"url":"http://qwe.ru","alias":"new"}`,
+			callSave:     true,
+			wantAlias:    "new",
+			wantETag:     `"1"`,
+			httpStatus:   http.StatusOK,
+		},
+		{
+			caseName:     "Expiring url is not deduplicated",
+			serverDedupe: true,
+			body:         `{"url":"http://qwe.ru","ttl":60}`,
+			callSave:     true,
+			wantETag:     `"1"`,
+			httpStatus:   http.StatusOK,
+		},
+		{
+			caseName:     "Find error",
+			serverDedupe: true,
+			body:         `{"url":"http://qwe.ru"}`,
+			callFind:     true,
+			findErr:      errors.New("unexpected error"),
+			httpStatus:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlSaverMock := mocks.NewURLSaver(t)
+			if tc.callFind {
+				urlSaverMock.On("FindGeneratedURL", mock.Anything, "http://qwe.ru").
+					Return(storage.URLRecord{Id: 1, Alias: "old", Version: 2}, tc.findErr).
+					Once()
+			}
+			if tc.callSave {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).Return(2, nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliases(t), newRules(t), mocks.NewAliasSuggester(t), urlnorm.New(urlnorm.DefaultStripParams), tc.serverDedupe, time.Second)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(tc.body)))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.wantExisting, resp.Existing)
+			if tc.httpStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.wantETag, rr.Header().Get("ETag"))
+			if tc.wantAlias != "" {
+				assert.Equal(t, tc.wantAlias, resp.Alias)
+			}
+		})
+	}
+}
+
+// TestSaveAliasRetry проверяет, что занятый сгенерированный алиас
+// заменяется новым, пока не кончатся попытки, а занятый
+// пользовательский алиас сразу возвращает 409.
+func TestSaveAliasRetry(t *testing.T) {
+	cases := []struct {
+		caseName   string
+		body       string
+		collisions int
+		callSaved  bool
+		httpStatus int
+		respCode   string
+	}{
+		{
+			caseName:   "Free alias on retry",
+			body:       `{"url":"http://qwe.ru"}`,
+			collisions: 2,
+			callSaved:  true,
+			httpStatus: http.StatusOK,
+		},
+		{
+			caseName:   "No free alias",
+			body:       `{"url":"http://qwe.ru"}`,
+			collisions: 3,
+			httpStatus: http.StatusServiceUnavailable,
+			respCode:   response.CodeNoFreeAlias,
+		},
+		{
+			caseName:   "Custom alias is not retried",
+			body:       `{"url":"http://qwe.ru","alias":"taken"}`,
+			collisions: 1,
+			httpStatus: http.StatusConflict,
+			respCode:   response.CodeAliasExists,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlSaverMock := mocks.NewURLSaver(t)
+			var tried []string
+			urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					tried = append(tried, args.Get(1).(storage.URLToSave).Alias)
+				}).
+				Return(0, storage.ErrAliasExists).
+				Times(tc.collisions)
+			if tc.callSaved {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).Return(1, nil).Once()
+			}
+			suggesterMock := mocks.NewAliasSuggester(t)
+			if tc.respCode == response.CodeAliasExists {
+				suggesterMock.On("Suggest", mock.Anything, mock.Anything).Return(nil, nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliases(t), newRules(t), suggesterMock, urlnorm.New(nil), false, time.Second)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(tc.body)))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			if tc.callSaved {
+				assert.NotContains(t, tried, resp.Alias)
+			}
 		})
 	}
 }
+
+// TestSaveAliasStrategy проверяет выбор стратегии генерации алиаса
+// в запросе.
+func TestSaveAliasStrategy(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		body        string
+		reservedId  int
+		wantAlias   string
+		responseErr string
+	}{
+		{
+			caseName:   "Base62 uses reserved id",
+			body:       `{"url":"http://qwe.ru","alias_strategy":"base62"}`,
+			reservedId: 125,
+			wantAlias:  "21",
+		},
+		{
+			caseName:    "Unknown strategy",
+			body:        `{"url":"http://qwe.ru","alias_strategy":"uuid"}`,
+			responseErr: save.ErrMsgUnknownStrategy,
+		},
+		{
+			caseName:    "Strategy with alias",
+			body:        `{"url":"http://qwe.ru","alias":"qwe","alias_strategy":"words"}`,
+			responseErr: save.ErrMsgStrategyAndAlias,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlSaverMock := mocks.NewURLSaver(t)
+			if tc.responseErr == "" {
+				urlSaverMock.On("ReserveURLIds", mock.Anything, 1).Return([]int{tc.reservedId}, nil).Once()
+				urlSaverMock.On("SaveURL", mock.Anything, mock.MatchedBy(func(urlToSave storage.URLToSave) bool {
+					return urlToSave.Id == tc.reservedId && urlToSave.Alias == tc.wantAlias
+				})).Return(tc.reservedId, nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliases(t), newRules(t), mocks.NewAliasSuggester(t), urlnorm.New(nil), false, time.Second)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(tc.body)))
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			if tc.responseErr != "" {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, response.CodeValidation, resp.Code)
+				assert.Equal(t, tc.responseErr, resp.Error)
+				return
+			}
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.wantAlias, resp.Alias)
+		})
+	}
+}
+
+// TestGenerateAliasesSkipsReserved проверяет, что зарезервированный
+// алиас, построенный из url_id, заменяется алиасом из нового url_id.
+func TestGenerateAliasesSkipsReserved(t *testing.T) {
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 1, MaxLength: 16, Reserved: []string{"21"}})
+	require.NoError(t, err)
+	generator, err := newAliases(t).Generator(random.StrategyBase62)
+	require.NoError(t, err)
+
+	reserverMock := mocks.NewURLSaver(t)
+	reserverMock.On("ReserveURLIds", mock.Anything, 1).Return([]int{125}, nil).Once()
+	reserverMock.On("ReserveURLIds", mock.Anything, 1).Return([]int{126}, nil).Once()
+
+	prepared := save.Prepared{URLToSave: storage.URLToSave{AliasGenerated: true}, Generator: generator}
+	require.NoError(t, save.GenerateAliases(context.Background(), reserverMock, rules, []*save.Prepared{&prepared}))
+	assert.Equal(t, 126, prepared.Id)
+	assert.Equal(t, "22", prepared.Alias)
+}
+
+// TestSaveAliasKey проверяет, что ссылка сохраняется с ключом алиаса,
+// который строят правила.
+func TestSaveAliasKey(t *testing.T) {
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 3, MaxLength: 16, Fold: true})
+	require.NoError(t, err)
+
+	urlSaverMock := mocks.NewURLSaver(t)
+	urlSaverMock.On("SaveURL", mock.Anything, mock.MatchedBy(func(urlToSave storage.URLToSave) bool {
+		return urlToSave.Alias == "Promo10" && urlToSave.AliasKey == "promolo"
+	})).Return(0, storage.ErrAliasExists).Once()
+	suggesterMock := mocks.NewAliasSuggester(t)
+	suggesterMock.On("Suggest", mock.Anything, "Promo10").Return([]string{"Promo10-2"}, nil).Once()
+
+	handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliases(t), rules, suggesterMock, urlnorm.New(nil), false, time.Second)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"url":"http://qwe.ru","alias":"Promo10"}`)))
+
+	// Ключ занят похожим алиасом
+	require.Equal(t, http.StatusConflict, rr.Code)
+}
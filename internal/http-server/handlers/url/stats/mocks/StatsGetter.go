@@ -0,0 +1,60 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+
+	time "time"
+)
+
+// StatsGetter is an autogenerated mock type for the StatsGetter type
+type StatsGetter struct {
+	mock.Mock
+}
+
+// GetStats provides a mock function with given fields: ctx, alias, since, top
+func (_m *StatsGetter) GetStats(ctx context.Context, alias string, since time.Time, top int) (storage.Stats, error) {
+	ret := _m.Called(ctx, alias, since, top)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStats")
+	}
+
+	var r0 storage.Stats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, int) (storage.Stats, error)); ok {
+		return rf(ctx, alias, since, top)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, int) storage.Stats); ok {
+		r0 = rf(ctx, alias, since, top)
+	} else {
+		r0 = ret.Get(0).(storage.Stats)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, int) error); ok {
+		r1 = rf(ctx, alias, since, top)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewStatsGetter creates a new instance of StatsGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewStatsGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *StatsGetter {
+	mock := &StatsGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,141 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	DefaultDays = 30
+	MaxDays     = 366
+	DefaultTop  = 10
+	MaxTop      = 100
+)
+
+const (
+	ErrMsgEmptyAlias     = "empty alias"
+	ErrMsgNoAlias        = "no url on this alias"
+	ErrMsgInvalidDays    = "days must be a number from 1 to 366"
+	ErrMsgInvalidTop     = "top must be a number from 1 to 100"
+	ErrMsgFailedGetStats = "failed to get stats"
+)
+
+type StatsGetter interface {
+	GetStats(ctx context.Context, alias string, since time.Time, top int) (storage.Stats, error)
+}
+
+type DayClicks struct {
+	Day    string `json:"day"`
+	Clicks int    `json:"clicks"`
+}
+
+type ValueClicks struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+type Response struct {
+	response.Response
+	Alias         string        `json:"alias,omitempty"`
+	TotalClicks   int           `json:"total_clicks"`
+	ClicksPerDay  []DayClicks   `json:"clicks_per_day,omitempty"`
+	TopReferrers  []ValueClicks `json:"top_referrers,omitempty"`
+	TopUserAgents []ValueClicks `json:"top_user_agents,omitempty"`
+}
+
+// New возвращает статистику переходов по алиасу. Query-параметры:
+// days - за сколько последних дней показать переходы по дням,
+// top - сколько значений показать в топах referrer и user agent.
+func New(log *slog.Logger, statsGetter StatsGetter, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.stats.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		alias := chi.URLParam(r, "alias")
+
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgEmptyAlias))
+			return
+		}
+
+		days, ok := queryInt(r, "days", DefaultDays, MaxDays)
+		if !ok {
+			log.Info("invalid days", slog.String("days", r.URL.Query().Get("days")))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgInvalidDays))
+			return
+		}
+		top, ok := queryInt(r, "top", DefaultTop, MaxTop)
+		if !ok {
+			log.Info("invalid top", slog.String("top", r.URL.Query().Get("top")))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgInvalidTop))
+			return
+		}
+
+		since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		stats, err := statsGetter.GetStats(ctx, alias, since, top)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("no url on this alias", "alias", alias)
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrMsgNoAlias))
+			return
+		}
+
+		if err != nil {
+			log.Error(ErrMsgFailedGetStats, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedGetStats)
+			return
+		}
+
+		resp := Response{
+			Response:    response.OK(),
+			Alias:       alias,
+			TotalClicks: stats.TotalClicks,
+		}
+		for _, day := range stats.ClicksPerDay {
+			resp.ClicksPerDay = append(resp.ClicksPerDay, DayClicks{Day: day.Day.Format(time.DateOnly), Clicks: day.Clicks})
+		}
+		for _, referrer := range stats.TopReferrers {
+			resp.TopReferrers = append(resp.TopReferrers, ValueClicks(referrer))
+		}
+		for _, userAgent := range stats.TopUserAgents {
+			resp.TopUserAgents = append(resp.TopUserAgents, ValueClicks(userAgent))
+		}
+
+		log.Info("stats by alias", "alias", alias)
+		render.JSON(w, r, resp)
+	}
+}
+
+// queryInt читает из query целое число от 1 до max.
+// Если параметр не передан, возвращается def.
+func queryInt(r *http.Request, name string, def int, max int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > max {
+		return 0, false
+	}
+	return value, true
+}
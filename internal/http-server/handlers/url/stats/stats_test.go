@@ -0,0 +1,135 @@
+//go:build smoke
+
+package stats_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/stats/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStats проверяет разбор query-параметров и ответ хендлера статистики.
+func TestStats(t *testing.T) {
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		caseName   string
+		alias      string
+		query      string
+		top        int
+		callMock   bool
+		mockStats  storage.Stats
+		mockError  error
+		respStatus string
+		respError  string
+		httpStatus int
+		respTotal  int
+	}{
+		{
+			caseName: "Success with defaults",
+			alias:    "qwe",
+			top:      stats.DefaultTop,
+			callMock: true,
+			mockStats: storage.Stats{
+				TotalClicks:   3,
+				ClicksPerDay:  []storage.DayClicks{{Day: day, Clicks: 3}},
+				TopReferrers:  []storage.ValueClicks{{Value: "https://ya.ru", Clicks: 2}},
+				TopUserAgents: []storage.ValueClicks{{Value: "curl/8.0", Clicks: 3}},
+			},
+			respStatus: response.StatusOK,
+			httpStatus: http.StatusOK,
+			respTotal:  3,
+		},
+		{
+			caseName:   "Success with custom top",
+			alias:      "qwe",
+			query:      "?days=7&top=3",
+			top:        3,
+			callMock:   true,
+			respStatus: response.StatusOK,
+			httpStatus: http.StatusOK,
+		},
+		{
+			caseName:   "Invalid days",
+			alias:      "qwe",
+			query:      "?days=0",
+			respStatus: response.StatusError,
+			respError:  stats.ErrMsgInvalidDays,
+			httpStatus: http.StatusBadRequest,
+		},
+		{
+			caseName:   "Invalid top",
+			alias:      "qwe",
+			query:      "?top=abc",
+			respStatus: response.StatusError,
+			respError:  stats.ErrMsgInvalidTop,
+			httpStatus: http.StatusBadRequest,
+		},
+		{
+			caseName:   "No url on alias",
+			alias:      "qwe",
+			top:        stats.DefaultTop,
+			callMock:   true,
+			mockError:  storage.ErrURLNotFound,
+			respStatus: response.StatusError,
+			respError:  stats.ErrMsgNoAlias,
+			httpStatus: http.StatusNotFound,
+		},
+		{
+			caseName:   "Storage error",
+			alias:      "qwe",
+			top:        stats.DefaultTop,
+			callMock:   true,
+			mockError:  errors.New("unexpected error"),
+			respStatus: response.StatusError,
+			respError:  stats.ErrMsgFailedGetStats,
+			httpStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			statsGetterMock := mocks.NewStatsGetter(t)
+			if tc.callMock {
+				statsGetterMock.On("GetStats", mock.Anything, tc.alias, mock.AnythingOfType("time.Time"), tc.top).
+					Return(tc.mockStats, tc.mockError).Once()
+			}
+			r := chi.NewRouter()
+			r.Get("/{alias}/stats", stats.New(slogdiscard.NewDiscardLogger(), statsGetterMock, time.Second))
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			resp, err := http.Get(ts.URL + "/" + tc.alias + "/stats" + tc.query)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			var respBody stats.Response
+			require.NoError(t, json.Unmarshal(body, &respBody))
+
+			assert.Equal(t, tc.httpStatus, resp.StatusCode)
+			assert.Equal(t, tc.respStatus, respBody.Status)
+			assert.Equal(t, tc.respError, respBody.Error)
+			assert.Equal(t, tc.respTotal, respBody.TotalClicks)
+			if tc.respTotal > 0 {
+				assert.Equal(t, []stats.DayClicks{{Day: "2026-10-18", Clicks: 3}}, respBody.ClicksPerDay)
+				assert.Equal(t, "https://ya.ru", respBody.TopReferrers[0].Value)
+			}
+		})
+	}
+}
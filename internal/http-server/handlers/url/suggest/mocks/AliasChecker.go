@@ -0,0 +1,65 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AliasChecker is an autogenerated mock type for the AliasChecker type
+type AliasChecker struct {
+	mock.Mock
+}
+
+// Check provides a mock function with given fields: ctx, alias
+func (_m *AliasChecker) Check(ctx context.Context, alias string) (bool, []string, error) {
+	ret := _m.Called(ctx, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Check")
+	}
+
+	var r0 bool
+	var r1 []string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, []string, error)); ok {
+		return rf(ctx, alias)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, alias)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) []string); ok {
+		r1 = rf(ctx, alias)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, alias)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewAliasChecker creates a new instance of AliasChecker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAliasChecker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AliasChecker {
+	mock := &AliasChecker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,72 @@
+package suggest
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+const ErrMsgFailedCheckAlias = "failed to check alias"
+
+// AliasChecker проверяет, свободен ли алиас, и предлагает свободные варианты.
+type AliasChecker interface {
+	Check(ctx context.Context, alias string) (bool, []string, error)
+}
+
+// Request query-параметры запроса.
+type Request struct {
+	Alias string `json:"alias" validate:"required,alias"`
+}
+
+type Response struct {
+	response.Response
+	Alias string `json:"alias,omitempty"`
+	// Available - алиас из запроса свободен
+	Available   bool     `json:"available"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// New проверяет, свободен ли алиас из query-параметра alias, и
+// предлагает свободные варианты. Алиас должен соответствовать rules.
+func New(log *slog.Logger, aliasChecker AliasChecker, rules *aliasrule.Rules, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.suggest.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		request := Request{Alias: r.URL.Query().Get("alias")}
+		if err := rules.Validator().Struct(request); err != nil {
+			log.Info("invalid alias", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(err.(validator.ValidationErrors)))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		available, suggestions, err := aliasChecker.Check(ctx, request.Alias)
+		if err != nil {
+			log.Error(ErrMsgFailedCheckAlias, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedCheckAlias)
+			return
+		}
+
+		log.Info("alias checked", slog.String("alias", request.Alias), slog.Bool("available", available))
+		render.JSON(w, r, Response{
+			Response:    response.OK(),
+			Alias:       request.Alias,
+			Available:   available,
+			Suggestions: suggestions,
+		})
+	}
+}
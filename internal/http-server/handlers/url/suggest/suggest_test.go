@@ -0,0 +1,104 @@
+//go:build smoke
+
+package suggest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/suggest"
+	"url-shortener/internal/http-server/handlers/url/suggest/mocks"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSuggest проверяет ответ на проверку алиаса.
+func TestSuggest(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		alias       string
+		callMock    bool
+		available   bool
+		suggestions []string
+		mockError   error
+		httpStatus  int
+		respCode    string
+		fieldCode   string
+	}{
+		{
+			caseName:    "Taken",
+			alias:       "promo",
+			callMock:    true,
+			suggestions: []string{"promo-2", "my-promo"},
+			httpStatus:  http.StatusOK,
+		},
+		{
+			caseName:    "Available",
+			alias:       "sale",
+			callMock:    true,
+			available:   true,
+			suggestions: []string{"sale-2"},
+			httpStatus:  http.StatusOK,
+		},
+		{
+			caseName:   "Empty alias",
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			fieldCode:  response.FieldCodeRequired,
+		},
+		{
+			caseName:   "Reserved alias",
+			alias:      "url",
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			fieldCode:  response.FieldCodeReserved,
+		},
+		{
+			caseName:   "Storage unavailable",
+			alias:      "promo",
+			callMock:   true,
+			mockError:  storage.ErrUnavailable,
+			httpStatus: http.StatusServiceUnavailable,
+			respCode:   response.CodeStorageUnavailable,
+		},
+	}
+
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 3, MaxLength: 16, Reserved: []string{"url"}})
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			aliasCheckerMock := mocks.NewAliasChecker(t)
+			if tc.callMock {
+				aliasCheckerMock.On("Check", mock.Anything, tc.alias).Return(tc.available, tc.suggestions, tc.mockError).Once()
+			}
+
+			handler := suggest.New(slogdiscard.NewDiscardLogger(), aliasCheckerMock, rules, time.Second)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/suggest?alias="+url.QueryEscape(tc.alias), nil))
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			var resp suggest.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			if tc.fieldCode != "" {
+				require.Len(t, resp.Details, 1)
+				assert.Equal(t, "alias", resp.Details[0].Field)
+				assert.Equal(t, tc.fieldCode, resp.Details[0].Code)
+			}
+			if tc.httpStatus == http.StatusOK {
+				assert.Equal(t, tc.available, resp.Available)
+				assert.Equal(t, tc.suggestions, resp.Suggestions)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// URLUpdater is an autogenerated mock type for the URLUpdater type
+type URLUpdater struct {
+	mock.Mock
+}
+
+// UpdateURL provides a mock function with given fields: ctx, alias, _a2, expectedVersion
+func (_m *URLUpdater) UpdateURL(ctx context.Context, alias string, _a2 storage.URLUpdate, expectedVersion int64) (storage.URLRecord, error) {
+	ret := _m.Called(ctx, alias, _a2, expectedVersion)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateURL")
+	}
+
+	var r0 storage.URLRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.URLUpdate, int64) (storage.URLRecord, error)); ok {
+		return rf(ctx, alias, _a2, expectedVersion)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.URLUpdate, int64) storage.URLRecord); ok {
+		r0 = rf(ctx, alias, _a2, expectedVersion)
+	} else {
+		r0 = ret.Get(0).(storage.URLRecord)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.URLUpdate, int64) error); ok {
+		r1 = rf(ctx, alias, _a2, expectedVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewURLUpdater creates a new instance of URLUpdater. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLUpdater(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLUpdater {
+	mock := &URLUpdater{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,203 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/lib/api/etag"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ErrMsgNoAlias          = "no url on this alias"
+	ErrMsgFailedUpdateURL  = "failed to update url"
+	ErrMsgNothingToUpdate  = "nothing to update"
+	ErrMsgInvalidIfMatch   = "invalid If-Match header"
+	ErrMsgVersionMismatch  = "url was changed by someone else, fetch it again"
+	ErrMsgExpiresAtInPast  = "expires_at must be in the future"
+	ErrMsgExpiresAtWithTTL = "only one of expires_at and ttl can be set"
+)
+
+// OptionalTime время, для которого важно, было ли поле в запросе:
+// отсутствующее поле не меняет срок жизни, а null снимает его.
+type OptionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		o.Time = nil
+		return nil
+	}
+	var t time.Time
+	if err := t.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	o.Time = &t
+	return nil
+}
+
+type Request struct {
+	URL string `json:"url,omitempty" validate:"omitempty,url"`
+	// ExpiresAt новый срок жизни, null - сделать ссылку бессрочной
+	ExpiresAt OptionalTime `json:"expires_at"`
+	// TTL новое время жизни ссылки в секундах от текущего момента,
+	// не больше 100 лет
+	TTL int64 `json:"ttl,omitempty" validate:"gte=0,lte=3153600000"`
+}
+
+type Response struct {
+	response.Response
+	Alias     string     `json:"alias,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int64      `json:"version,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type URLUpdater interface {
+	UpdateURL(ctx context.Context, alias string, update storage.URLUpdate, expectedVersion int64) (storage.URLRecord, error)
+}
+
+// New меняет ссылку по алиасу. PATCH меняет только переданные поля,
+// PUT заменяет ссылку целиком: url обязателен, а без expires_at и ttl
+// ссылка становится бессрочной. Если передан If-Match, ссылка меняется
+// только когда ее ETag совпадает с ним, иначе вернется 412.
+// Каноническую форму нового URL строит normalizer.
+func New(log *slog.Logger, urlUpdater URLUpdater, normalizer *urlnorm.Normalizer, queryTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const operationPlace = "handlers.url.update.New"
+		log := log.With(
+			slog.String("op", operationPlace),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		alias := chi.URLParam(r, "alias")
+
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "empty alias"))
+			return
+		}
+
+		expectedVersion, err := etag.ParseIfMatch(r.Header.Get("If-Match"))
+		if err != nil {
+			log.Info("invalid If-Match", slog.String("if_match", r.Header.Get("If-Match")))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, ErrMsgInvalidIfMatch))
+			return
+		}
+
+		var request Request
+		err = render.DecodeJSON(r.Body, &request)
+		if err != nil {
+			log.Error("failed to decode request body", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeBadRequest, "failed to decode request"))
+			return
+		}
+		log.Info("request body decoded", slog.Any("request", request))
+
+		err = validate.New().Struct(request)
+		if err != nil {
+			validationErrors := err.(validator.ValidationErrors)
+			log.Error("invalid request data", xslog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validationErrors))
+			return
+		}
+
+		replace := r.Method == http.MethodPut
+		if replace && request.URL == "" {
+			log.Info("url is required for PUT")
+			resp := response.Error(response.CodeValidation, response.ErrMsgInvalidRequest)
+			resp.Details = []response.FieldError{
+				{Field: "url", Code: response.FieldCodeRequired, Message: response.ErrMSgMissingRequiredField + " url"},
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		if request.ExpiresAt.Set && request.TTL > 0 {
+			log.Info("both expires_at and ttl are set")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeValidation, ErrMsgExpiresAtWithTTL))
+			return
+		}
+
+		update := storage.URLUpdate{
+			URL:          request.URL,
+			SetExpiresAt: replace || request.ExpiresAt.Set || request.TTL > 0,
+			ExpiresAt:    request.ExpiresAt.Time,
+		}
+		if update.URL != "" {
+			update.CanonicalURL = normalizer.Canonical(update.URL)
+		}
+		if request.TTL > 0 {
+			ttlExpiresAt := time.Now().Add(time.Duration(request.TTL) * time.Second)
+			update.ExpiresAt = &ttlExpiresAt
+		}
+		if update.ExpiresAt != nil && !update.ExpiresAt.After(time.Now()) {
+			log.Info("expires_at in the past", slog.Time("expires_at", *update.ExpiresAt))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeValidation, ErrMsgExpiresAtInPast))
+			return
+		}
+		if update.URL == "" && !update.SetExpiresAt {
+			log.Info("nothing to update")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeValidation, ErrMsgNothingToUpdate))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+		defer cancel()
+		record, err := urlUpdater.UpdateURL(ctx, alias, update, expectedVersion)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("no url on this alias", "alias", alias)
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, ErrMsgNoAlias))
+			return
+		}
+
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			log.Info("version mismatch", "alias", alias, slog.Int64("expected_version", expectedVersion))
+			render.Status(r, http.StatusPreconditionFailed)
+			render.JSON(w, r, response.Error(response.CodeVersionMismatch, ErrMsgVersionMismatch))
+			return
+		}
+
+		if err != nil {
+			log.Error(ErrMsgFailedUpdateURL, xslog.Err(err))
+			response.RenderStorageError(w, r, err, ErrMsgFailedUpdateURL)
+			return
+		}
+
+		log.Info("url updated", "alias", alias, slog.Int64("version", record.Version))
+		w.Header().Set("ETag", etag.Format(record.Version))
+		render.JSON(w, r, Response{
+			Response:  response.OK(),
+			Alias:     record.Alias,
+			URL:       record.URL,
+			ExpiresAt: record.ExpiresAt,
+			Version:   record.Version,
+			UpdatedAt: &record.UpdatedAt,
+		})
+	}
+}
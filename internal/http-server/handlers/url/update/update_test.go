@@ -0,0 +1,227 @@
+//go:build smoke
+
+package update_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/handlers/url/update/mocks"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/lib/urlnorm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	cases := []struct {
+		caseName   string
+		method     string
+		body       string
+		ifMatch    string
+		callMock   bool
+		wantUpdate func(update storage.URLUpdate) bool
+		wantVer    int64
+		mockErr    error
+		httpStatus int
+		respCode   string
+		respError  string
+		etag       string
+	}{
+		{
+			caseName: "Patch url",
+			method:   http.MethodPatch,
+			body:     `{"url":"http://NEW.ru/?utm_source=tg"}`,
+			ifMatch:  `"1"`,
+			callMock: true,
+			wantUpdate: func(update storage.URLUpdate) bool {
+				return update.URL == "http://NEW.ru/?utm_source=tg" && update.CanonicalURL == "http://new.ru" && !update.SetExpiresAt
+			},
+			wantVer:    1,
+			httpStatus: http.StatusOK,
+			etag:       `"2"`,
+		},
+		{
+			caseName: "Patch expires_at",
+			method:   http.MethodPatch,
+			body:     `{"expires_at":"` + future.Format(time.RFC3339) + `"}`,
+			callMock: true,
+			wantUpdate: func(update storage.URLUpdate) bool {
+				return update.URL == "" && update.CanonicalURL == "" && update.SetExpiresAt && update.ExpiresAt.Equal(future)
+			},
+			httpStatus: http.StatusOK,
+			etag:       `"2"`,
+		},
+		{
+			caseName: "Patch null expires_at",
+			method:   http.MethodPatch,
+			body:     `{"expires_at":null}`,
+			callMock: true,
+			wantUpdate: func(update storage.URLUpdate) bool {
+				return update.SetExpiresAt && update.ExpiresAt == nil
+			},
+			httpStatus: http.StatusOK,
+			etag:       `"2"`,
+		},
+		{
+			caseName: "Patch ttl",
+			method:   http.MethodPatch,
+			body:     `{"ttl":3600}`,
+			callMock: true,
+			wantUpdate: func(update storage.URLUpdate) bool {
+				return update.SetExpiresAt && update.ExpiresAt != nil
+			},
+			httpStatus: http.StatusOK,
+			etag:       `"2"`,
+		},
+		{
+			caseName: "Put clears expires_at",
+			method:   http.MethodPut,
+			body:     `{"url":"http://new.ru"}`,
+			callMock: true,
+			wantUpdate: func(update storage.URLUpdate) bool {
+				return update.URL == "http://new.ru" && update.SetExpiresAt && update.ExpiresAt == nil
+			},
+			httpStatus: http.StatusOK,
+			etag:       `"2"`,
+		},
+		{
+			caseName:   "Put without url",
+			method:     http.MethodPut,
+			body:       `{"ttl":3600}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			respError:  response.ErrMsgInvalidRequest,
+		},
+		{
+			caseName:   "Empty patch",
+			method:     http.MethodPatch,
+			body:       `{}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			respError:  update.ErrMsgNothingToUpdate,
+		},
+		{
+			caseName:   "Invalid url",
+			method:     http.MethodPatch,
+			body:       `{"url":"not a url"}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			respError:  response.ErrMsgInvalidRequest,
+		},
+		{
+			caseName:   "Too large ttl",
+			method:     http.MethodPatch,
+			body:       `{"ttl":9300000000}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			respError:  response.ErrMsgInvalidRequest,
+		},
+		{
+			caseName:   "Both ttl and expires_at",
+			method:     http.MethodPatch,
+			body:       `{"ttl":3600,"expires_at":"` + future.Format(time.RFC3339) + `"}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			respError:  update.ErrMsgExpiresAtWithTTL,
+		},
+		{
+			caseName:   "Expires_at in the past",
+			method:     http.MethodPatch,
+			body:       `{"expires_at":"` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeValidation,
+			respError:  update.ErrMsgExpiresAtInPast,
+		},
+		{
+			caseName:   "Invalid If-Match",
+			method:     http.MethodPatch,
+			body:       `{"url":"http://new.ru"}`,
+			ifMatch:    `W/"1"`,
+			httpStatus: http.StatusBadRequest,
+			respCode:   response.CodeBadRequest,
+			respError:  update.ErrMsgInvalidIfMatch,
+		},
+		{
+			caseName:   "Version mismatch",
+			method:     http.MethodPatch,
+			body:       `{"url":"http://new.ru"}`,
+			ifMatch:    `"3"`,
+			callMock:   true,
+			wantVer:    3,
+			mockErr:    storage.ErrVersionMismatch,
+			httpStatus: http.StatusPreconditionFailed,
+			respCode:   response.CodeVersionMismatch,
+			respError:  update.ErrMsgVersionMismatch,
+		},
+		{
+			caseName:   "No url on alias",
+			method:     http.MethodPatch,
+			body:       `{"url":"http://new.ru"}`,
+			callMock:   true,
+			mockErr:    storage.ErrURLNotFound,
+			httpStatus: http.StatusNotFound,
+			respCode:   response.CodeNotFound,
+			respError:  update.ErrMsgNoAlias,
+		},
+		{
+			caseName:   "Storage error",
+			method:     http.MethodPatch,
+			body:       `{"url":"http://new.ru"}`,
+			callMock:   true,
+			mockErr:    errors.New("unexpected error"),
+			httpStatus: http.StatusInternalServerError,
+			respCode:   response.CodeInternal,
+			respError:  update.ErrMsgFailedUpdateURL,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			urlUpdaterMock := mocks.NewURLUpdater(t)
+			if tc.callMock {
+				wantUpdate := tc.wantUpdate
+				if wantUpdate == nil {
+					wantUpdate = func(storage.URLUpdate) bool { return true }
+				}
+				urlUpdaterMock.On("UpdateURL", mock.Anything, "qwe", mock.MatchedBy(wantUpdate), tc.wantVer).
+					Return(storage.URLRecord{Alias: "qwe", URL: "http://new.ru", Version: 2}, tc.mockErr).
+					Once()
+			}
+			r := chi.NewRouter()
+			handler := update.New(slogdiscard.NewDiscardLogger(), urlUpdaterMock, urlnorm.New(urlnorm.DefaultStripParams), time.Second)
+			r.Patch("/{alias}", handler)
+			r.Put("/{alias}", handler)
+
+			req := httptest.NewRequest(tc.method, "/qwe", strings.NewReader(tc.body))
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			assert.Equal(t, tc.etag, rr.Header().Get("ETag"))
+
+			var resp update.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+			assert.Equal(t, tc.respError, resp.Error)
+			if tc.httpStatus == http.StatusOK {
+				assert.Equal(t, int64(2), resp.Version)
+				assert.Equal(t, "http://new.ru", resp.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package auth проверяет учетные данные запросов к API.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/xslog"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgUnauthorized      = "unauthorized"
+	ErrMsgFailedCheckAPIKey = "failed to check api key"
+	ErrMsgMissingScope      = "api key has no scope "
+	ErrMsgUserOnly          = "available only to users"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	bearerPrefix = "Bearer "
+	// keyNamePrefix отличает в Principal.Name ключи от пользователей
+	keyNamePrefix = "key:"
+)
+
+// Authenticator проверяет пароль пользователя.
+type Authenticator interface {
+	Authenticate(name, password string) bool
+}
+
+// APIKeyAuthenticator возвращает действующий ключ API.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (storage.APIKey, error)
+}
+
+// Principal тот, от чьего имени выполняется запрос.
+type Principal struct {
+	// Name имя пользователя или "key:<имя ключа>"
+	Name string
+	// APIKeyId id ключа API, 0 - пользователь с basic auth
+	APIKeyId int
+	// Scopes права ключа API, пользователям разрешено все
+	Scopes []string
+}
+
+// IsUser запрос выполняет пользователь, а не ключ API.
+func (p Principal) IsUser() bool {
+	return p.APIKeyId == 0
+}
+
+// Allowed проверяет, есть ли у p право scope.
+func (p Principal) Allowed(scope string) bool {
+	return p.IsUser() || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст запроса от имени principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom возвращает того, от чьего имени выполняется запрос.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// New пропускает запросы с ключом API из keys в заголовке X-API-Key
+// или Authorization: Bearer и запросы с basic auth пользователя из
+// users. Остальным отвечает 401 с заголовком WWW-Authenticate для realm.
+func New(log *slog.Logger, users Authenticator, keys APIKeyAuthenticator, realm string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		log.Info("auth middleware enabled")
+
+		unauthorized := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error(response.CodeUnauthorized, ErrMsgUnauthorized))
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+
+			if key, ok := apiKey(r); ok {
+				saved, err := keys.Authenticate(r.Context(), key)
+				if errors.Is(err, apikey.ErrInvalidKey) || errors.Is(err, apikey.ErrKeyRevoked) || errors.Is(err, apikey.ErrKeyExpired) {
+					log.Info("api key authentication failed", xslog.Err(err))
+					unauthorized(w, r)
+					return
+				}
+				if err != nil {
+					log.Error(ErrMsgFailedCheckAPIKey, xslog.Err(err))
+					response.RenderStorageError(w, r, err, ErrMsgFailedCheckAPIKey)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), Principal{
+					Name:     keyNamePrefix + saved.Name,
+					APIKeyId: saved.Id,
+					Scopes:   saved.Scopes,
+				})))
+				return
+			}
+
+			name, password, ok := r.BasicAuth()
+			if !ok || !users.Authenticate(name, password) {
+				log.Info("authentication failed", slog.String("user", name))
+				unauthorized(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), Principal{Name: name})))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Require пропускает запросы, у которых есть право scope, остальным
+// отвечает 403. Ставится после New.
+func Require(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFrom(r.Context())
+			if !ok || !principal.Allowed(scope) {
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(response.CodeForbidden, ErrMsgMissingScope+scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireUser пропускает запросы пользователей из names, остальным
+// пользователям и ключам API отвечает 403. Пустой names не пропускает
+// никого. Ставится после New.
+func RequireUser(names []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFrom(r.Context())
+			if !ok || !principal.IsUser() || !slices.Contains(names, principal.Name) {
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(response.CodeForbidden, ErrMsgUserOnly))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// apiKey возвращает ключ API из заголовков запроса.
+func apiKey(r *http.Request) (string, bool) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key, true
+	}
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > len(bearerPrefix) && strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return authorization[len(bearerPrefix):], true
+	}
+	return "", false
+}
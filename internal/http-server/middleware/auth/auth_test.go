@@ -0,0 +1,110 @@
+//go:build smoke
+
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/storage/memory"
+	"url-shortener/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter возвращает роутер с пользователями alice и bob, где
+// GET /links требует права links:read, /admin доступен только alice,
+// а /nobody не доступен никому.
+func newRouter(t *testing.T, keys *apikey.Manager) http.Handler {
+	store, err := users.NewStatic(slogdiscard.NewDiscardLogger(), map[string]string{"alice": "alice-secret", "bob": "bob-secret"})
+	require.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFrom(r.Context())
+		_, _ = w.Write([]byte(principal.Name))
+	}
+	router := chi.NewRouter()
+	router.Use(auth.New(slogdiscard.NewDiscardLogger(), store, keys, "url-shortener"))
+	router.With(auth.Require(apikey.ScopeLinksRead)).Get("/links", ok)
+	router.With(auth.RequireUser([]string{"alice"})).Get("/admin", ok)
+	router.With(auth.RequireUser(nil)).Get("/nobody", ok)
+	return router
+}
+
+// TestAuth проверяет, кого пропускает middleware и от чьего имени
+// выполняется запрос.
+func TestAuth(t *testing.T) {
+	ctx := context.Background()
+	strg := memory.New()
+	keys := apikey.New(slogdiscard.NewDiscardLogger(), strg, time.Minute)
+	readKey, _, err := keys.Issue(ctx, apikey.IssueOptions{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}})
+	require.NoError(t, err)
+	statsKey, _, err := keys.Issue(ctx, apikey.IssueOptions{Name: "bot", Scopes: []string{apikey.ScopeStatsRead}})
+	require.NoError(t, err)
+	revokedKey, revoked, err := keys.Issue(ctx, apikey.IssueOptions{Name: "leaked", Scopes: []string{apikey.ScopeLinksRead}})
+	require.NoError(t, err)
+	_, err = strg.RevokeAPIKey(ctx, revoked.Id, time.Now())
+	require.NoError(t, err)
+
+	cases := []struct {
+		caseName   string
+		path       string
+		user       string
+		password   string
+		headers    map[string]string
+		httpStatus int
+		respCode   string
+		principal  string
+	}{
+		{caseName: "Valid user", path: "/links", user: "alice", password: "alice-secret", httpStatus: http.StatusOK, principal: "alice"},
+		{caseName: "Other valid user", path: "/links", user: "bob", password: "bob-secret", httpStatus: http.StatusOK, principal: "bob"},
+		{caseName: "Wrong password", path: "/links", user: "alice", password: "bob-secret", httpStatus: http.StatusUnauthorized, respCode: response.CodeUnauthorized},
+		{caseName: "Unknown user", path: "/links", user: "carol", password: "alice-secret", httpStatus: http.StatusUnauthorized, respCode: response.CodeUnauthorized},
+		{caseName: "Without credentials", path: "/links", httpStatus: http.StatusUnauthorized, respCode: response.CodeUnauthorized},
+		{caseName: "API key header", path: "/links", headers: map[string]string{"X-API-Key": readKey}, httpStatus: http.StatusOK, principal: "key:ci"},
+		{caseName: "Bearer API key", path: "/links", headers: map[string]string{"Authorization": "Bearer " + readKey}, httpStatus: http.StatusOK, principal: "key:ci"},
+		{caseName: "API key without scope", path: "/links", headers: map[string]string{"X-API-Key": statsKey}, httpStatus: http.StatusForbidden, respCode: response.CodeForbidden},
+		{caseName: "Revoked API key", path: "/links", headers: map[string]string{"X-API-Key": revokedKey}, httpStatus: http.StatusUnauthorized, respCode: response.CodeUnauthorized},
+		{caseName: "Invalid API key", path: "/links", headers: map[string]string{"X-API-Key": "secret"}, httpStatus: http.StatusUnauthorized, respCode: response.CodeUnauthorized},
+		{caseName: "Admin user", path: "/admin", user: "alice", password: "alice-secret", httpStatus: http.StatusOK, principal: "alice"},
+		{caseName: "Not admin user", path: "/admin", user: "bob", password: "bob-secret", httpStatus: http.StatusForbidden, respCode: response.CodeForbidden},
+		{caseName: "Empty user list", path: "/nobody", user: "alice", password: "alice-secret", httpStatus: http.StatusForbidden, respCode: response.CodeForbidden},
+		{caseName: "API key on admin route", path: "/admin", headers: map[string]string{"X-API-Key": readKey}, httpStatus: http.StatusForbidden, respCode: response.CodeForbidden},
+	}
+
+	router := newRouter(t, keys)
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.httpStatus, rr.Code)
+			if tc.httpStatus == http.StatusOK {
+				assert.Equal(t, tc.principal, rr.Body.String())
+				return
+			}
+			if tc.httpStatus == http.StatusUnauthorized {
+				assert.Contains(t, rr.Header().Get("WWW-Authenticate"), `realm="url-shortener"`)
+			}
+			var resp response.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tc.respCode, resp.Code)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"url-shortener/internal/apikey"
+	"url-shortener/internal/config"
+	"url-shortener/internal/http-server/handlers/apikey/issue"
+	keylist "url-shortener/internal/http-server/handlers/apikey/list"
+	"url-shortener/internal/http-server/handlers/apikey/revoke"
+	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/url/batch"
+	"url-shortener/internal/http-server/handlers/url/delete"
+	"url-shortener/internal/http-server/handlers/url/deletebyurl"
+	"url-shortener/internal/http-server/handlers/url/info"
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/lookup"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/suggest"
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/middleware/auth"
+	mwLogger "url-shortener/internal/http-server/middleware/logger"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/aliassuggest"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Storage объединяет все интерфейсы хранилища,
+// которые нужны хендлерам.
+type Storage interface {
+	redirect.URLGetter
+	save.URLSaver
+	delete.URLDeleter
+	stats.StatsGetter
+	update.URLUpdater
+	list.URLLister
+	info.URLInfoGetter
+	batch.URLBatchSaver
+	lookup.URLsByURLGetter
+	deletebyurl.URLsByURLDeleter
+	aliassuggest.TakenKeysFinder
+	apikey.KeyStorage
+	keylist.KeyLister
+	revoke.KeyRevoker
+}
+
+// New собирает роутер. Алиасы для ссылок без алиаса генерируют
+// генераторы из aliases, алиасы из запросов проверяются по rules.
+// Запросы к /url пропускаются для пользователей из users и для ключей
+// API с нужными правами, ключами в /admin/keys управляют только
+// пользователи из cfg.APIKeys.Admins.
+// Сегменты путей роутера добавляются в зарезервированные слова rules,
+// чтобы алиасы не перекрывались роутами.
+func New(log *slog.Logger, cfg *config.Config, storage Storage, clickRecorder redirect.ClickRecorder, aliases *random.Aliases,
+	rules *aliasrule.Rules, users auth.Authenticator) *chi.Mux {
+	router := chi.NewRouter()
+	// Добавляет request id к каждому запросу
+	router.Use(middleware.RequestID)
+	// Добавляет ip пользователя
+	router.Use(middleware.RealIP)
+	// Логирует входящие запросы
+	router.Use(mwLogger.New(log))
+	// При панике, чтобы не падало все приложение из-за одного запроса
+	router.Use(middleware.Recoverer)
+	// Фишка chi. Позволяет писать такие роуты: /articles/{id} и потом
+	// получать этот id в хендлере
+	router.Use(middleware.URLFormat)
+
+	// Канонические формы URL для поиска ссылок по URL
+	normalizer := urlnorm.New(cfg.Save.StripParams)
+	// Свободные варианты занятых алиасов
+	suggester := aliassuggest.New(storage, rules, cfg.Alias.Suggestions)
+
+	router.Get("/{alias}", redirect.New(log, storage, rules, clickRecorder, cfg.Storage.QueryTimeout))
+
+	keys := apikey.New(log, storage, cfg.APIKeys.TouchInterval)
+	authenticate := auth.New(log, users, keys, "url-shortener")
+	// Права, которые нужны ключам API
+	canCreate := auth.Require(apikey.ScopeLinksCreate)
+	canDelete := auth.Require(apikey.ScopeLinksDelete)
+	canRead := auth.Require(apikey.ScopeLinksRead)
+	canReadStats := auth.Require(apikey.ScopeStatsRead)
+
+	router.Route("/url", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(canRead).Get("/", list.New(log, storage, cfg.Storage.QueryTimeout))
+		r.With(canCreate).Post("/", save.New(log, storage, aliases, rules, suggester, normalizer, cfg.Save.Dedupe, cfg.Storage.QueryTimeout))
+		r.With(canCreate).Post("/batch", batch.New(log, storage, aliases, rules, normalizer, cfg.Save.Dedupe, cfg.Batch.MaxItems, cfg.Storage.QueryTimeout))
+		r.With(canRead).Get("/suggest", suggest.New(log, suggester, rules, cfg.Storage.QueryTimeout))
+		r.With(canRead).Get("/by-target", lookup.New(log, storage, normalizer, cfg.Storage.QueryTimeout))
+		r.With(canDelete).Delete("/by-target", deletebyurl.New(log, storage, normalizer, cfg.Storage.QueryTimeout))
+		r.With(canRead).Get("/{alias}", info.New(log, storage, cfg.Storage.QueryTimeout))
+		r.With(canDelete).Delete("/{alias}", delete.New(log, storage, cfg.Storage.QueryTimeout))
+		r.With(canCreate).Patch("/{alias}", update.New(log, storage, normalizer, cfg.Storage.QueryTimeout))
+		r.With(canCreate).Put("/{alias}", update.New(log, storage, normalizer, cfg.Storage.QueryTimeout))
+		r.With(canReadStats).Get("/{alias}/stats", stats.New(log, storage, cfg.Storage.QueryTimeout))
+	})
+
+	router.Route("/admin/keys", func(r chi.Router) {
+		r.Use(authenticate, auth.RequireUser(cfg.APIKeys.Admins))
+		r.Get("/", keylist.New(log, storage, cfg.Storage.QueryTimeout))
+		r.Post("/", issue.New(log, keys, cfg.Storage.QueryTimeout))
+		r.Delete("/{id}", revoke.New(log, storage, cfg.Storage.QueryTimeout))
+	})
+
+	rules.Reserve(routeWords(router)...)
+
+	return router
+}
+
+// routeWords возвращает сегменты путей роутера, кроме параметров.
+// Кроме первых сегментов, которые перекрывают /{alias}, в них попадают
+// и вложенные, например suggest из /url/suggest перекрывает /url/{alias}.
+func routeWords(routes chi.Routes) []string {
+	var words []string
+	// walkFn не возвращает ошибок, поэтому их не вернет и Walk
+	_ = chi.Walk(routes, func(_ string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		for _, word := range strings.Split(route, "/") {
+			if word != "" && !strings.ContainsAny(word, "{*") {
+				words = append(words, word)
+			}
+		}
+		return nil
+	})
+	return words
+}
@@ -0,0 +1,159 @@
+// Package aliasrule проверяет алиасы, которые задают пользователи.
+package aliasrule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"url-shortener/internal/lib/api/validate"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Теги валидатора, которые регистрирует Rules. Tag проверяет алиас
+// целиком, остальные - отдельные правила, их возвращает валидатор
+// в FieldError.ActualTag.
+const (
+	Tag         = "alias"
+	TagCharset  = "alias_charset"
+	TagPattern  = "alias_pattern"
+	TagReserved = "alias_reserved"
+)
+
+// DefaultCharset символы, допустимые в алиасах по умолчанию.
+const DefaultCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+type Options struct {
+	// Charset допустимые символы
+	Charset   string
+	MinLength int
+	MaxLength int
+	// Pattern регулярное выражение, которому должен соответствовать
+	// алиас целиком, пустое - не проверяется
+	Pattern string
+	// Reserved алиасы, которые нельзя занять
+	Reserved []string
+	// Fold сравнивать алиасы без учета регистра и похожих символов
+	Fold bool
+}
+
+// Rules правила для алиасов. Зарезервированные слова сравниваются
+// без учета регистра, а с Options.Fold - так же, как Key.
+type Rules struct {
+	charset   string
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	reserved  map[string]struct{}
+	fold      bool
+	validate  *validator.Validate
+}
+
+// confusables символы, которые легко спутать при перепечатке
+// ссылки, и символы, которыми они заменяются в Fold.
+var confusables = strings.NewReplacer("0", "o", "1", "l", "i", "l")
+
+// Fold приводит алиас к виду, в котором совпадают алиасы, отличающиеся
+// только регистром и похожими символами: 0 и O, 1, l и I.
+func Fold(alias string) string {
+	return confusables.Replace(strings.ToLower(alias))
+}
+
+func New(opts Options) (*Rules, error) {
+	const operationPlace = "aliasrule.New"
+
+	if opts.Charset == "" {
+		return nil, fmt.Errorf("%s: charset must not be empty", operationPlace)
+	}
+	if opts.MinLength < 1 || opts.MaxLength < opts.MinLength {
+		return nil, fmt.Errorf("%s: invalid length range [%d, %d]", operationPlace, opts.MinLength, opts.MaxLength)
+	}
+
+	r := &Rules{
+		charset:   opts.Charset,
+		minLength: opts.MinLength,
+		maxLength: opts.MaxLength,
+		reserved:  make(map[string]struct{}, len(opts.Reserved)),
+		fold:      opts.Fold,
+	}
+	if opts.Pattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + opts.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		r.pattern = pattern
+	}
+	r.Reserve(opts.Reserved...)
+
+	r.validate = validate.New()
+	checks := map[string]func(alias string) bool{
+		TagCharset:  r.validCharset,
+		TagPattern:  r.matchesPattern,
+		TagReserved: func(alias string) bool { return !r.IsReserved(alias) },
+	}
+	for tag, check := range checks {
+		err := r.validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return check(fl.Field().String())
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+	}
+	r.validate.RegisterAlias(Tag, fmt.Sprintf("min=%d,max=%d,%s,%s,%s",
+		r.minLength, r.maxLength, TagCharset, TagPattern, TagReserved))
+
+	return r, nil
+}
+
+// Reserve добавляет зарезервированные слова. Вызывать можно только
+// до того, как начали проверять алиасы.
+func (r *Rules) Reserve(words ...string) {
+	for _, word := range words {
+		r.reserved[r.reservedKey(word)] = struct{}{}
+	}
+}
+
+func (r *Rules) IsReserved(alias string) bool {
+	_, ok := r.reserved[r.reservedKey(alias)]
+	return ok
+}
+
+// Key возвращает ключ, по которому алиас ищется в хранилище: с
+// Options.Fold - Fold(alias), иначе сам алиас.
+func (r *Rules) Key(alias string) string {
+	if r.fold {
+		return Fold(alias)
+	}
+	return alias
+}
+
+func (r *Rules) reservedKey(word string) string {
+	if r.fold {
+		return Fold(word)
+	}
+	return strings.ToLower(word)
+}
+
+// Valid проверяет, соответствует ли алиас всем правилам.
+func (r *Rules) Valid(alias string) bool {
+	return r.validate.Var(alias, Tag) == nil
+}
+
+// Validator возвращает валидатор запросов, в котором для алиасов
+// есть тег Tag.
+func (r *Rules) Validator() *validator.Validate {
+	return r.validate
+}
+
+func (r *Rules) validCharset(alias string) bool {
+	for _, char := range alias {
+		if !strings.ContainsRune(r.charset, char) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rules) matchesPattern(alias string) bool {
+	return r.pattern == nil || r.pattern.MatchString(alias)
+}
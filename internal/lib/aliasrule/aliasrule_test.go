@@ -0,0 +1,118 @@
+//go:build smoke
+
+package aliasrule_test
+
+import (
+	"testing"
+	"url-shortener/internal/lib/aliasrule"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	Alias string `json:"alias" validate:"omitempty,alias"`
+}
+
+// TestValidate проверяет, какое правило нарушает алиас.
+func TestValidate(t *testing.T) {
+	rules, err := aliasrule.New(aliasrule.Options{
+		Charset:   aliasrule.DefaultCharset,
+		MinLength: 3,
+		MaxLength: 8,
+		Pattern:   `[a-z].*`,
+		Reserved:  []string{"admin"},
+	})
+	require.NoError(t, err)
+	rules.Reserve("url")
+
+	cases := []struct {
+		caseName string
+		alias    string
+		// tag нарушенное правило, "" - алиас корректный
+		tag string
+	}{
+		{"Valid", "qwe-1_a", ""},
+		{"Empty is skipped", "", ""},
+		{"Too short", "qw", "min"},
+		{"Too long", "qwertyuio", "max"},
+		{"Slash", "qwe/asd", aliasrule.TagCharset},
+		{"Unicode", "ссылка", aliasrule.TagCharset},
+		{"Pattern", "1qwe", aliasrule.TagPattern},
+		{"Reserved", "admin", aliasrule.TagReserved},
+		{"Reserved route", "url", aliasrule.TagReserved},
+		{"Reserved ignores case", "aDMIN", aliasrule.TagReserved},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			err := rules.Validator().Struct(request{Alias: tc.alias})
+			if tc.tag == "" {
+				require.NoError(t, err)
+				return
+			}
+			var errs validator.ValidationErrors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, 1)
+			assert.Equal(t, "alias", errs[0].Field())
+			assert.Equal(t, tc.tag, errs[0].ActualTag())
+		})
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	cases := []struct {
+		caseName string
+		opts     aliasrule.Options
+	}{
+		{"Empty charset", aliasrule.Options{MinLength: 1, MaxLength: 8}},
+		{"Zero min length", aliasrule.Options{Charset: "abc", MaxLength: 8}},
+		{"Max less than min", aliasrule.Options{Charset: "abc", MinLength: 5, MaxLength: 4}},
+		{"Invalid pattern", aliasrule.Options{Charset: "abc", MinLength: 1, MaxLength: 8, Pattern: "("}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			_, err := aliasrule.New(tc.opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestKey проверяет, что с Fold похожие алиасы получают один ключ,
+// а без него ключом остается сам алиас.
+func TestKey(t *testing.T) {
+	cases := []struct {
+		caseName string
+		fold     bool
+		alias    string
+		expected string
+	}{
+		{"Exact keeps alias", false, "Qwe01", "Qwe01"},
+		{"Fold case", true, "QwE", "qwe"},
+		{"Fold zero", true, "g00gle", "google"},
+		{"Fold one and I", true, "1nfo-Il", "lnfo-ll"},
+		{"Fold keeps other chars", true, "abc_2-9", "abc_2-9"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 1, MaxLength: 8, Fold: tc.fold})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rules.Key(tc.alias))
+		})
+	}
+}
+
+// TestReservedFold проверяет, что с Fold зарезервированные слова
+// сравниваются так же, как ключи алиасов.
+func TestReservedFold(t *testing.T) {
+	rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 1, MaxLength: 8, Fold: true})
+	require.NoError(t, err)
+	rules.Reserve("url")
+
+	assert.True(t, rules.IsReserved("URL"))
+	assert.True(t, rules.IsReserved("ur1"))
+	assert.False(t, rules.IsReserved("urls"))
+}
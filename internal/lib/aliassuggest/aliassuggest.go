@@ -0,0 +1,116 @@
+// Package aliassuggest предлагает свободные алиасы, похожие на занятый.
+package aliassuggest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/random"
+)
+
+// randomSuffixes сколько вариантов со случайным суффиксом добавлять,
+// чтобы свободные варианты нашлись, даже если заняты все остальные.
+const randomSuffixes = 3
+
+type TakenKeysFinder interface {
+	TakenAliasKeys(ctx context.Context, keys []string) ([]string, error)
+}
+
+// Suggester проверяет алиасы и предлагает свободные варианты.
+type Suggester struct {
+	finder TakenKeysFinder
+	rules  *aliasrule.Rules
+	limit  int
+}
+
+// New возвращает Suggester, который предлагает до limit вариантов,
+// подходящих под rules.
+func New(finder TakenKeysFinder, rules *aliasrule.Rules, limit int) *Suggester {
+	return &Suggester{
+		finder: finder,
+		rules:  rules,
+		limit:  limit,
+	}
+}
+
+// Check проверяет, свободен ли alias, и предлагает свободные варианты.
+// Алиасы сравниваются по ключам из rules, все ключи проверяются одним
+// запросом к хранилищу.
+func (s *Suggester) Check(ctx context.Context, alias string) (bool, []string, error) {
+	const operationPlace = "aliassuggest.Check"
+
+	aliasKey := s.rules.Key(alias)
+	keys := []string{aliasKey}
+	seen := map[string]bool{aliasKey: true}
+	var suggestions []string
+	for _, candidate := range candidates(alias, time.Now()) {
+		key := s.rules.Key(candidate)
+		if seen[key] || !s.rules.Valid(candidate) {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+		suggestions = append(suggestions, candidate)
+	}
+
+	takenKeys, err := s.finder.TakenAliasKeys(ctx, keys)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	taken := make(map[string]bool, len(takenKeys))
+	for _, key := range takenKeys {
+		taken[key] = true
+	}
+
+	free := make([]string, 0, s.limit)
+	for _, suggestion := range suggestions {
+		if len(free) == s.limit {
+			break
+		}
+		if !taken[s.rules.Key(suggestion)] {
+			free = append(free, suggestion)
+		}
+	}
+	return !taken[aliasKey], free, nil
+}
+
+// Suggest предлагает свободные варианты занятого alias.
+func (s *Suggester) Suggest(ctx context.Context, alias string) ([]string, error) {
+	_, suggestions, err := s.Check(ctx, alias)
+	return suggestions, err
+}
+
+// candidates возвращает варианты alias в порядке предпочтения.
+// Неподходящие под правила варианты отсеивает Check.
+func candidates(alias string, now time.Time) []string {
+	year := strconv.Itoa(now.Year())
+	result := []string{
+		alias + "-2",
+		"my-" + alias,
+		alias + "-" + year,
+		"get-" + alias,
+	}
+	// Те же слова с другими разделителями
+	if strings.ContainsAny(alias, "-_") {
+		result = append(result,
+			strings.ReplaceAll(alias, "_", "-"),
+			strings.ReplaceAll(alias, "-", "_"),
+			strings.NewReplacer("-", "", "_", "").Replace(alias),
+		)
+	}
+	for i := 3; i <= 9; i++ {
+		result = append(result, alias+"-"+strconv.Itoa(i))
+	}
+	// Для наборов символов без "-"
+	for i := 2; i <= 9; i++ {
+		result = append(result, alias+strconv.Itoa(i))
+	}
+	result = append(result, alias+year)
+	for range randomSuffixes {
+		result = append(result, alias+"-"+random.NewRandomString(4))
+	}
+	return result
+}
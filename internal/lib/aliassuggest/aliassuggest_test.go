@@ -0,0 +1,90 @@
+//go:build smoke
+
+package aliassuggest_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/aliasrule"
+	"url-shortener/internal/lib/aliassuggest"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRules(t *testing.T, charset string, fold bool) *aliasrule.Rules {
+	rules, err := aliasrule.New(aliasrule.Options{Charset: charset, MinLength: 3, MaxLength: 16, Reserved: []string{"my-promo"}, Fold: fold})
+	require.NoError(t, err)
+	return rules
+}
+
+// TestCheck проверяет, что предлагаются только свободные варианты,
+// подходящие под правила.
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	strg := memory.New()
+	for _, alias := range []string{"promo", "promo-2", "get-promo"} {
+		_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: alias})
+		require.NoError(t, err)
+	}
+	year := strconv.Itoa(time.Now().Year())
+
+	cases := []struct {
+		caseName  string
+		alias     string
+		charset   string
+		available bool
+		expected  []string
+	}{
+		{
+			caseName: "Taken alias",
+			alias:    "promo",
+			charset:  aliasrule.DefaultCharset,
+			expected: []string{"promo-" + year, "promo-3", "promo-4"},
+		},
+		{
+			caseName:  "Free alias",
+			alias:     "sale",
+			charset:   aliasrule.DefaultCharset,
+			available: true,
+			expected:  []string{"sale-2", "my-sale", "sale-" + year},
+		},
+		{
+			caseName: "Charset without dash",
+			alias:    "promo",
+			charset:  "abcdefghijklmnopqrstuvwxyz0123456789",
+			expected: []string{"promo2", "promo3", "promo4"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			suggester := aliassuggest.New(strg, newRules(t, tc.charset, false), 3)
+			available, suggestions, err := suggester.Check(ctx, tc.alias)
+			require.NoError(t, err)
+			assert.Equal(t, tc.available, available)
+			assert.Equal(t, tc.expected, suggestions)
+		})
+	}
+}
+
+// TestCheckFold проверяет, что с Fold похожие алиасы считаются занятыми.
+func TestCheckFold(t *testing.T) {
+	ctx := context.Background()
+	strg := memory.New()
+	rules := newRules(t, aliasrule.DefaultCharset, true)
+	for _, alias := range []string{"Sale", "sale-2"} {
+		_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: alias, AliasKey: rules.Key(alias)})
+		require.NoError(t, err)
+	}
+
+	suggester := aliassuggest.New(strg, rules, 2)
+	available, suggestions, err := suggester.Check(ctx, "SA1E")
+	require.NoError(t, err)
+	assert.False(t, available)
+	assert.Equal(t, []string{"my-SA1E", "SA1E-" + strconv.Itoa(time.Now().Year())}, suggestions)
+}
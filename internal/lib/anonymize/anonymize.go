@@ -0,0 +1,37 @@
+package anonymize
+
+import (
+	"net"
+	"net/netip"
+)
+
+const (
+	ipv4PrefixLen = 24
+	ipv6PrefixLen = 48
+)
+
+// IP обнуляет младшие биты адреса: последний октет для IPv4
+// и все, кроме первых 48 бит, для IPv6. addr может содержать порт.
+// Для некорректного адреса возвращается пустая строка.
+func IP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap().WithZone("")
+
+	prefixLen := ipv6PrefixLen
+	if ip.Is4() {
+		prefixLen = ipv4PrefixLen
+	}
+	prefix, err := ip.Prefix(prefixLen)
+	if err != nil {
+		return ""
+	}
+
+	return prefix.Addr().String()
+}
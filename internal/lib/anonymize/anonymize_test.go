@@ -0,0 +1,32 @@
+//go:build smoke
+
+package anonymize_test
+
+import (
+	"testing"
+	"url-shortener/internal/lib/anonymize"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIP(t *testing.T) {
+	cases := []struct {
+		caseName string
+		addr     string
+		expected string
+	}{
+		{"IPv4", "192.168.10.42", "192.168.10.0"},
+		{"IPv4 with port", "192.168.10.42:54321", "192.168.10.0"},
+		{"IPv6", "2001:db8:85a3:8d3:1319:8a2e:370:7348", "2001:db8:85a3::"},
+		{"IPv6 with port", "[2001:db8:85a3::7348]:443", "2001:db8:85a3::"},
+		{"IPv4-mapped IPv6", "::ffff:10.1.2.3", "10.1.2.0"},
+		{"Invalid address", "localhost", ""},
+		{"Empty address", "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			assert.Equal(t, tc.expected, anonymize.IP(tc.addr))
+		})
+	}
+}
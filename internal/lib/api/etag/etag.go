@@ -0,0 +1,36 @@
+// Package etag переводит версию ссылки в ETag и обратно.
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidETag = errors.New("invalid etag")
+
+// Any значение If-Match, совпадающее с любой версией.
+const Any = "*"
+
+// Format возвращает сильный ETag для версии ссылки.
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseIfMatch разбирает заголовок If-Match. Пустой заголовок и "*"
+// дают версию 0, то есть изменение без проверки версии. Слабые ETag
+// не подходят для If-Match и считаются невалидными.
+func ParseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == Any {
+		return 0, nil
+	}
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return 0, ErrInvalidETag
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalidETag
+	}
+	return version, nil
+}
@@ -0,0 +1,37 @@
+//go:build smoke
+
+package etag_test
+
+import (
+	"testing"
+	"url-shortener/internal/lib/api/etag"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		caseName string
+		header   string
+		version  int64
+		err      error
+	}{
+		{caseName: "Empty header", header: "", version: 0},
+		{caseName: "Any version", header: "*", version: 0},
+		{caseName: "Strong etag", header: `"42"`, version: 42},
+		{caseName: "Etag from Format", header: etag.Format(7), version: 7},
+		{caseName: "Weak etag", header: `W/"42"`, err: etag.ErrInvalidETag},
+		{caseName: "Unquoted", header: "42", err: etag.ErrInvalidETag},
+		{caseName: "Not a number", header: `"abc"`, err: etag.ErrInvalidETag},
+		{caseName: "Zero version", header: `"0"`, err: etag.ErrInvalidETag},
+		{caseName: "Single quote", header: `"`, err: etag.ErrInvalidETag},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			version, err := etag.ParseIfMatch(tc.header)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.version, version)
+		})
+	}
+}
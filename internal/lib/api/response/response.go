@@ -2,7 +2,6 @@ package response
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -10,12 +9,31 @@ import (
 type Response struct {
 	Status string `json:"status"`
 	Error  string `json:"error,omitempty"`
+	// Code машиночитаемый код ошибки, по нему клиентам
+	// стоит различать ошибки вместо текста Error
+	Code string `json:"code,omitempty"`
+	// Details ошибки валидации по отдельным полям запроса
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError ошибка валидации одного поля запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 const (
 	ErrMsgInvalidUrl           = "field is not a valid URL. Field:"
 	ErrMSgMissingRequiredField = "field is required. Field:"
 	ErrMsgUnexpected           = "invalid field or unecpected rule. Field:"
+	ErrMsgTooShort             = "field is too short, min length %s. Field:"
+	ErrMsgTooLong              = "field is too long, max length %s. Field:"
+	ErrMsgInvalidCharset       = "field contains characters that are not allowed. Field:"
+	ErrMsgPatternMismatch      = "field does not match the required pattern. Field:"
+	ErrMsgReserved             = "field value is reserved. Field:"
+	ErrMsgInvalidRequest       = "invalid request"
+	ErrMsgInternal             = "internal error"
 )
 
 const (
@@ -23,32 +41,77 @@ const (
 	StatusError = "Error"
 )
 
+// Коды ошибок для поля Code.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeValidation         = "validation_error"
+	CodeNotFound           = "not_found"
+	CodeAliasExists        = "alias_exists"
+	CodeExpired            = "expired"
+	CodeVersionMismatch    = "version_mismatch"
+	CodeBatchAborted       = "batch_aborted"
+	CodeNoFreeAlias        = "no_free_alias"
+	CodeInternal           = "internal_error"
+	CodeStorageTimeout     = "storage_timeout"
+	CodeStorageUnavailable = "storage_unavailable"
+)
+
+// Коды ошибок для поля FieldError.Code.
+const (
+	FieldCodeRequired   = "required"
+	FieldCodeInvalidURL = "invalid_url"
+	FieldCodeInvalid    = "invalid"
+	FieldCodeTooShort   = "too_short"
+	FieldCodeTooLong    = "too_long"
+	// FieldCodeInvalidCharset в значении есть недопустимые символы
+	FieldCodeInvalidCharset = "invalid_charset"
+	FieldCodePattern        = "pattern_mismatch"
+	FieldCodeReserved       = "reserved"
+)
+
 func OK() Response {
 	return Response{
 		Status: StatusOK,
 	}
 }
 
-func Error(msg string) Response {
+func Error(code string, msg string) Response {
 	return Response{
 		Status: StatusError,
 		Error:  msg,
+		Code:   code,
 	}
 }
 
+// ValidationError собирает ошибки валидатора в Details. В Field
+// попадает имя поля так, как его вернул validator: чтобы это было
+// имя из json, в валидаторе нужно зарегистрировать RegisterTagNameFunc.
 func ValidationError(errs validator.ValidationErrors) Response {
-	var errMessages []string
+	resp := Error(CodeValidation, ErrMsgInvalidRequest)
 
 	for _, err := range errs {
+		field := err.Field()
 		switch err.ActualTag() {
 		case "required":
-			errMessages = append(errMessages, fmt.Sprintf("%s %s", ErrMSgMissingRequiredField, err.Field()))
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeRequired, Message: ErrMSgMissingRequiredField + " " + field})
 		case "url":
-			errMessages = append(errMessages, fmt.Sprintf("%s %s", ErrMsgInvalidUrl, err.Field()))
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeInvalidURL, Message: ErrMsgInvalidUrl + " " + field})
+		case "min":
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeTooShort, Message: fmt.Sprintf(ErrMsgTooShort, err.Param()) + " " + field})
+		case "max":
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeTooLong, Message: fmt.Sprintf(ErrMsgTooLong, err.Param()) + " " + field})
+		case "alias_charset":
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeInvalidCharset, Message: ErrMsgInvalidCharset + " " + field})
+		case "alias_pattern":
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodePattern, Message: ErrMsgPatternMismatch + " " + field})
+		case "alias_reserved":
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeReserved, Message: ErrMsgReserved + " " + field})
 		default:
-			errMessages = append(errMessages, fmt.Sprintf("%s %s", ErrMsgUnexpected, err.Field()))
+			resp.Details = append(resp.Details, FieldError{Field: field, Code: FieldCodeInvalid, Message: ErrMsgUnexpected + " " + field})
 		}
-
 	}
-	return Error(strings.Join(errMessages, ", "))
+
+	return resp
 }
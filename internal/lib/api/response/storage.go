@@ -0,0 +1,31 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	ErrMsgStorageTimeout     = "storage request timed out"
+	ErrMsgStorageUnavailable = "storage is unavailable, try again later"
+)
+
+// RenderStorageError отвечает на ошибку хранилища: 504 на таймаут
+// запроса, 503 на перегруженное хранилище и 500 с msg на остальные ошибки.
+func RenderStorageError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		render.Status(r, http.StatusGatewayTimeout)
+		render.JSON(w, r, Error(CodeStorageTimeout, ErrMsgStorageTimeout))
+	case errors.Is(err, storage.ErrUnavailable):
+		render.Status(r, http.StatusServiceUnavailable)
+		render.JSON(w, r, Error(CodeStorageUnavailable, ErrMsgStorageUnavailable))
+	default:
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, Error(CodeInternal, msg))
+	}
+}
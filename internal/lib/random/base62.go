@@ -0,0 +1,118 @@
+package random
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Base62 строит алиас из url_id в base62. Алиасы самые короткие,
+// но по ним видно порядок создания ссылок.
+type Base62 struct{}
+
+func NewBase62() Base62 {
+	return Base62{}
+}
+
+func (Base62) NewAlias(id int) string {
+	return encode(uint64(id), base62Alphabet)
+}
+
+func (Base62) UsesId() bool {
+	return true
+}
+
+// Collided ничего не делает: занятым алиас бывает, только если такой
+// же алиас задан пользователем, а следующий url_id даст другой.
+func (Base62) Collided() {}
+
+func (Base62) Saved() {}
+
+// Hashids строит алиас из url_id, как hashids: id перемешивается
+// обратимой перестановкой, зависящей от соли, и кодируется алфавитом,
+// перемешанным той же солью. Разные id всегда дают разные алиасы,
+// а соседние id - непохожие.
+type Hashids struct {
+	alphabet  string
+	minLength int
+	// keys ключи раундов сети Фейстеля
+	keys [4]uint32
+}
+
+func NewHashids(salt string, minLength int) (*Hashids, error) {
+	const operationPlace = "random.NewHashids"
+
+	if minLength < 0 {
+		return nil, fmt.Errorf("%s: min length must not be negative, got %d", operationPlace, minLength)
+	}
+
+	prng := rand.New(rand.NewChaCha8(sha256.Sum256([]byte(salt))))
+	alphabet := []byte(base62Alphabet)
+	prng.Shuffle(len(alphabet), func(i, j int) {
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	})
+	h := &Hashids{alphabet: string(alphabet), minLength: minLength}
+	for i := range h.keys {
+		h.keys[i] = prng.Uint32()
+	}
+	return h, nil
+}
+
+func (h *Hashids) NewAlias(id int) string {
+	n := uint64(id)
+	// Перестановка переводит [0, 2^32) в себя, поэтому большие
+	// id кодируются как есть и не совпадают с перемешанными
+	if n < 1<<32 {
+		n = uint64(h.permute(uint32(n)))
+	}
+	alias := encode(n, h.alphabet)
+	// encode не начинает алиас с нулевого символа, поэтому
+	// дополненные алиасы не совпадают с недополненными
+	if len(alias) < h.minLength {
+		alias = strings.Repeat(h.alphabet[:1], h.minLength-len(alias)) + alias
+	}
+	return alias
+}
+
+func (h *Hashids) UsesId() bool {
+	return true
+}
+
+// Collided ничего не делает, см. Base62.Collided.
+func (h *Hashids) Collided() {}
+
+func (h *Hashids) Saved() {}
+
+// permute обратимо перемешивает x сетью Фейстеля на 16-битных половинах.
+func (h *Hashids) permute(x uint32) uint32 {
+	left, right := uint16(x>>16), uint16(x)
+	for _, key := range h.keys {
+		left, right = right, left^feistelRound(right, key)
+	}
+	return uint32(left)<<16 | uint32(right)
+}
+
+func feistelRound(half uint16, key uint32) uint16 {
+	x := (uint32(half) ^ key) * 0x9E3779B1
+	x ^= x >> 15
+	return uint16(x >> 16)
+}
+
+// encode записывает n в системе счисления с цифрами alphabet.
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
@@ -0,0 +1,158 @@
+package random
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Стратегии генерации алиасов.
+const (
+	// StrategyRandom случайный алиас из заданного алфавита
+	StrategyRandom = "random"
+	// StrategyBase62 url_id в base62: самые короткие алиасы
+	StrategyBase62 = "base62"
+	// StrategyHashids url_id, перемешанный солью: короткие алиасы,
+	// по которым не видно порядок и число ссылок
+	StrategyHashids = "hashids"
+	// StrategyWords читаемый алиас вида brave-otter-42
+	StrategyWords = "words"
+)
+
+var ErrUnknownStrategy = errors.New("unknown alias strategy")
+
+// AliasGenerator генерирует алиасы для ссылок, у которых алиас
+// не задан пользователем. Реализации безопасны для конкурентного
+// использования.
+type AliasGenerator interface {
+	// NewAlias возвращает новый алиас. id - зарезервированный url_id
+	// ссылки, если UsesId, иначе 0.
+	NewAlias(id int) string
+	// UsesId сообщает, что алиас строится из url_id, и его нужно
+	// зарезервировать до сохранения ссылки.
+	UsesId() bool
+	// Collided сообщает, что сгенерированный алиас оказался занят.
+	Collided()
+	// Saved сообщает, что сгенерированный алиас удалось сохранить.
+	Saved()
+}
+
+// AliasesOptions параметры Aliases.
+type AliasesOptions struct {
+	// Strategy стратегия по умолчанию
+	Strategy string
+	// Retries сколько раз пробовать новый алиас, если сгенерированный занят
+	Retries int
+	// Random параметры StrategyRandom, GrowAfter используется и в StrategyWords
+	Random Options
+	// HashidsSalt соль StrategyHashids. Смена соли меняет алиасы новых ссылок
+	// и может дать коллизии со старыми.
+	HashidsSalt string
+	// HashidsMinLength минимальная длина алиасов StrategyHashids
+	HashidsMinLength int
+}
+
+// Aliases генераторы алиасов всех стратегий.
+type Aliases struct {
+	generators      map[string]AliasGenerator
+	defaultStrategy string
+	retries         int
+}
+
+func NewAliases(opts AliasesOptions) (*Aliases, error) {
+	const operationPlace = "random.NewAliases"
+
+	if opts.Retries < 0 {
+		return nil, fmt.Errorf("%s: retries must not be negative, got %d", operationPlace, opts.Retries)
+	}
+	generator, err := NewGenerator(opts.Random)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	hashids, err := NewHashids(opts.HashidsSalt, opts.HashidsMinLength)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	a := &Aliases{
+		generators: map[string]AliasGenerator{
+			StrategyRandom:  generator,
+			StrategyBase62:  NewBase62(),
+			StrategyHashids: hashids,
+			StrategyWords:   NewWords(opts.Random.GrowAfter),
+		},
+		defaultStrategy: opts.Strategy,
+		retries:         opts.Retries,
+	}
+	if _, ok := a.generators[opts.Strategy]; !ok {
+		return nil, fmt.Errorf("%s: %w %q, expected one of %s", operationPlace, ErrUnknownStrategy, opts.Strategy, a.strategies())
+	}
+	return a, nil
+}
+
+// Generator возвращает генератор стратегии strategy,
+// пустая строка - стратегия по умолчанию.
+func (a *Aliases) Generator(strategy string) (AliasGenerator, error) {
+	if strategy == "" {
+		strategy = a.defaultStrategy
+	}
+	generator, ok := a.generators[strategy]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of %s", ErrUnknownStrategy, strategy, a.strategies())
+	}
+	return generator, nil
+}
+
+// Retries сколько раз пробовать новый алиас, если сгенерированный занят.
+func (a *Aliases) Retries() int {
+	return a.retries
+}
+
+func (a *Aliases) strategies() string {
+	names := make([]string, 0, len(a.generators))
+	for name := range a.generators {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// growth размер алиасов, который увеличивается после
+// growAfter коллизий подряд, но не больше max.
+type growth struct {
+	max       int64
+	growAfter int64
+
+	current atomic.Int64
+	// collisions коллизий подряд с последнего увеличения или сохранения
+	collisions atomic.Int64
+}
+
+func newGrowth(size int, max int, growAfter int) *growth {
+	g := &growth{max: int64(max), growAfter: int64(growAfter)}
+	g.current.Store(int64(size))
+	return g
+}
+
+func (g *growth) size() int {
+	return int(g.current.Load())
+}
+
+func (g *growth) collided() {
+	if g.collisions.Add(1) < g.growAfter {
+		return
+	}
+	g.collisions.Store(0)
+	for {
+		size := g.current.Load()
+		if size >= g.max || g.current.CompareAndSwap(size, size+1) {
+			return
+		}
+	}
+}
+
+func (g *growth) saved() {
+	g.collisions.Store(0)
+}
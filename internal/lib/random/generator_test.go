@@ -0,0 +1,141 @@
+//go:build smoke
+
+package random_test
+
+import (
+	"regexp"
+	"testing"
+
+	r "url-shortener/internal/lib/random"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAliases(t *testing.T, strategy string) *r.Aliases {
+	aliases, err := r.NewAliases(r.AliasesOptions{
+		Strategy:         strategy,
+		Retries:          2,
+		Random:           r.Options{Alphabet: r.DefaultAlphabet, Length: 6, MaxLength: 8, GrowAfter: 3},
+		HashidsSalt:      "salt",
+		HashidsMinLength: 5,
+	})
+	require.NoError(t, err)
+	return aliases
+}
+
+// TestAliasesGenerator проверяет выбор генератора по стратегии.
+func TestAliasesGenerator(t *testing.T) {
+	aliases := newAliases(t, r.StrategyWords)
+	assert.Equal(t, 2, aliases.Retries())
+
+	cases := []struct {
+		caseName string
+		strategy string
+		usesId   bool
+		pattern  string
+	}{
+		{"Default", "", false, `^[a-z]+-[a-z]+-\d{2}$`},
+		{"Random", r.StrategyRandom, false, `^[a-zA-Z0-9]{6}$`},
+		{"Base62", r.StrategyBase62, true, `^[a-zA-Z0-9]+$`},
+		{"Hashids", r.StrategyHashids, true, `^[a-zA-Z0-9]{5,}$`},
+		{"Words", r.StrategyWords, false, `^[a-z]+-[a-z]+-\d{2}$`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			generator, err := aliases.Generator(tc.strategy)
+			require.NoError(t, err)
+			assert.Equal(t, tc.usesId, generator.UsesId())
+			assert.Regexp(t, regexp.MustCompile(tc.pattern), generator.NewAlias(12345))
+		})
+	}
+
+	_, err := aliases.Generator("uuid")
+	assert.ErrorIs(t, err, r.ErrUnknownStrategy)
+}
+
+// TestNewAliasesInvalidOptions проверяет, что некорректные
+// параметры генераторов отклоняются.
+func TestNewAliasesInvalidOptions(t *testing.T) {
+	valid := r.AliasesOptions{
+		Strategy: r.StrategyRandom,
+		Random:   r.Options{Alphabet: r.DefaultAlphabet, Length: 6, MaxLength: 8, GrowAfter: 3},
+	}
+	cases := []struct {
+		caseName string
+		modify   func(opts *r.AliasesOptions)
+	}{
+		{"Unknown strategy", func(opts *r.AliasesOptions) { opts.Strategy = "uuid" }},
+		{"Empty strategy", func(opts *r.AliasesOptions) { opts.Strategy = "" }},
+		{"Negative retries", func(opts *r.AliasesOptions) { opts.Retries = -1 }},
+		{"Invalid random options", func(opts *r.AliasesOptions) { opts.Random.Length = 0 }},
+		{"Negative hashids min length", func(opts *r.AliasesOptions) { opts.HashidsMinLength = -1 }},
+	}
+
+	_, err := r.NewAliases(valid)
+	require.NoError(t, err)
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			opts := valid
+			tc.modify(&opts)
+			_, err := r.NewAliases(opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestBase62 проверяет кодирование url_id в base62.
+func TestBase62(t *testing.T) {
+	cases := []struct {
+		id    int
+		alias string
+	}{
+		{0, "0"},
+		{9, "9"},
+		{10, "a"},
+		{61, "Z"},
+		{62, "10"},
+		{3843, "ZZ"},
+		{1_000_000, "4c92"},
+	}
+	base62 := r.NewBase62()
+	for _, tc := range cases {
+		assert.Equal(t, tc.alias, base62.NewAlias(tc.id))
+	}
+}
+
+// TestHashids проверяет, что разные id дают разные алиасы не короче
+// минимальной длины, соседние id не похожи, а алиасы зависят от соли.
+func TestHashids(t *testing.T) {
+	hashids, err := r.NewHashids("salt", 6)
+	require.NoError(t, err)
+
+	seen := map[string]int{}
+	ids := []int{1 << 32, 1<<32 + 1, 1 << 40}
+	for id := range 20000 {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		alias := hashids.NewAlias(id)
+		require.GreaterOrEqual(t, len(alias), 6)
+		prev, ok := seen[alias]
+		require.False(t, ok, "ids %d and %d have the same alias %s", prev, id, alias)
+		seen[alias] = id
+	}
+	assert.Equal(t, hashids.NewAlias(100), hashids.NewAlias(100))
+	assert.NotEqual(t, hashids.NewAlias(100)[:4], hashids.NewAlias(101)[:4])
+
+	other, err := r.NewHashids("pepper", 6)
+	require.NoError(t, err)
+	assert.NotEqual(t, hashids.NewAlias(100), other.NewAlias(100))
+}
+
+// TestWordsGrows проверяет, что после коллизий число
+// в конце алиаса удлиняется.
+func TestWordsGrows(t *testing.T) {
+	words := r.NewWords(1)
+	assert.Regexp(t, `^[a-z]+-[a-z]+-\d{2}$`, words.NewAlias(0))
+
+	words.Collided()
+	assert.Regexp(t, `^[a-z]+-[a-z]+-\d{3}$`, words.NewAlias(0))
+}
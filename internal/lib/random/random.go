@@ -1,21 +1,157 @@
 package random
 
-import "math/rand/v2"
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
 
-const DefaultStringLen = 6
+const (
+	DefaultStringLen = 6
+	// DefaultAlphabet символы сгенерированных алиасов по умолчанию
+	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-func NewRandomString(strLen int) string {
-	min := 97
-	max := 123
-	var res string
+	lowercaseAlphabet = "abcdefghijklmnopqrstuvwxyz"
+)
 
+// NewRandomString возвращает строку из strLen случайных строчных
+// латинских букв, 0 - DefaultStringLen.
+func NewRandomString(strLen int) string {
 	if strLen == 0 {
 		strLen = DefaultStringLen
 	}
 
-	for i := 0; i < strLen; i++ {
-		res += string(rune(rand.IntN(max-min) + min))
+	return randomString(lowercaseAlphabet, strLen)
+}
+
+// NewString возвращает строку из strLen символов alphabet, выбранных
+// равновероятно с помощью crypto/rand.
+func NewString(alphabet string, strLen int) string {
+	return randomString(alphabet, strLen)
+}
+
+// Options параметры Generator.
+type Options struct {
+	// Alphabet символы алиасов: латинские буквы, цифры, '-' и '_'
+	Alphabet string
+	// Length начальная длина алиаса
+	Length int
+	// MaxLength до какой длины алиасы могут удлиняться
+	MaxLength int
+	// GrowAfter после скольких коллизий подряд алиасы удлиняются
+	GrowAfter int
+}
+
+// Generator генерирует случайные алиасы с помощью crypto/rand. Если
+// сгенерированные алиасы раз за разом оказываются заняты, то есть
+// алиасов текущей длины становится мало, длина увеличивается.
+// Безопасен для конкурентного использования.
+type Generator struct {
+	alphabet string
+	length   *growth
+}
+
+func NewGenerator(opts Options) (*Generator, error) {
+	const operationPlace = "random.NewGenerator"
+
+	if err := validateAlphabet(opts.Alphabet); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if opts.Length <= 0 {
+		return nil, fmt.Errorf("%s: length must be positive, got %d", operationPlace, opts.Length)
+	}
+	if opts.MaxLength < opts.Length {
+		return nil, fmt.Errorf("%s: max length %d is less than length %d", operationPlace, opts.MaxLength, opts.Length)
+	}
+	if opts.GrowAfter <= 0 {
+		return nil, fmt.Errorf("%s: grow after must be positive, got %d", operationPlace, opts.GrowAfter)
+	}
+
+	return &Generator{
+		alphabet: opts.Alphabet,
+		length:   newGrowth(opts.Length, opts.MaxLength, opts.GrowAfter),
+	}, nil
+}
+
+// NewAlias возвращает случайный алиас текущей длины, id не используется.
+func (g *Generator) NewAlias(int) string {
+	return randomString(g.alphabet, g.Length())
+}
+
+func (g *Generator) UsesId() bool {
+	return false
+}
+
+// Length текущая длина алиасов.
+func (g *Generator) Length() int {
+	return g.length.size()
+}
+
+// Collided сообщает, что сгенерированный алиас оказался занят.
+// После GrowAfter коллизий подряд длина алиасов увеличивается.
+func (g *Generator) Collided() {
+	g.length.collided()
+}
+
+func (g *Generator) Saved() {
+	g.length.saved()
+}
+
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("alphabet must contain at least 2 characters")
+	}
+	var seen [256]bool
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if !isAliasChar(c) {
+			return fmt.Errorf("alphabet contains unsupported character %q", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("alphabet contains duplicate character %q", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+func isAliasChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// randomString возвращает строку длины strLen из символов alphabet,
+// выбранных равновероятно.
+func randomString(alphabet string, strLen int) string {
+	// Байты не меньше limit отбрасываются, иначе первые
+	// символы алфавита выпадали бы чаще остальных
+	limit := 256 - 256%len(alphabet)
+	res := make([]byte, 0, strLen)
+	buf := make([]byte, strLen+strLen/2)
+	for len(res) < strLen {
+		if _, err := rand.Read(buf); err != nil {
+			// crypto/rand отказывает, только если ОС не отдает случайные
+			// байты, продолжать без них небезопасно
+			panic(fmt.Sprintf("random: crypto/rand failed: %v", err))
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			res = append(res, alphabet[int(b)%len(alphabet)])
+			if len(res) == strLen {
+				break
+			}
+		}
 	}
+	return string(res)
+}
 
-	return res
+// randomInt возвращает равновероятное число из [0, n).
+func randomInt(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(fmt.Sprintf("random: crypto/rand failed: %v", err))
+	}
+	return int(v.Int64())
 }
@@ -7,6 +7,9 @@ import (
 	"testing"
 
 	r "url-shortener/internal/lib/random"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testData = []struct {
@@ -45,3 +48,71 @@ func TestStringWithOnlySmallLetters(t *testing.T) {
 		})
 	}
 }
+
+// TestGeneratorAlias проверяет, что алиасы состоят
+// только из символов алфавита и имеют заданную длину.
+func TestGeneratorAlias(t *testing.T) {
+	g, err := r.NewGenerator(r.Options{Alphabet: "ab-", Length: 8, MaxLength: 8, GrowAfter: 1})
+	require.NoError(t, err)
+
+	seen := map[rune]bool{}
+	for range 100 {
+		alias := g.NewAlias(0)
+		require.Len(t, alias, 8)
+		for _, c := range alias {
+			seen[c] = true
+		}
+	}
+	assert.Equal(t, map[rune]bool{'a': true, 'b': true, '-': true}, seen)
+}
+
+// TestGeneratorGrows проверяет, что длина растет после GrowAfter
+// коллизий подряд, не превышает MaxLength и что сохранение
+// сбрасывает счетчик коллизий.
+func TestGeneratorGrows(t *testing.T) {
+	g, err := r.NewGenerator(r.Options{Alphabet: r.DefaultAlphabet, Length: 4, MaxLength: 5, GrowAfter: 2})
+	require.NoError(t, err)
+
+	g.Collided()
+	g.Saved()
+	g.Collided()
+	assert.Equal(t, 4, g.Length())
+
+	g.Collided()
+	assert.Equal(t, 5, g.Length())
+	assert.Len(t, g.NewAlias(0), 5)
+
+	for range 10 {
+		g.Collided()
+	}
+	assert.Equal(t, 5, g.Length())
+}
+
+// TestNewGeneratorInvalidOptions проверяет, что некорректные
+// параметры генератора отклоняются.
+func TestNewGeneratorInvalidOptions(t *testing.T) {
+	valid := r.Options{Alphabet: r.DefaultAlphabet, Length: 6, MaxLength: 10, GrowAfter: 3}
+	cases := []struct {
+		caseName string
+		modify   func(opts *r.Options)
+	}{
+		{"Short alphabet", func(opts *r.Options) { opts.Alphabet = "a" }},
+		{"Duplicate characters", func(opts *r.Options) { opts.Alphabet = "abca" }},
+		{"Unsupported characters", func(opts *r.Options) { opts.Alphabet = "ab/" }},
+		{"Non-ASCII alphabet", func(opts *r.Options) { opts.Alphabet = "abя" }},
+		{"Zero length", func(opts *r.Options) { opts.Length = 0 }},
+		{"Max length less than length", func(opts *r.Options) { opts.MaxLength = 5 }},
+		{"Zero grow after", func(opts *r.Options) { opts.GrowAfter = 0 }},
+	}
+
+	_, err := r.NewGenerator(valid)
+	require.NoError(t, err)
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			opts := valid
+			tc.modify(&opts)
+			_, err := r.NewGenerator(opts)
+			assert.Error(t, err)
+		})
+	}
+}
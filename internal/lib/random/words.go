@@ -0,0 +1,60 @@
+package random
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+const (
+	// wordsDigits сколько цифр в конце алиаса сначала
+	wordsDigits = 2
+	// wordsMaxDigits до скольких цифр может вырасти число в конце алиаса
+	wordsMaxDigits = 6
+)
+
+var (
+	//go:embed words/adjectives.txt
+	adjectivesList string
+	//go:embed words/animals.txt
+	animalsList string
+
+	adjectives = strings.Fields(adjectivesList)
+	animals    = strings.Fields(animalsList)
+)
+
+// Words генерирует читаемые алиасы вида brave-otter-42 из встроенных
+// списков слов. Когда алиасы раз за разом оказываются заняты, число
+// в конце удлиняется.
+type Words struct {
+	digits *growth
+}
+
+// NewWords возвращает Words, который удлиняет число после
+// growAfter коллизий подряд.
+func NewWords(growAfter int) *Words {
+	return &Words{digits: newGrowth(wordsDigits, wordsMaxDigits, growAfter)}
+}
+
+// NewAlias возвращает случайный алиас, id не используется.
+func (w *Words) NewAlias(int) string {
+	digits := w.digits.size()
+	limit := 1
+	for range digits {
+		limit *= 10
+	}
+	return fmt.Sprintf("%s-%s-%0*d", adjectives[randomInt(len(adjectives))], animals[randomInt(len(animals))],
+		digits, randomInt(limit))
+}
+
+func (w *Words) UsesId() bool {
+	return false
+}
+
+func (w *Words) Collided() {
+	w.digits.collided()
+}
+
+func (w *Words) Saved() {
+	w.digits.saved()
+}
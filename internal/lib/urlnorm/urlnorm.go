@@ -0,0 +1,151 @@
+package urlnorm
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultStripParams параметры отслеживания, которые удаляются
+// из query по умолчанию. Звездочка в конце задает префикс.
+var DefaultStripParams = []string{"utm_*", "fbclid", "gclid", "yclid"}
+
+// defaultPorts порты, которые не указываются в каноническом URL.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer приводит URL к канонической форме, чтобы разные записи
+// одного адреса совпадали. Каноническая форма - только ключ для поиска,
+// переходы идут по исходному URL.
+type Normalizer struct {
+	// strip имена удаляемых параметров в нижнем регистре
+	strip map[string]bool
+	// stripPrefixes префиксы удаляемых параметров в нижнем регистре
+	stripPrefixes []string
+}
+
+// New возвращает Normalizer, удаляющий из query параметры stripParams.
+// Имена сравниваются без учета регистра, "utm_*" удаляет все параметры,
+// начинающиеся с "utm_".
+func New(stripParams []string) *Normalizer {
+	n := &Normalizer{strip: make(map[string]bool, len(stripParams))}
+	for _, param := range stripParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			n.stripPrefixes = append(n.stripPrefixes, prefix)
+		} else if param != "" {
+			n.strip[param] = true
+		}
+	}
+	return n
+}
+
+// Canonical возвращает каноническую форму URL:
+//   - схема и хост в нижнем регистре, IDN-хост в punycode;
+//   - без порта по умолчанию и точки в конце хоста;
+//   - путь без сегментов "." и ".." и без завершающего слеша;
+//   - query без параметров отслеживания, отсортированный по имени.
+//
+// Fragment не меняется. URL без хоста или который не разбирается
+// возвращается как есть.
+func (n *Normalizer) Canonical(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = canonicalHost(u.Hostname(), u.Port(), u.Scheme)
+
+	escapedPath := strings.TrimRight(removeDotSegments(u.EscapedPath()), "/")
+	if path, err := url.PathUnescape(escapedPath); err == nil {
+		u.Path, u.RawPath = path, escapedPath
+	}
+
+	u.ForceQuery = false
+	if u.RawQuery != "" {
+		if query, err := url.ParseQuery(u.RawQuery); err == nil {
+			for name := range query {
+				if n.isStripped(name) {
+					query.Del(name)
+				}
+			}
+			// Encode сортирует параметры по имени
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	return u.String()
+}
+
+func (n *Normalizer) isStripped(name string) bool {
+	name = strings.ToLower(name)
+	if n.strip[name] {
+		return true
+	}
+	for _, prefix := range n.stripPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalHost(host string, port string, scheme string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	isIPv6 := strings.Contains(host, ":")
+	if !isIPv6 {
+		// Некорректный IDN оставляется как есть, его все равно не открыть
+		if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+			host = ascii
+		}
+	}
+
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		return net.JoinHostPort(host, port)
+	case isIPv6:
+		return "[" + host + "]"
+	default:
+		return host
+	}
+}
+
+// removeDotSegments убирает из пути сегменты "." и ".." по RFC 3986.
+// ".." выше корня отбрасывается.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	result := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				result = append(result, "")
+			}
+		case "..":
+			// Первый элемент - пустая строка перед ведущим слешем
+			if len(result) > 1 {
+				result = result[:len(result)-1]
+			}
+			if last {
+				result = append(result, "")
+			}
+		default:
+			result = append(result, segment)
+		}
+	}
+
+	return strings.Join(result, "/")
+}
@@ -0,0 +1,59 @@
+//go:build smoke
+
+package urlnorm_test
+
+import (
+	"testing"
+	"url-shortener/internal/lib/urlnorm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	cases := []struct {
+		caseName string
+		url      string
+		expected string
+	}{
+		{"Already canonical", "https://ya.ru/news", "https://ya.ru/news"},
+		{"Host case", "https://YA.ru/News", "https://ya.ru/News"},
+		{"Scheme case", "HTTPS://ya.ru", "https://ya.ru"},
+		{"Host trailing dot", "https://ya.ru./news", "https://ya.ru/news"},
+		{"IDN host", "https://Пример.РФ/новости", "https://xn--e1afmkfd.xn--p1ai/%D0%BD%D0%BE%D0%B2%D0%BE%D1%81%D1%82%D0%B8"},
+		{"Punycode host", "https://xn--e1afmkfd.xn--p1ai", "https://xn--e1afmkfd.xn--p1ai"},
+		{"Default http port", "http://ya.ru:80/news", "http://ya.ru/news"},
+		{"Default https port", "https://ya.ru:443/news", "https://ya.ru/news"},
+		{"Other port", "https://ya.ru:8443/news", "https://ya.ru:8443/news"},
+		{"Root slash", "https://ya.ru/", "https://ya.ru"},
+		{"Trailing slash", "https://ya.ru/news/", "https://ya.ru/news"},
+		{"Dot segments", "https://ya.ru/a/./b/../c", "https://ya.ru/a/c"},
+		{"Dot segments above root", "https://ya.ru/../../a", "https://ya.ru/a"},
+		{"Trailing dot segment", "https://ya.ru/a/b/..", "https://ya.ru/a"},
+		{"Dots inside segment", "https://ya.ru/v1.2/..a", "https://ya.ru/v1.2/..a"},
+		{"Escaped path", "https://ya.ru/a%2Fb/", "https://ya.ru/a%2Fb"},
+		{"Sorted query", "https://ya.ru/?b=2&a=1", "https://ya.ru?a=1&b=2"},
+		{"Repeated params keep order", "https://ya.ru/?a=2&a=1", "https://ya.ru?a=2&a=1"},
+		{"Tracking params", "https://ya.ru/news?utm_source=tg&UTM_Medium=post&id=5&fbclid=abc", "https://ya.ru/news?id=5"},
+		{"Only tracking params", "https://ya.ru/news?utm_source=tg&gclid=1", "https://ya.ru/news"},
+		{"Empty query", "https://ya.ru/news?", "https://ya.ru/news"},
+		{"Fragment is kept", "https://ya.ru/#top", "https://ya.ru#top"},
+		{"IPv6 with default port", "http://[::1]:80/", "http://[::1]"},
+		{"IPv6 with port", "http://[::1]:8080/", "http://[::1]:8080"},
+		{"No host", "mailto:user@ya.ru", "mailto:user@ya.ru"},
+		{"Invalid", "http://ya.ru/%zz", "http://ya.ru/%zz"},
+	}
+
+	normalizer := urlnorm.New(urlnorm.DefaultStripParams)
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			assert.Equal(t, tc.expected, normalizer.Canonical(tc.url))
+		})
+	}
+}
+
+func TestCustomStripParams(t *testing.T) {
+	normalizer := urlnorm.New([]string{"ref", " Session_* "})
+	assert.Equal(t, "https://ya.ru?utm_source=tg", normalizer.Canonical("https://ya.ru/?ref=1&session_id=2&SESSION_TS=3&utm_source=tg"))
+
+	assert.Equal(t, "https://ya.ru?fbclid=1", urlnorm.New(nil).Canonical("https://ya.ru/?fbclid=1"))
+}
@@ -0,0 +1,85 @@
+// Package purge периодически убирает из хранилища
+// ссылки с истекшим сроком жизни.
+package purge
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"url-shortener/internal/lib/logger/xslog"
+)
+
+const (
+	ModeDelete  = "delete"
+	ModeArchive = "archive"
+)
+
+type ExpiredURLRemover interface {
+	DeleteExpiredURLs(ctx context.Context, now time.Time) (int, error)
+	ArchiveExpiredURLs(ctx context.Context, now time.Time) (int, error)
+}
+
+type Purger struct {
+	log      *slog.Logger
+	remover  ExpiredURLRemover
+	interval time.Duration
+	mode     string
+}
+
+func New(log *slog.Logger, remover ExpiredURLRemover, interval time.Duration, mode string) (*Purger, error) {
+	const operationPlace = "purge.New"
+
+	if mode != ModeDelete && mode != ModeArchive {
+		return nil, fmt.Errorf("%s: undefined purge mode %s", operationPlace, mode)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("%s: interval must be positive, got %s", operationPlace, interval)
+	}
+
+	return &Purger{
+		log:      log.With(slog.String("component", "purge")),
+		remover:  remover,
+		interval: interval,
+		mode:     mode,
+	}, nil
+}
+
+// Run запускает очистку раз в interval до отмены ctx.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.Purge(ctx); err != nil {
+				p.log.Error("failed to purge expired urls", xslog.Err(err))
+			}
+		}
+	}
+}
+
+// Purge один раз убирает ссылки, истекшие к текущему моменту.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	const operationPlace = "purge.Purge"
+
+	var removed int
+	var err error
+	now := time.Now()
+	if p.mode == ModeArchive {
+		removed, err = p.remover.ArchiveExpiredURLs(ctx, now)
+	} else {
+		removed, err = p.remover.DeleteExpiredURLs(ctx, now)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	if removed > 0 {
+		p.log.Info("expired urls purged", slog.Int("count", removed), slog.String("mode", p.mode))
+	}
+	return removed, nil
+}
@@ -0,0 +1,55 @@
+//go:build smoke
+
+package purge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/purge"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurge(t *testing.T) {
+	cases := []struct {
+		caseName string
+		mode     string
+	}{
+		{"Delete expired urls", purge.ModeDelete},
+		{"Archive expired urls", purge.ModeArchive},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			ctx := context.Background()
+			strg := memory.New()
+			past := time.Now().Add(-time.Minute)
+			_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "expired", ExpiresAt: &past})
+			require.NoError(t, err)
+			_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "forever"})
+			require.NoError(t, err)
+
+			purger, err := purge.New(slogdiscard.NewDiscardLogger(), strg, time.Hour, tc.mode)
+			require.NoError(t, err)
+
+			removed, err := purger.Purge(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, removed)
+
+			_, err = strg.GetURLByAlias(ctx, "expired")
+			assert.ErrorIs(t, err, storage.ErrURLNotFound)
+			_, err = strg.GetURLByAlias(ctx, "forever")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewWithUndefinedMode(t *testing.T) {
+	_, err := purge.New(slogdiscard.NewDiscardLogger(), memory.New(), time.Hour, "drop")
+	assert.Error(t, err)
+}
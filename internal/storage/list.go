@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Поля, по которым можно сортировать список ссылок.
+const (
+	SortCreated = "created"
+	SortAlias   = "alias"
+	SortClicks  = "clicks"
+)
+
+// ListFilter условия отбора ссылок. Пустые поля не участвуют в отборе.
+type ListFilter struct {
+	// URLContains подстрока URL без учета регистра
+	URLContains string
+	// Domain домен URL вместе с поддоменами
+	Domain string
+	// AliasPrefix начало алиаса с учетом регистра
+	AliasPrefix string
+	// CreatedFrom и CreatedTo ограничивают время создания: [from, to)
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// ListCursor позиция в списке: последняя ссылка предыдущей
+// страницы. Заполняется значение поля сортировки и Id.
+type ListCursor struct {
+	CreatedAt time.Time
+	Alias     string
+	Clicks    int
+	Id        int
+}
+
+// ListOptions параметры постраничного получения ссылок. Ссылки
+// упорядочены по SortBy, а при равенстве - по id в том же направлении.
+type ListOptions struct {
+	Filter ListFilter
+	// SortBy - SortCreated, SortAlias или SortClicks, пустое значение - SortCreated
+	SortBy string
+	Desc   bool
+	// After - вернуть ссылки, идущие строго после курсора
+	After *ListCursor
+	Limit int
+}
+
+// URLListItem ссылка вместе с количеством переходов.
+type URLListItem struct {
+	URLRecord
+	Clicks int
+}
+
+// Cursor возвращает курсор, указывающий на эту ссылку.
+func (i URLListItem) Cursor() ListCursor {
+	return ListCursor{
+		CreatedAt: i.CreatedAt,
+		Alias:     i.Alias,
+		Clicks:    i.Clicks,
+		Id:        i.Id,
+	}
+}
+
+// URLHost возвращает хост из URL в нижнем регистре без порта
+// или пустую строку, если URL не разбирается.
+func URLHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// MatchDomain сообщает, относится ли host к домену domain
+// или одному из его поддоменов.
+func MatchDomain(host string, domain string) bool {
+	domain = strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
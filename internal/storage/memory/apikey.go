@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+// SaveAPIKey сохраняет новый ключ API.
+func (s *Storage) SaveAPIKey(_ context.Context, key storage.APIKeyToSave) (storage.APIKey, error) {
+	const operationPlace = "storage.memory.SaveAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, saved := range s.apiKeys {
+		if saved.Prefix == key.Prefix {
+			return storage.APIKey{}, fmt.Errorf("%s: prefix %s already exists", operationPlace, key.Prefix)
+		}
+	}
+
+	saved := storage.APIKey{
+		Id:        len(s.apiKeys) + 1,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Hash:      slices.Clone(key.Hash),
+		Scopes:    slices.Clone(key.Scopes),
+		CreatedAt: time.Now(),
+		CreatedBy: key.CreatedBy,
+		ExpiresAt: cloneTime(key.ExpiresAt),
+	}
+	s.apiKeys = append(s.apiKeys, saved)
+	return cloneAPIKey(saved), nil
+}
+
+// GetAPIKeyByPrefix возвращает ключ API по его открытой части.
+// Отозванные и истекшие ключи тоже возвращаются.
+func (s *Storage) GetAPIKeyByPrefix(_ context.Context, prefix string) (storage.APIKey, error) {
+	const operationPlace = "storage.memory.GetAPIKeyByPrefix"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Prefix == prefix {
+			return cloneAPIKey(key), nil
+		}
+	}
+	return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+}
+
+// ListAPIKeys возвращает все ключи API в порядке создания.
+func (s *Storage) ListAPIKeys(_ context.Context) ([]storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]storage.APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, cloneAPIKey(key))
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает ключ API в момент now. Уже отозванный
+// ключ не меняется.
+func (s *Storage) RevokeAPIKey(_ context.Context, id int, now time.Time) (storage.APIKey, error) {
+	const operationPlace = "storage.memory.RevokeAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.apiKey(id)
+	if key == nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &now
+	}
+	return cloneAPIKey(*key), nil
+}
+
+// TouchAPIKey запоминает, что ключ API использовался в момент usedAt.
+// Более раннее время не затирает более позднее.
+func (s *Storage) TouchAPIKey(_ context.Context, id int, usedAt time.Time) error {
+	const operationPlace = "storage.memory.TouchAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.apiKey(id)
+	if key == nil {
+		return fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+	if key.LastUsedAt == nil || usedAt.After(*key.LastUsedAt) {
+		key.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+// apiKey возвращает ключ по id или nil. Вызывается под s.mu.
+func (s *Storage) apiKey(id int) *storage.APIKey {
+	if id < 1 || id > len(s.apiKeys) {
+		return nil
+	}
+	return &s.apiKeys[id-1]
+}
+
+func cloneAPIKey(key storage.APIKey) storage.APIKey {
+	key.Hash = slices.Clone(key.Hash)
+	key.Scopes = slices.Clone(key.Scopes)
+	key.ExpiresAt = cloneTime(key.ExpiresAt)
+	key.LastUsedAt = cloneTime(key.LastUsedAt)
+	key.RevokedAt = cloneTime(key.RevokedAt)
+	return key
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
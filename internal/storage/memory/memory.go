@@ -0,0 +1,631 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+type record struct {
+	id        int
+	alias     string
+	url       string
+	expiresAt *time.Time
+	version   int64
+	createdAt time.Time
+	updatedAt time.Time
+	createdBy string
+	generated bool
+	// canonical канонический URL, по которому ссылка ищется по URL
+	canonical string
+	// canonicalPending canonical еще не построен, см. CanonicalizeURLs
+	canonicalPending bool
+	// key ключ алиаса, по которому ссылка ищется при редиректе
+	key string
+}
+
+// Storage хранит пары алиас-url в памяти процесса.
+// Данные теряются при перезапуске, поэтому подходит
+// для локального запуска и тестов.
+type Storage struct {
+	mu       sync.RWMutex
+	lastId   int
+	byAlias  map[string]record
+	archived []record
+	// byKey алиасы по ключам
+	byKey map[string]string
+	// clicks переходы по id записи
+	clicks map[int][]storage.Click
+	// apiKeys ключи API в порядке создания, id ключа - индекс + 1
+	apiKeys []storage.APIKey
+}
+
+func New() *Storage {
+	return &Storage{
+		byAlias: make(map[string]record),
+		byKey:   make(map[string]string),
+		clicks:  make(map[int][]storage.Click),
+	}
+}
+
+// ReserveURLIds резервирует n значений url_id для ссылок, которые
+// будут сохранены позже. Зарезервированные id не выдаются повторно.
+func (s *Storage) ReserveURLIds(_ context.Context, n int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, n)
+	for i := range ids {
+		s.lastId++
+		ids[i] = s.lastId
+	}
+	return ids, nil
+}
+
+// taken проверяет, занят ли алиас или его ключ. Вызывается под s.mu.
+func (s *Storage) taken(urlToSave storage.URLToSave) bool {
+	_, aliasTaken := s.byAlias[urlToSave.Alias]
+	_, keyTaken := s.byKey[urlToSave.Key()]
+	return aliasTaken || keyTaken
+}
+
+// add сохраняет запись. Вызывается под s.mu.
+func (s *Storage) add(rec record) {
+	s.byAlias[rec.alias] = rec
+	s.byKey[rec.key] = rec.alias
+}
+
+// remove удаляет запись вместе с ее переходами. Вызывается под s.mu.
+func (s *Storage) remove(rec record) {
+	delete(s.byAlias, rec.alias)
+	delete(s.byKey, rec.key)
+	delete(s.clicks, rec.id)
+}
+
+// nextId возвращает reserved или, если id не зарезервирован,
+// следующий свободный id. Вызывается под s.mu.
+func (s *Storage) nextId(reserved int) int {
+	if reserved != 0 {
+		return reserved
+	}
+	s.lastId++
+	return s.lastId
+}
+
+func (s *Storage) SaveURL(_ context.Context, urlToSave storage.URLToSave) (int, error) {
+	const operationPlace = "storage.memory.SaveURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.taken(urlToSave) {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrAliasExists)
+	}
+
+	id := s.nextId(urlToSave.Id)
+	now := time.Now()
+	s.add(record{
+		id:        id,
+		alias:     urlToSave.Alias,
+		url:       urlToSave.URL,
+		expiresAt: urlToSave.ExpiresAt,
+		version:   1,
+		createdAt: now,
+		updatedAt: now,
+		createdBy: urlToSave.CreatedBy,
+		generated: urlToSave.AliasGenerated,
+		canonical: urlToSave.Canonical(),
+		key:       urlToSave.Key(),
+
+		canonicalPending: urlToSave.CanonicalURL == "",
+	})
+
+	return id, nil
+}
+
+// SaveURLs сохраняет пачку ссылок в одной транзакции. Ссылки с занятым
+// алиасом пропускаются, в их результате storage.ErrAliasExists. Если atomic
+// и хотя бы одна ссылка не сохранилась, не сохраняется ни одна.
+// Ошибка возвращается, только если пачку не удалось обработать целиком.
+func (s *Storage) SaveURLs(_ context.Context, urls []storage.URLToSave, atomic bool) ([]storage.SaveResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]storage.SaveResult, len(urls))
+	// Алиасы и ключи пачки, которые будут заняты после сохранения
+	taken := make(map[string]bool, len(urls))
+	keys := make(map[string]bool, len(urls))
+	failed := false
+	for i, urlToSave := range urls {
+		if s.taken(urlToSave) || taken[urlToSave.Alias] || keys[urlToSave.Key()] {
+			results[i].Err = storage.ErrAliasExists
+			failed = true
+			continue
+		}
+		taken[urlToSave.Alias] = true
+		keys[urlToSave.Key()] = true
+	}
+	if atomic && failed {
+		storage.AbortSaveResults(results)
+		return results, nil
+	}
+
+	now := time.Now()
+	for i, urlToSave := range urls {
+		if results[i].Err != nil {
+			continue
+		}
+		id := s.nextId(urlToSave.Id)
+		s.add(record{
+			id:        id,
+			alias:     urlToSave.Alias,
+			url:       urlToSave.URL,
+			expiresAt: urlToSave.ExpiresAt,
+			version:   1,
+			createdAt: now,
+			updatedAt: now,
+			createdBy: urlToSave.CreatedBy,
+			generated: urlToSave.AliasGenerated,
+			canonical: urlToSave.Canonical(),
+			key:       urlToSave.Key(),
+
+			canonicalPending: urlToSave.CanonicalURL == "",
+		})
+		results[i].Id = id
+	}
+
+	return results, nil
+}
+
+// GetURLByAlias возвращает URL ссылки с ключом алиаса aliasKey.
+func (s *Storage) GetURLByAlias(_ context.Context, aliasKey string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.byAlias[s.byKey[aliasKey]]
+	if !ok {
+		return "", storage.ErrURLNotFound
+	}
+	if storage.IsExpired(rec.expiresAt, time.Now()) {
+		return "", storage.ErrURLExpired
+	}
+
+	return rec.url, nil
+}
+
+// TakenAliasKeys возвращает те из ключей алиасов keys, которые уже заняты.
+func (s *Storage) TakenAliasKeys(_ context.Context, keys []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var taken []string
+	for _, key := range keys {
+		if _, ok := s.byKey[key]; ok {
+			taken = append(taken, key)
+		}
+	}
+	return taken, nil
+}
+
+func (s *Storage) DeleteURLByAlias(_ context.Context, alias string) (int, error) {
+	const operationPlace = "storage.memory.DeleteURLByAlias"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.byAlias[alias]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	s.remove(rec)
+
+	return rec.id, nil
+}
+
+func (s *Storage) DeleteURLByURL(_ context.Context, canonicalURL string) (int, error) {
+	const operationPlace = "storage.memory.DeleteURLByURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletedId := -1
+	for _, rec := range s.byAlias {
+		if rec.canonical != canonicalURL {
+			continue
+		}
+		if deletedId == -1 || rec.id < deletedId {
+			deletedId = rec.id
+		}
+		s.remove(rec)
+	}
+
+	if deletedId == -1 {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return deletedId, nil
+}
+
+// GetURLsByURL возвращает все ссылки на URL с канонической формой
+// canonicalURL в порядке создания.
+// Если таких ссылок нет, возвращается пустой список.
+func (s *Storage) GetURLsByURL(_ context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.recordsByURL(canonicalURL), nil
+}
+
+// DeleteURLsByURL удаляет все ссылки на URL с канонической формой
+// canonicalURL и возвращает их в порядке создания. Если таких ссылок нет, возвращается storage.ErrURLNotFound.
+func (s *Storage) DeleteURLsByURL(_ context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	const operationPlace = "storage.memory.DeleteURLsByURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.recordsByURL(canonicalURL)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	for _, record := range records {
+		s.remove(s.byAlias[record.Alias])
+	}
+
+	return records, nil
+}
+
+// FindGeneratedURL возвращает самую старую бессрочную ссылку со
+// сгенерированным алиасом и канонической формой URL canonicalURL.
+// Если такой нет, возвращается storage.ErrURLNotFound.
+func (s *Storage) FindGeneratedURL(_ context.Context, canonicalURL string) (storage.URLRecord, error) {
+	const operationPlace = "storage.memory.FindGeneratedURL"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *record
+	for _, rec := range s.byAlias {
+		if rec.canonical != canonicalURL || !rec.generated || rec.expiresAt != nil {
+			continue
+		}
+		if found == nil || rec.id < found.id {
+			found = &rec
+		}
+	}
+	if found == nil {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return found.toURLRecord(), nil
+}
+
+// recordsByURL возвращает ссылки с канонической формой URL
+// canonicalURL в порядке создания.
+// Вызывается под s.mu.
+func (s *Storage) recordsByURL(canonicalURL string) []storage.URLRecord {
+	records := []storage.URLRecord{}
+	for _, rec := range s.byAlias {
+		if rec.canonical == canonicalURL {
+			records = append(records, rec.toURLRecord())
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Id < records[j].Id
+	})
+	return records
+}
+
+func (s *Storage) Truncate(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byAlias = make(map[string]record)
+	s.byKey = make(map[string]string)
+	s.clicks = make(map[int][]storage.Click)
+
+	return nil
+}
+
+func (s *Storage) GetURLIdByURL(_ context.Context, canonicalURL string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	urlId := -1
+	for _, rec := range s.byAlias {
+		if rec.canonical == canonicalURL && (urlId == -1 || rec.id < urlId) {
+			urlId = rec.id
+		}
+	}
+
+	if urlId == -1 {
+		return -1, storage.ErrURLNotFound
+	}
+
+	return urlId, nil
+}
+
+// CanonicalizeURLs строит каноническую форму URL функцией canonical для
+// ссылок, сохраненных без CanonicalURL. Возвращает число обработанных ссылок.
+func (s *Storage) CanonicalizeURLs(_ context.Context, canonical func(rawURL string) string, _ int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	processed := 0
+	for alias, rec := range s.byAlias {
+		if !rec.canonicalPending {
+			continue
+		}
+		rec.canonical = canonical(rec.url)
+		rec.canonicalPending = false
+		s.byAlias[alias] = rec
+		processed++
+	}
+	return processed, nil
+}
+
+// DeleteExpiredURLs удаляет ссылки, срок жизни которых истек к моменту now.
+func (s *Storage) DeleteExpiredURLs(_ context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.removeExpired(now)), nil
+}
+
+// UpdateURL меняет ссылку по алиасу и увеличивает ее версию. Если
+// expectedVersion больше нуля, ссылка меняется, только если ее текущая
+// версия совпадает с expectedVersion, иначе возвращается
+// storage.ErrVersionMismatch.
+func (s *Storage) UpdateURL(_ context.Context, alias string, update storage.URLUpdate, expectedVersion int64) (storage.URLRecord, error) {
+	const operationPlace = "storage.memory.UpdateURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.byAlias[alias]
+	if !ok {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	if expectedVersion > 0 && rec.version != expectedVersion {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrVersionMismatch)
+	}
+
+	if update.URL != "" {
+		rec.url = update.URL
+		rec.canonical = update.Canonical()
+	}
+	if update.SetExpiresAt {
+		rec.expiresAt = update.ExpiresAt
+	}
+	rec.version++
+	rec.updatedAt = time.Now()
+	s.byAlias[alias] = rec
+
+	return rec.toURLRecord(), nil
+}
+
+// ArchiveExpiredURLs переносит ссылки, срок жизни
+// которых истек к моменту now, в архив.
+func (s *Storage) ArchiveExpiredURLs(_ context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := s.removeExpired(now)
+	s.archived = append(s.archived, expired...)
+
+	return len(expired), nil
+}
+
+func (s *Storage) removeExpired(now time.Time) []record {
+	var expired []record
+	for _, rec := range s.byAlias {
+		if storage.IsExpired(rec.expiresAt, now) {
+			expired = append(expired, rec)
+			s.remove(rec)
+		}
+	}
+	return expired
+}
+
+// SaveClick сохраняет переход по алиасу.
+func (s *Storage) SaveClick(_ context.Context, click storage.Click) error {
+	const operationPlace = "storage.memory.SaveClick"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.byAlias[s.byKey[click.Alias]]
+	if !ok {
+		return fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	s.clicks[rec.id] = append(s.clicks[rec.id], click)
+
+	return nil
+}
+
+// SaveClicks сохраняет пачку переходов. Переходы по алиасам, которых
+// уже нет в хранилище, пропускаются. Возвращает число сохраненных переходов.
+func (s *Storage) SaveClicks(_ context.Context, clicks []storage.Click) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := 0
+	for _, click := range clicks {
+		rec, ok := s.byAlias[s.byKey[click.Alias]]
+		if !ok {
+			continue
+		}
+		s.clicks[rec.id] = append(s.clicks[rec.id], click)
+		saved++
+	}
+
+	return saved, nil
+}
+
+// GetURLInfo возвращает ссылку по алиасу вместе с количеством
+// переходов. В отличие от GetURLByAlias истекшие ссылки тоже возвращаются.
+func (s *Storage) GetURLInfo(_ context.Context, alias string) (storage.URLListItem, error) {
+	const operationPlace = "storage.memory.GetURLInfo"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.byAlias[alias]
+	if !ok {
+		return storage.URLListItem{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return storage.URLListItem{URLRecord: rec.toURLRecord(), Clicks: len(s.clicks[rec.id])}, nil
+}
+
+// ListURLs возвращает страницу ссылок, подходящих под opts.Filter,
+// в порядке opts.SortBy начиная после opts.After.
+func (s *Storage) ListURLs(_ context.Context, opts storage.ListOptions) ([]storage.URLListItem, error) {
+	s.mu.RLock()
+	items := make([]storage.URLListItem, 0, len(s.byAlias))
+	for _, rec := range s.byAlias {
+		if !matchFilter(rec, opts.Filter) {
+			continue
+		}
+		items = append(items, storage.URLListItem{URLRecord: rec.toURLRecord(), Clicks: len(s.clicks[rec.id])})
+	}
+	s.mu.RUnlock()
+
+	less := func(a, b storage.ListCursor) bool {
+		if opts.Desc {
+			return compareCursors(a, b, opts.SortBy) > 0
+		}
+		return compareCursors(a, b, opts.SortBy) < 0
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i].Cursor(), items[j].Cursor())
+	})
+
+	if opts.After != nil {
+		start := sort.Search(len(items), func(i int) bool {
+			return less(*opts.After, items[i].Cursor())
+		})
+		items = items[start:]
+	}
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		items = items[:opts.Limit]
+	}
+
+	return items, nil
+}
+
+// GetStats возвращает статистику переходов по алиасу. Переходы по дням
+// считаются начиная с since, в топах не больше top значений.
+func (s *Storage) GetStats(_ context.Context, alias string, since time.Time, top int) (storage.Stats, error) {
+	const operationPlace = "storage.memory.GetStats"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.byAlias[alias]
+	if !ok {
+		return storage.Stats{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	clicks := s.clicks[rec.id]
+	perDay := make(map[time.Time]int)
+	referrers := make(map[string]int)
+	userAgents := make(map[string]int)
+	for _, click := range clicks {
+		if !click.ClickedAt.Before(since) {
+			perDay[click.ClickedAt.UTC().Truncate(24*time.Hour)]++
+		}
+		if click.Referrer != "" {
+			referrers[click.Referrer]++
+		}
+		if click.UserAgent != "" {
+			userAgents[click.UserAgent]++
+		}
+	}
+
+	stats := storage.Stats{
+		TotalClicks:   len(clicks),
+		TopReferrers:  topValues(referrers, top),
+		TopUserAgents: topValues(userAgents, top),
+	}
+	for day, count := range perDay {
+		stats.ClicksPerDay = append(stats.ClicksPerDay, storage.DayClicks{Day: day, Clicks: count})
+	}
+	sort.Slice(stats.ClicksPerDay, func(i, j int) bool {
+		return stats.ClicksPerDay[i].Day.Before(stats.ClicksPerDay[j].Day)
+	})
+
+	return stats, nil
+}
+
+func topValues(counts map[string]int, top int) []storage.ValueClicks {
+	values := make([]storage.ValueClicks, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, storage.ValueClicks{Value: value, Clicks: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Clicks != values[j].Clicks {
+			return values[i].Clicks > values[j].Clicks
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > top {
+		values = values[:top]
+	}
+	return values
+}
+
+func (r record) toURLRecord() storage.URLRecord {
+	return storage.URLRecord{
+		Id:             r.id,
+		URL:            r.url,
+		Alias:          r.alias,
+		ExpiresAt:      r.expiresAt,
+		Version:        r.version,
+		CreatedAt:      r.createdAt,
+		UpdatedAt:      r.updatedAt,
+		CreatedBy:      r.createdBy,
+		AliasGenerated: r.generated,
+	}
+}
+
+func matchFilter(rec record, filter storage.ListFilter) bool {
+	if filter.URLContains != "" && !strings.Contains(strings.ToLower(rec.url), strings.ToLower(filter.URLContains)) {
+		return false
+	}
+	if filter.Domain != "" && !storage.MatchDomain(storage.URLHost(rec.url), filter.Domain) {
+		return false
+	}
+	if !strings.HasPrefix(rec.alias, filter.AliasPrefix) {
+		return false
+	}
+	if filter.CreatedFrom != nil && rec.createdAt.Before(*filter.CreatedFrom) {
+		return false
+	}
+	if filter.CreatedTo != nil && !rec.createdAt.Before(*filter.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// compareCursors сравнивает позиции по полю сортировки, а при равенстве по id.
+func compareCursors(a, b storage.ListCursor, sortBy string) int {
+	var c int
+	switch sortBy {
+	case storage.SortAlias:
+		c = strings.Compare(a.Alias, b.Alias)
+	case storage.SortClicks:
+		c = cmp.Compare(a.Clicks, b.Clicks)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
@@ -0,0 +1,15 @@
+//go:build smoke
+
+package memory_test
+
+import (
+	"testing"
+	"url-shortener/internal/storage/memory"
+	"url-shortener/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		return memory.New()
+	})
+}
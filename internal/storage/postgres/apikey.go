@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `api_key_id, name, prefix, key_hash, scopes, created_at, created_by, expires_at, last_used_at, revoked_at`
+
+// SaveAPIKey сохраняет новый ключ API.
+func (s *Storage) SaveAPIKey(ctx context.Context, key storage.APIKeyToSave) (storage.APIKey, error) {
+	const operationPlace = "storage.postgres.SaveAPIKey"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `insert into api_key(name, prefix, key_hash, scopes, expires_at, created_by)
+		values($1, $2, $3, $4, $5, $6)
+		returning ` + apiKeyColumns
+	rows, err := conn.Query(ctx, query, key.Name, key.Prefix, key.Hash, key.Scopes, key.ExpiresAt, key.CreatedBy)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	saved, err := pgx.CollectExactlyOneRow(rows, scanAPIKey)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return saved, nil
+}
+
+// GetAPIKeyByPrefix возвращает ключ API по его открытой части.
+// Отозванные и истекшие ключи тоже возвращаются.
+func (s *Storage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (storage.APIKey, error) {
+	const operationPlace = "storage.postgres.GetAPIKeyByPrefix"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `select `+apiKeyColumns+` from api_key where prefix = $1`, prefix)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	key, err := pgx.CollectExactlyOneRow(rows, scanAPIKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys возвращает все ключи API в порядке создания.
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	const operationPlace = "storage.postgres.ListAPIKeys"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `select `+apiKeyColumns+` from api_key order by api_key_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	keys, err := pgx.CollectRows(rows, scanAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает ключ API в момент now. Уже отозванный
+// ключ не меняется.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int, now time.Time) (storage.APIKey, error) {
+	const operationPlace = "storage.postgres.RevokeAPIKey"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `update api_key set revoked_at = coalesce(revoked_at, $2)
+		where api_key_id = $1
+		returning ` + apiKeyColumns
+	rows, err := conn.Query(ctx, query, id, now)
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	key, err := pgx.CollectExactlyOneRow(rows, scanAPIKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return key, nil
+}
+
+// TouchAPIKey запоминает, что ключ API использовался в момент usedAt.
+// Более раннее время не затирает более позднее.
+func (s *Storage) TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error {
+	const operationPlace = "storage.postgres.TouchAPIKey"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `update api_key set last_used_at = greatest(last_used_at, $2) where api_key_id = $1`
+	tag, err := conn.Exec(ctx, query, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row pgx.CollectableRow) (storage.APIKey, error) {
+	var key storage.APIKey
+	err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.Hash, &key.Scopes, &key.CreatedAt, &key.CreatedBy,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"url-shortener/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationsLockKey ключ advisory lock, под которым выполняются миграции.
+// Пока одна реплика мигрирует схему, остальные ждут.
+const migrationsLockKey int64 = 4_216_831_305_772_003
+
+var (
+	ErrUnknownMigrationVersion = errors.New("unknown migration version")
+	ErrNoMigrationToRollback   = errors.New("no migration to rollback")
+)
+
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	// AppliedAt nil, если миграция еще не применена
+	AppliedAt *time.Time
+}
+
+// MigrateUp применяет все непримененные миграции.
+// Возвращает количество примененных миграций.
+func (s *Storage) MigrateUp(ctx context.Context) (int, error) {
+	const operationPlace = "storage.postgres.MigrateUp"
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+
+	applied, err := s.migrateTo(ctx, migrations, migrations[len(migrations)-1].Version)
+	if err != nil {
+		return applied, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	return applied, nil
+}
+
+// MigrateDown откатывает последнюю примененную миграцию.
+func (s *Storage) MigrateDown(ctx context.Context) error {
+	const operationPlace = "storage.postgres.MigrateDown"
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	err = s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[migrations[i].Version]; ok {
+				return rollbackMigration(ctx, conn, migrations[i])
+			}
+		}
+		return ErrNoMigrationToRollback
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	return nil
+}
+
+// MigrateTo приводит схему к версии version: применяет миграции
+// с версией не больше version и откатывает миграции новее нее.
+// Версия 0 откатывает все миграции. Возвращает количество
+// примененных и откаченных миграций.
+func (s *Storage) MigrateTo(ctx context.Context, version int64) (int, error) {
+	const operationPlace = "storage.postgres.MigrateTo"
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	known := version == 0
+	for _, migration := range migrations {
+		if migration.Version == version {
+			known = true
+		}
+	}
+	if !known {
+		return 0, fmt.Errorf("%s: %w: %d", operationPlace, ErrUnknownMigrationVersion, version)
+	}
+
+	changed, err := s.migrateTo(ctx, migrations, version)
+	if err != nil {
+		return changed, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	return changed, nil
+}
+
+// MigrationsStatus возвращает все известные миграции
+// с отметкой о применении.
+func (s *Storage) MigrationsStatus(ctx context.Context) ([]MigrationStatus, error) {
+	const operationPlace = "storage.postgres.MigrationsStatus"
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	var statuses []MigrationStatus
+	err = s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	return statuses, nil
+}
+
+func (s *Storage) migrateTo(ctx context.Context, migrations []db.Migration, version int64) (int, error) {
+	changed := 0
+	err := s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0; i-- {
+			_, ok := applied[migrations[i].Version]
+			if !ok || migrations[i].Version <= version {
+				continue
+			}
+			if err := rollbackMigration(ctx, conn, migrations[i]); err != nil {
+				return err
+			}
+			changed++
+		}
+		for _, migration := range migrations {
+			_, ok := applied[migration.Version]
+			if ok || migration.Version > version {
+				continue
+			}
+			if err := applyMigration(ctx, conn, migration); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// withMigrationLock выполняет fn на одном соединении под advisory lock
+// и гарантирует наличие таблицы версий схемы.
+func (s *Storage) withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `select pg_advisory_lock($1)`, migrationsLockKey)
+	if err != nil {
+		return fmt.Errorf("cannot take migrations lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), `select pg_advisory_unlock($1)`, migrationsLockKey)
+	}()
+
+	_, err = conn.Exec(ctx, `create table if not exists schema_migrations (
+		version bigint primary key,
+		name text not null,
+		applied_at timestamptz not null default now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `select version, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, migration db.Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = tx.Exec(ctx, migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+	}
+	_, err = tx.Exec(ctx, `insert into schema_migrations(version, name) values ($1, $2)`, migration.Version, migration.Name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func rollbackMigration(ctx context.Context, conn *pgxpool.Conn, migration db.Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if migration.Down != "" {
+		if _, err = tx.Exec(ctx, migration.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+	}
+	_, err = tx.Exec(ctx, `delete from schema_migrations where version=$1`, migration.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -1,48 +1,182 @@
 package postgres
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 	"url-shortener/internal/storage"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrAcquireTimeout = fmt.Errorf("timeout while acquiring connection from pool: %w", storage.ErrUnavailable)
 )
 
 type Storage struct {
-	connection *pgx.Conn
+	pool           *pgxpool.Pool
+	acquireTimeout time.Duration
+}
+
+// Options настройки пула соединений. Нулевые значения оставляют
+// настройки из строки подключения или значения pgxpool по умолчанию.
+type Options struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// AcquireTimeout сколько ждать свободного соединения, 0 - без ограничения
+	AcquireTimeout time.Duration
+}
+
+// Stats снимок состояния пула соединений.
+type Stats struct {
+	MaxConns             int32
+	TotalConns           int32
+	IdleConns            int32
+	AcquiredConns        int32
+	ConstructingConns    int32
+	AcquireCount         int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+	AcquireDuration      time.Duration
+}
+
+func (s Stats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("max_conns", int(s.MaxConns)),
+		slog.Int("total_conns", int(s.TotalConns)),
+		slog.Int("idle_conns", int(s.IdleConns)),
+		slog.Int("acquired_conns", int(s.AcquiredConns)),
+		slog.Int("constructing_conns", int(s.ConstructingConns)),
+		slog.Int64("acquire_count", s.AcquireCount),
+		slog.Int64("empty_acquire_count", s.EmptyAcquireCount),
+		slog.Int64("canceled_acquire_count", s.CanceledAcquireCount),
+		slog.String("acquire_duration", s.AcquireDuration.String()),
+	)
 }
 
-func MustNewConnection(ctx context.Context, storagePath string) (*Storage, func(s Storage), error) {
+func MustNewConnection(ctx context.Context, storagePath string, opts Options) (*Storage, func(s Storage), error) {
 	const operationPlace = "storage.storage.MustNewConnection"
 	cancel := func(s Storage) {
-		err := s.connection.Close(ctx)
-		if err != nil {
-			log.Fatalf("Cannot close connection: %v (%s)", err, operationPlace)
-		}
+		s.pool.Close()
 	}
-	conn, err := pgx.Connect(ctx, storagePath)
+	poolConfig, err := pgxpool.ParseConfig(storagePath)
+	if err != nil {
+		log.Fatalf("Cannot parse db config: %v (%s)", err, operationPlace)
+	}
+	if opts.MaxConns > 0 {
+		poolConfig.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		poolConfig.MinConns = opts.MinConns
+	}
+	if opts.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if opts.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	if opts.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		log.Fatalf("Cannot connect to db: %v (%s)", err, operationPlace)
 	}
-	err = conn.Ping(ctx)
+	err = pool.Ping(ctx)
 	if err != nil {
 		log.Fatalf("DB is not availavle: %v (%s)", err, operationPlace)
 	}
-	return &Storage{connection: conn}, cancel, nil
+	return &Storage{pool: pool, acquireTimeout: opts.AcquireTimeout}, cancel, nil
+}
+
+// Stats возвращает статистику пула соединений.
+func (s *Storage) Stats() Stats {
+	stat := s.pool.Stat()
+	return Stats{
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		IdleConns:            stat.IdleConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+	}
+}
+
+// acquire берет соединение из пула. Ожидание свободного
+// соединения ограничено acquireTimeout, сам запрос - только ctx.
+func (s *Storage) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if s.acquireTimeout <= 0 {
+		return s.pool.Acquire(ctx)
+	}
+	acquireCtx, cancel := context.WithTimeout(ctx, s.acquireTimeout)
+	defer cancel()
+
+	conn, err := s.pool.Acquire(acquireCtx)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, ErrAcquireTimeout
+	}
+	return conn, err
+}
+
+// urlIdValue значение url_id при вставке: зарезервированный id из $7
+// или следующее значение последовательности, как при вставке без url_id.
+const urlIdValue = `coalesce(nullif($7::bigint, 0), nextval(pg_get_serial_sequence('url', 'url_id')))`
+
+// ReserveURLIds резервирует n значений url_id для ссылок, которые
+// будут сохранены позже. Зарезервированные id не выдаются повторно.
+func (s *Storage) ReserveURLIds(ctx context.Context, n int) ([]int, error) {
+	const operationPlace = "storage.postgres.ReserveURLIds"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `select nextval(pg_get_serial_sequence('url', 'url_id')) from generate_series(1, $1)`, n)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	slices.Sort(ids)
+
+	return ids, nil
 }
 
 // TODO: Подумать, правильно ли будет сделать это через UPSERT
-func (s *Storage) SaveURL(ctx context.Context, urlToSave string, alias string) (int, error) {
+func (s *Storage) SaveURL(ctx context.Context, urlToSave storage.URLToSave) (int, error) {
 	const operationPlace = "storage.postgres.SaveURL"
 	var insertedId int
 	var pgErr *pgconn.PgError
 
-	query := "insert into url(url, alias) values ($1, $2) returning url_id"
-	err := s.connection.QueryRow(ctx, query, urlToSave, alias).Scan(&insertedId)
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `insert into url(url_id, url, alias, expires_at, created_by, alias_generated, canonical_url, alias_key, canonical_pending) overriding system value
+		values (` + urlIdValue + `, $1, $2, $3, $4, $5, $6, $8, $9) returning url_id`
+	err = conn.QueryRow(ctx, query, urlToSave.URL, urlToSave.Alias, urlToSave.ExpiresAt, urlToSave.CreatedBy, urlToSave.AliasGenerated,
+		urlToSave.Canonical(), urlToSave.Id, urlToSave.Key(), urlToSave.CanonicalURL == "").Scan(&insertedId)
 
 	if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
 		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrAliasExists)
@@ -55,12 +189,68 @@ func (s *Storage) SaveURL(ctx context.Context, urlToSave string, alias string) (
 	return insertedId, nil
 }
 
-func (s *Storage) GetURLByAlias(ctx context.Context, alias string) (string, error) {
+// SaveURLs сохраняет пачку ссылок в одной транзакции. Ссылки с занятым
+// алиасом пропускаются, в их результате storage.ErrAliasExists. Если atomic
+// и хотя бы одна ссылка не сохранилась, не сохраняется ни одна.
+// Ошибка возвращается, только если пачку не удалось обработать целиком.
+func (s *Storage) SaveURLs(ctx context.Context, urls []storage.URLToSave, atomic bool) ([]storage.SaveResult, error) {
+	const operationPlace = "storage.postgres.SaveURLs"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// on conflict не прерывает транзакцию, в отличие от ошибки уникальности.
+	// Без указания индекса он срабатывает и на занятый алиас, и на занятый ключ.
+	query := `insert into url(url_id, url, alias, expires_at, created_by, alias_generated, canonical_url, alias_key, canonical_pending) overriding system value
+		values (` + urlIdValue + `, $1, $2, $3, $4, $5, $6, $8, $9) on conflict do nothing returning url_id`
+	results := make([]storage.SaveResult, len(urls))
+	failed := false
+	for i, urlToSave := range urls {
+		err := tx.QueryRow(ctx, query, urlToSave.URL, urlToSave.Alias, urlToSave.ExpiresAt, urlToSave.CreatedBy, urlToSave.AliasGenerated,
+			urlToSave.Canonical(), urlToSave.Id, urlToSave.Key(), urlToSave.CanonicalURL == "").Scan(&results[i].Id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			results[i].Err = storage.ErrAliasExists
+			failed = true
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+	}
+	if atomic && failed {
+		storage.AbortSaveResults(results)
+		return results, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return results, nil
+}
+
+// GetURLByAlias возвращает URL ссылки с ключом алиаса aliasKey.
+func (s *Storage) GetURLByAlias(ctx context.Context, aliasKey string) (string, error) {
 	const operationPlace = "storage.postgres.GetURLByAlias"
 	var urlByAlias string
+	var expiresAt *time.Time
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
 
-	query := `select url from url where alias=$1`
-	err := s.connection.QueryRow(ctx, query, alias).Scan(&urlByAlias)
+	query := `select url, expires_at from url where alias_key=$1`
+	err = conn.QueryRow(ctx, query, aliasKey).Scan(&urlByAlias, &expiresAt)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return "", storage.ErrURLNotFound
@@ -68,17 +258,52 @@ func (s *Storage) GetURLByAlias(ctx context.Context, alias string) (string, erro
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", operationPlace, err)
 	}
+	if storage.IsExpired(expiresAt, time.Now()) {
+		return "", storage.ErrURLExpired
+	}
 
 	return urlByAlias, nil
 }
 
+// TakenAliasKeys возвращает те из ключей алиасов keys, которые уже заняты.
+func (s *Storage) TakenAliasKeys(ctx context.Context, keys []string) ([]string, error) {
+	const operationPlace = "storage.postgres.TakenAliasKeys"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `select alias_key from url where alias_key = any($1)`, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return taken, nil
+}
+
 func (s *Storage) DeleteURLByAlias(ctx context.Context, alias string) (int, error) {
 	const operationPlace = "storage.postgres.DeleteURLByAlias"
 
 	var deletedRows int
 
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
 	query := `delete from url where alias=$1 returning url_id`
-	err := s.connection.QueryRow(ctx, query, alias).Scan(&deletedRows)
+	err = conn.QueryRow(ctx, query, alias).Scan(&deletedRows)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
 
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", operationPlace, err)
@@ -87,37 +312,165 @@ func (s *Storage) DeleteURLByAlias(ctx context.Context, alias string) (int, erro
 	return deletedRows, nil
 }
 
-func (s *Storage) DeleteURLByURL(ctx context.Context, url string) (int, error) {
+func (s *Storage) DeleteURLByURL(ctx context.Context, canonicalURL string) (int, error) {
 	const operationPlace = "storage.postgres.DeleteURLByURL"
 
-	var deletedRows int
+	var deletedRows *int
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
 
-	query := `delete from url where url=$1 returning url_id`
-	err := s.connection.QueryRow(ctx, query, url).Scan(&deletedRows)
+	query := `with deleted as (delete from url where canonical_url=$1 returning url_id)
+		select min(url_id) from deleted`
+	err = conn.QueryRow(ctx, query, canonicalURL).Scan(&deletedRows)
 
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", operationPlace, err)
 	}
+	if deletedRows == nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
 
-	return deletedRows, nil
+	return *deletedRows, nil
+}
+
+// GetURLsByURL возвращает все ссылки на URL с канонической формой
+// canonicalURL в порядке создания.
+// Если таких ссылок нет, возвращается пустой список.
+func (s *Storage) GetURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	const operationPlace = "storage.postgres.GetURLsByURL"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `select `+urlRecordColumns+` from url where canonical_url=$1 order by url_id`, canonicalURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	records, err := collectURLRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return records, nil
+}
+
+// DeleteURLsByURL удаляет все ссылки на URL с канонической формой
+// canonicalURL и возвращает их в порядке создания. Если таких ссылок нет, возвращается storage.ErrURLNotFound.
+func (s *Storage) DeleteURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	const operationPlace = "storage.postgres.DeleteURLsByURL"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `delete from url where canonical_url=$1 returning `+urlRecordColumns, canonicalURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	records, err := collectURLRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	// returning не гарантирует порядок строк
+	slices.SortFunc(records, func(a, b storage.URLRecord) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	return records, nil
+}
+
+// FindGeneratedURL возвращает самую старую бессрочную ссылку со
+// сгенерированным алиасом и канонической формой URL canonicalURL.
+// Если такой нет, возвращается storage.ErrURLNotFound.
+func (s *Storage) FindGeneratedURL(ctx context.Context, canonicalURL string) (storage.URLRecord, error) {
+	const operationPlace = "storage.postgres.FindGeneratedURL"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `select ` + urlRecordColumns + ` from url
+		where canonical_url=$1 and alias_generated and expires_at is null
+		order by url_id limit 1`
+	rows, err := conn.Query(ctx, query, canonicalURL)
+	if err != nil {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	records, err := collectURLRecords(rows)
+	if err != nil {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if len(records) == 0 {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return records[0], nil
+}
+
+// urlRecordColumns колонки url в порядке полей, которые читает collectURLRecords.
+const urlRecordColumns = "url_id, url, alias, expires_at, version, created_at, updated_at, created_by, alias_generated"
+
+// collectURLRecords читает все строки с колонками urlRecordColumns и закрывает rows.
+func collectURLRecords(rows pgx.Rows) ([]storage.URLRecord, error) {
+	defer rows.Close()
+
+	records := []storage.URLRecord{}
+	for rows.Next() {
+		var record storage.URLRecord
+		err := rows.Scan(&record.Id, &record.URL, &record.Alias, &record.ExpiresAt, &record.Version,
+			&record.CreatedAt, &record.UpdatedAt, &record.CreatedBy, &record.AliasGenerated)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
 }
 
 func (s *Storage) Truncate(ctx context.Context) error {
 	const operationPlace = "storage.postgres.Truncate"
-	query := `truncate url`
-	_, err := s.connection.Exec(ctx, query)
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `truncate url cascade`
+	_, err = conn.Exec(ctx, query)
 	if err != nil {
 		return fmt.Errorf("%s: %w", operationPlace, err)
 	}
 	return nil
 }
 
-func (s *Storage) GetURLIdByURL(ctx context.Context, URL string) (int, error) {
+func (s *Storage) GetURLIdByURL(ctx context.Context, canonicalURL string) (int, error) {
 	const operationPlace = "storage.postgres.GetURLIdByURL"
 	var urlId int
 
-	query := `select url_id from url where url=$1`
-	err := s.connection.QueryRow(ctx, query, URL).Scan(&urlId)
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `select url_id from url where canonical_url=$1 order by url_id limit 1`
+	err = conn.QueryRow(ctx, query, canonicalURL).Scan(&urlId)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return -1, storage.ErrURLNotFound
@@ -128,3 +481,445 @@ func (s *Storage) GetURLIdByURL(ctx context.Context, URL string) (int, error) {
 
 	return urlId, nil
 }
+
+// CanonicalizeURLs строит каноническую форму URL функцией canonical для
+// ссылок, у которых ее еще нет: созданных до появления канонической формы
+// или сохраненных без CanonicalURL. Ссылки обрабатываются пачками по
+// batchSize, каждая в своей транзакции. Возвращает число обработанных ссылок.
+func (s *Storage) CanonicalizeURLs(ctx context.Context, canonical func(rawURL string) string, batchSize int) (int, error) {
+	const operationPlace = "storage.postgres.CanonicalizeURLs"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	total := 0
+	for {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		rows, err := tx.Query(ctx, `select url_id, url from url where canonical_pending order by url_id limit $1 for update`, batchSize)
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return total, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		var ids []int
+		var urls []string
+		for rows.Next() {
+			var id int
+			var rawURL string
+			if err := rows.Scan(&id, &rawURL); err != nil {
+				rows.Close()
+				_ = tx.Rollback(ctx)
+				return total, fmt.Errorf("%s: %w", operationPlace, err)
+			}
+			ids = append(ids, id)
+			urls = append(urls, canonical(rawURL))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			_ = tx.Rollback(ctx)
+			return total, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		if len(ids) == 0 {
+			_ = tx.Rollback(ctx)
+			return total, nil
+		}
+
+		_, err = tx.Exec(ctx, `update url set canonical_url = batch.canonical_url, canonical_pending = false
+			from unnest($1::bigint[], $2::text[]) as batch(url_id, canonical_url)
+			where url.url_id = batch.url_id`, ids, urls)
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return total, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return total, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		total += len(ids)
+	}
+}
+
+// DeleteExpiredURLs удаляет ссылки, срок жизни которых истек к моменту now.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int, error) {
+	const operationPlace = "storage.postgres.DeleteExpiredURLs"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `delete from url where expires_at <= $1`
+	tag, err := conn.Exec(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// UpdateURL меняет ссылку по алиасу и увеличивает ее версию. Если
+// expectedVersion больше нуля, ссылка меняется, только если ее текущая
+// версия совпадает с expectedVersion, иначе возвращается
+// storage.ErrVersionMismatch.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, update storage.URLUpdate, expectedVersion int64) (storage.URLRecord, error) {
+	const operationPlace = "storage.postgres.UpdateURL"
+	var record storage.URLRecord
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return record, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `update url set
+			url = coalesce(nullif($2, ''), url),
+			canonical_url = coalesce(nullif($6, ''), canonical_url),
+			expires_at = case when $3 then $4 else expires_at end,
+			version = version + 1,
+			updated_at = now()
+		where alias=$1 and ($5 = 0 or version = $5)
+		returning url_id, url, alias, expires_at, version, created_at, updated_at, created_by, alias_generated`
+	err = conn.QueryRow(ctx, query, alias, update.URL, update.SetExpiresAt, update.ExpiresAt, expectedVersion, update.Canonical()).
+		Scan(&record.Id, &record.URL, &record.Alias, &record.ExpiresAt, &record.Version, &record.CreatedAt, &record.UpdatedAt,
+			&record.CreatedBy, &record.AliasGenerated)
+	if err == nil {
+		return record, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return record, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	// Ничего не обновилось: либо ссылки нет, либо версия не совпала
+	var exists bool
+	err = conn.QueryRow(ctx, `select exists(select 1 from url where alias=$1)`, alias).Scan(&exists)
+	if err != nil {
+		return record, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if !exists {
+		return record, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	return record, fmt.Errorf("%s: %w", operationPlace, storage.ErrVersionMismatch)
+}
+
+// ArchiveExpiredURLs переносит ссылки, срок жизни которых
+// истек к моменту now, в таблицу url_archive.
+func (s *Storage) ArchiveExpiredURLs(ctx context.Context, now time.Time) (int, error) {
+	const operationPlace = "storage.postgres.ArchiveExpiredURLs"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `with expired as (
+			delete from url where expires_at <= $1
+			returning url_id, alias, url, expires_at
+		)
+		insert into url_archive(url_id, alias, url, expires_at)
+		select url_id, alias, url, expires_at from expired
+		on conflict (url_id) do nothing`
+	tag, err := conn.Exec(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// SaveClick сохраняет переход по алиасу.
+func (s *Storage) SaveClick(ctx context.Context, click storage.Click) error {
+	const operationPlace = "storage.postgres.SaveClick"
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `insert into click(url_id, clicked_at, referrer, user_agent, request_id, ip)
+		select url_id, $2, $3, $4, $5, $6 from url where alias_key=$1`
+	tag, err := conn.Exec(ctx, query, click.Alias, click.ClickedAt, click.Referrer, click.UserAgent, click.RequestID, click.IP)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return nil
+}
+
+// SaveClicks сохраняет пачку переходов через COPY. Переходы по алиасам,
+// которых уже нет в хранилище, пропускаются. Возвращает число
+// сохраненных переходов.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) (int, error) {
+	const operationPlace = "storage.postgres.SaveClicks"
+	if len(clicks) == 0 {
+		return 0, nil
+	}
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	keys := make([]string, 0, len(clicks))
+	for _, click := range clicks {
+		keys = append(keys, click.Alias)
+	}
+	// for key share не дает удалить ссылки, пока идет COPY
+	rows, err := tx.Query(ctx, `select alias_key, url_id from url where alias_key = any($1) for key share`, keys)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	urlIds := make(map[string]int64, len(clicks))
+	for rows.Next() {
+		var key string
+		var urlId int64
+		if err := rows.Scan(&key, &urlId); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		urlIds[key] = urlId
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	rowsToCopy := make([][]any, 0, len(clicks))
+	for _, click := range clicks {
+		urlId, ok := urlIds[click.Alias]
+		if !ok {
+			continue
+		}
+		rowsToCopy = append(rowsToCopy, []any{urlId, click.ClickedAt, click.Referrer, click.UserAgent, click.RequestID, click.IP})
+	}
+	if len(rowsToCopy) == 0 {
+		return 0, nil
+	}
+
+	copied, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"click"},
+		[]string{"url_id", "clicked_at", "referrer", "user_agent", "request_id", "ip"},
+		pgx.CopyFromRows(rowsToCopy),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return int(copied), nil
+}
+
+// GetURLInfo возвращает ссылку по алиасу вместе с количеством
+// переходов. В отличие от GetURLByAlias истекшие ссылки тоже возвращаются.
+func (s *Storage) GetURLInfo(ctx context.Context, alias string) (storage.URLListItem, error) {
+	const operationPlace = "storage.postgres.GetURLInfo"
+	var item storage.URLListItem
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return item, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	query := `select url_id, url, alias, expires_at, version, created_at, updated_at, created_by, alias_generated,
+			(select count(*) from click where click.url_id = url.url_id)
+		from url where alias=$1`
+	err = conn.QueryRow(ctx, query, alias).Scan(&item.Id, &item.URL, &item.Alias, &item.ExpiresAt, &item.Version,
+		&item.CreatedAt, &item.UpdatedAt, &item.CreatedBy, &item.AliasGenerated, &item.Clicks)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return item, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return item, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return item, nil
+}
+
+// urlHostExpr вычисляет хост ссылки так же, как storage.URLHost.
+const urlHostExpr = `lower(substring(u.url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]*)'))`
+
+// ListURLs возвращает страницу ссылок, подходящих под opts.Filter,
+// в порядке opts.SortBy начиная после opts.After.
+func (s *Storage) ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.URLListItem, error) {
+	const operationPlace = "storage.postgres.ListURLs"
+
+	var where []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	filter := opts.Filter
+	if filter.URLContains != "" {
+		where = append(where, fmt.Sprintf("strpos(lower(u.url), lower(%s)) > 0", arg(filter.URLContains)))
+	}
+	if filter.Domain != "" {
+		domain := arg(strings.ToLower(filter.Domain))
+		where = append(where, fmt.Sprintf("(%[1]s = %[2]s or right(%[1]s, length(%[2]s) + 1) = '.' || %[2]s)", urlHostExpr, domain))
+	}
+	if filter.AliasPrefix != "" {
+		where = append(where, fmt.Sprintf("starts_with(u.alias, %s)", arg(filter.AliasPrefix)))
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, fmt.Sprintf("u.created_at >= %s", arg(*filter.CreatedFrom)))
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, fmt.Sprintf("u.created_at < %s", arg(*filter.CreatedTo)))
+	}
+
+	// Алиасы сравниваются побайтно, чтобы порядок не зависел от локали БД
+	sortExpr := "u.created_at"
+	switch opts.SortBy {
+	case storage.SortAlias:
+		sortExpr = `u.alias collate "C"`
+	case storage.SortClicks:
+		sortExpr = "coalesce(c.clicks, 0)"
+	}
+	direction, compare := "asc", ">"
+	if opts.Desc {
+		direction, compare = "desc", "<"
+	}
+	if opts.After != nil {
+		var value any = opts.After.CreatedAt
+		switch opts.SortBy {
+		case storage.SortAlias:
+			value = opts.After.Alias
+		case storage.SortClicks:
+			value = int64(opts.After.Clicks)
+		}
+		where = append(where, fmt.Sprintf("(%s, u.url_id) %s (%s, %s)", sortExpr, compare, arg(value), arg(opts.After.Id)))
+	}
+
+	query := `select u.url_id, u.url, u.alias, u.expires_at, u.version, u.created_at, u.updated_at,
+			u.created_by, u.alias_generated, coalesce(c.clicks, 0)
+		from url u
+		left join (select url_id, count(*) as clicks from click group by url_id) c on c.url_id = u.url_id`
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+	query += fmt.Sprintf(" order by %[1]s %[2]s, u.url_id %[2]s", sortExpr, direction)
+	if opts.Limit > 0 {
+		query += " limit " + arg(opts.Limit)
+	}
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer rows.Close()
+
+	var items []storage.URLListItem
+	for rows.Next() {
+		var item storage.URLListItem
+		err := rows.Scan(&item.Id, &item.URL, &item.Alias, &item.ExpiresAt, &item.Version, &item.CreatedAt, &item.UpdatedAt,
+			&item.CreatedBy, &item.AliasGenerated, &item.Clicks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return items, nil
+}
+
+// GetStats возвращает статистику переходов по алиасу. Переходы по дням
+// считаются начиная с since, в топах не больше top значений.
+func (s *Storage) GetStats(ctx context.Context, alias string, since time.Time, top int) (storage.Stats, error) {
+	const operationPlace = "storage.postgres.GetStats"
+	var stats storage.Stats
+	var urlId int
+
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(ctx, `select url_id from url where alias=$1`, alias).Scan(&urlId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return stats, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	err = conn.QueryRow(ctx, `select count(*) from click where url_id=$1`, urlId).Scan(&stats.TotalClicks)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	query := `select (date_trunc('day', clicked_at at time zone 'UTC'))::timestamp, count(*)
+		from click where url_id=$1 and clicked_at >= $2
+		group by 1 order by 1`
+	rows, err := conn.Query(ctx, query, urlId, since)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	stats.ClicksPerDay, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.DayClicks, error) {
+		var day storage.DayClicks
+		err := row.Scan(&day.Day, &day.Clicks)
+		day.Day = day.Day.UTC()
+		return day, err
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	stats.TopReferrers, err = topClickValues(ctx, conn, "referrer", urlId, top)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	stats.TopUserAgents, err = topClickValues(ctx, conn, "user_agent", urlId, top)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return stats, nil
+}
+
+// topClickValues возвращает самые частые непустые значения колонки
+// column таблицы click. column подставляется в запрос как есть,
+// поэтому передавать туда можно только константы.
+func topClickValues(ctx context.Context, conn *pgxpool.Conn, column string, urlId int, top int) ([]storage.ValueClicks, error) {
+	query := fmt.Sprintf(`select %[1]s, count(*) from click
+		where url_id=$1 and %[1]s <> ''
+		group by %[1]s order by count(*) desc, %[1]s limit $2`, column)
+	rows, err := conn.Query(ctx, query, urlId, top)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ValueClicks, error) {
+		var value storage.ValueClicks
+		err := row.Scan(&value.Value, &value.Clicks)
+		return value, err
+	})
+}
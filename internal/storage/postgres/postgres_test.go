@@ -7,9 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"url-shortener/internal/storage"
 	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/storagetest"
 
 	"github.com/joho/godotenv"
 )
@@ -20,6 +22,18 @@ func TestMain(m *testing.M) {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	ctx := context.Background()
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	_, err = strg.MigrateUp(ctx)
+	cancel(*strg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	exitVal := m.Run()
 	os.Exit(exitVal)
 }
@@ -28,7 +42,7 @@ func TestMain(m *testing.M) {
 // создание таблицы проходит без ошибок.
 func TestCreateTable(t *testing.T) {
 	ctx := context.Background()
-	storge, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	storge, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*storge)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
@@ -39,13 +53,13 @@ func TestCreateTable(t *testing.T) {
 // записи в таблицу происходит без ошибок.
 func TestInsertURLInTable(t *testing.T) {
 	ctx := context.Background()
-	storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	defer cancel(*storage)
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
+	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
 	}
 
-	_, err = storage.SaveURL(ctx, "http://qwe.ru", "TestInsertURLinTable")
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "TestInsertURLinTable"})
 	if err != nil {
 		t.Errorf("cannot save url: (%v)", err)
 	}
@@ -55,18 +69,18 @@ func TestInsertURLInTable(t *testing.T) {
 // что попытка вставки такого же алиаса приведет к ошибке.
 func TestCannotSaveURLBecauseAliasAlreadyInTable(t *testing.T) {
 	ctx := context.Background()
-	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
 	}
 
-	_, err = strg.SaveURL(ctx, "http://qwe.ru", "TestCannotSaveURLBecauseURLAlreadyInTable")
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "TestCannotSaveURLBecauseURLAlreadyInTable"})
 	if err != nil {
 		t.Errorf("cannot save url: (%v)", err)
 	}
 
-	_, err = strg.SaveURL(ctx, "http://qwe.ru", "TestCannotSaveURLBecauseURLAlreadyInTable")
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "TestCannotSaveURLBecauseURLAlreadyInTable"})
 	if !errors.Is(err, storage.ErrAliasExists) {
 		t.Errorf("unexpected error %v, expected %v", err, storage.ErrAliasExists)
 	}
@@ -77,13 +91,13 @@ func TestCannotSaveURLBecauseAliasAlreadyInTable(t *testing.T) {
 func TestCanGetURLByAlias(t *testing.T) {
 
 	ctx := context.Background()
-	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
 	}
 	alias, url := "TestCanGetURLByAlias", "http://qwe.ru"
-	_, err = strg.SaveURL(ctx, url, alias)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: url, Alias: alias})
 	if err != nil {
 		t.Errorf("cannot save url: (%v)", err)
 	}
@@ -101,7 +115,7 @@ func TestCanGetURLByAlias(t *testing.T) {
 // попытка получить URL по несуществующему алиасу приведет к ошибке.
 func TestCannotGetURLBecauseItNotExists(t *testing.T) {
 	ctx := context.Background()
-	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
@@ -130,12 +144,12 @@ func TestCanDeleteURLByAliasFromTable(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
 			ctx := context.Background()
-			strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+			strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 			defer cancel(*strg)
 			if err != nil {
 				t.Errorf("cannot create table url: (%v)", err)
 			}
-			_, err = strg.SaveURL(ctx, tc.url, tc.alias)
+			_, err = strg.SaveURL(ctx, storage.URLToSave{URL: tc.url, Alias: tc.alias})
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -163,12 +177,12 @@ func TestCanDeleteURLByURLFromTable(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
 			ctx := context.Background()
-			strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+			strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 			defer cancel(*strg)
 			if err != nil {
 				t.Errorf("cannot create table url: (%v)", err)
 			}
-			_, err = strg.SaveURL(ctx, tc.url, tc.alias)
+			_, err = strg.SaveURL(ctx, storage.URLToSave{URL: tc.url, Alias: tc.alias})
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -184,7 +198,7 @@ func TestCanDeleteURLByURLFromTable(t *testing.T) {
 // что очистка таблицы происходит без ошибок.
 func TestCanTruncateTable(t *testing.T) {
 	ctx := context.Background()
-	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
@@ -200,7 +214,7 @@ func TestCanTruncateTable(t *testing.T) {
 // что получение url_id по URl происходит без ошибок.
 func TestCanGetURLIdByURL(t *testing.T) {
 	ctx := context.Background()
-	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
@@ -208,7 +222,7 @@ func TestCanGetURLIdByURL(t *testing.T) {
 
 	alias, url := "TestCanGetURLIdByURL", "http://qwe.ru"
 
-	insertedId, err := strg.SaveURL(ctx, url, alias)
+	insertedId, err := strg.SaveURL(ctx, storage.URLToSave{URL: url, Alias: alias})
 	if err != nil {
 		t.Errorf("unexpected error: (%v)", err)
 	}
@@ -228,7 +242,7 @@ func TestCanGetURLIdByURL(t *testing.T) {
 // ошибка.
 func TestCannotGetURLIdByURLBecauseItNotExists(t *testing.T) {
 	ctx := context.Background()
-	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
 	defer cancel(*strg)
 	if err != nil {
 		t.Errorf("cannot create table url: (%v)", err)
@@ -240,3 +254,57 @@ func TestCannotGetURLIdByURLBecauseItNotExists(t *testing.T) {
 	}
 
 }
+
+// TestConcurrentGetURLByAlias проверяет, что
+// хранилище можно использовать из нескольких горутин одновременно.
+func TestConcurrentGetURLByAlias(t *testing.T) {
+	ctx := context.Background()
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{MaxConns: 4})
+	defer cancel(*strg)
+	if err != nil {
+		t.Errorf("cannot create table url: (%v)", err)
+	}
+
+	alias, url := "TestConcurrentGetURLByAlias", "http://qwe.ru"
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: url, Alias: alias})
+	if err != nil {
+		t.Errorf("cannot save url: (%v)", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := strg.GetURLByAlias(ctx, alias)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+// TestStorage прогоняет общий набор тестов хранилища.
+// Перед каждым тестом таблица очищается.
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	strg, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"), postgres.Options{})
+	defer cancel(*strg)
+	if err != nil {
+		t.Fatalf("cannot connect to db: (%v)", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		if err := strg.Truncate(ctx); err != nil {
+			t.Fatalf("cannot truncate table. Get error: (%v)", err)
+		}
+		return strg
+	})
+}
@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+const apiKeyColumns = `api_key_id, name, prefix, key_hash, scopes, created_at, created_by, expires_at, last_used_at, revoked_at`
+
+// SaveAPIKey сохраняет новый ключ API.
+func (s *Storage) SaveAPIKey(ctx context.Context, key storage.APIKeyToSave) (storage.APIKey, error) {
+	const operationPlace = "storage.sqlite.SaveAPIKey"
+
+	query := `insert into api_key(name, prefix, key_hash, scopes, expires_at, created_by)
+		values(?, ?, ?, ?, ?, ?)
+		returning ` + apiKeyColumns
+	saved, err := scanAPIKey(s.db.QueryRowContext(ctx, query, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","),
+		toUnix(key.ExpiresAt), key.CreatedBy))
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return saved, nil
+}
+
+// GetAPIKeyByPrefix возвращает ключ API по его открытой части.
+// Отозванные и истекшие ключи тоже возвращаются.
+func (s *Storage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (storage.APIKey, error) {
+	const operationPlace = "storage.sqlite.GetAPIKeyByPrefix"
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, `select `+apiKeyColumns+` from api_key where prefix = ?`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys возвращает все ключи API в порядке создания.
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	const operationPlace = "storage.sqlite.ListAPIKeys"
+
+	rows, err := s.db.QueryContext(ctx, `select `+apiKeyColumns+` from api_key order by api_key_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer rows.Close()
+
+	var keys []storage.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает ключ API в момент now. Уже отозванный
+// ключ не меняется.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int, now time.Time) (storage.APIKey, error) {
+	const operationPlace = "storage.sqlite.RevokeAPIKey"
+
+	query := `update api_key set revoked_at = coalesce(revoked_at, ?)
+		where api_key_id = ?
+		returning ` + apiKeyColumns
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, now.Unix(), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return key, nil
+}
+
+// TouchAPIKey запоминает, что ключ API использовался в момент usedAt.
+// Более раннее время не затирает более позднее.
+func (s *Storage) TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error {
+	const operationPlace = "storage.sqlite.TouchAPIKey"
+
+	query := `update api_key set last_used_at = max(coalesce(last_used_at, 0), ?) where api_key_id = ?`
+	res, err := s.db.ExecContext(ctx, query, usedAt.Unix(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("%s: %w", operationPlace, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+// scanAPIKey читает ключ из строки *sql.Row или *sql.Rows.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var key storage.APIKey
+	var scopes string
+	var createdAt int64
+	var expiresAt, lastUsedAt, revokedAt sql.NullInt64
+	err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.Hash, &scopes, &createdAt, &key.CreatedBy,
+		&expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return key, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.CreatedAt = time.Unix(createdAt, 0)
+	key.ExpiresAt = fromUnix(expiresAt)
+	key.LastUsedAt = fromUnix(lastUsedAt)
+	key.RevokedAt = fromUnix(revokedAt)
+	return key, nil
+}
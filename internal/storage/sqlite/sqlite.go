@@ -0,0 +1,908 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type Storage struct {
+	db *sql.DB
+}
+
+// driverName драйвер sqlite3 с функцией url_host,
+// через которую ListURLs отбирает ссылки по домену.
+const driverName = "sqlite3_url_shortener"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("url_host", storage.URLHost, true)
+		},
+	})
+}
+
+// migrations схема БД, аналогичная db/migrations. Версия схемы -
+// это количество примененных миграций, хранится в PRAGMA user_version.
+// Новые миграции добавляются только в конец списка.
+var migrations = []string{
+	`create table if not exists url (
+		url_id integer primary key autoincrement,
+		alias text not null unique,
+		url text not null
+	);
+	create unique index if not exists url_idx on url(alias);`,
+	// expires_at хранится как unix-время в секундах
+	`alter table url add column expires_at integer;
+	create index if not exists url_expires_at_idx on url(expires_at) where expires_at is not null;
+	create table if not exists url_archive (
+		url_id integer primary key,
+		alias text not null,
+		url text not null,
+		expires_at integer,
+		archived_at integer not null default (unixepoch())
+	);`,
+	`create table if not exists click (
+		click_id integer primary key autoincrement,
+		url_id integer not null references url(url_id) on delete cascade,
+		clicked_at integer not null,
+		referrer text not null default '',
+		user_agent text not null default '',
+		request_id text not null default '',
+		ip text not null default ''
+	);
+	create index if not exists click_url_id_clicked_at_idx on click(url_id, clicked_at);`,
+	// ALTER TABLE в SQLite не умеет неконстантные значения по умолчанию,
+	// поэтому время существующих ссылок проставляется отдельно
+	`alter table url add column version integer not null default 1;
+	alter table url add column created_at integer not null default 0;
+	alter table url add column updated_at integer not null default 0;
+	update url set created_at = unixepoch(), updated_at = unixepoch();`,
+	`alter table url add column created_by text not null default '';
+	alter table url add column alias_generated integer not null default 0;`,
+	`alter table url add column canonical_url text;
+	create index if not exists url_canonical_url_idx on url(canonical_url) where alias_generated;`,
+	// Поиск по URL идет по canonical_url, у старых ссылок ключом
+	// становится исходный URL
+	`update url set canonical_url = url where canonical_url is null;
+	drop index if exists url_canonical_url_idx;
+	create index if not exists url_canonical_url_idx on url(canonical_url);`,
+	// Ключом алиасов старых ссылок становится сам алиас
+	`alter table url add column alias_key text not null default '';
+	update url set alias_key = alias;
+	create unique index if not exists url_alias_key_idx on url(alias_key);`,
+	// scopes хранятся через запятую, время - как unix-время в секундах
+	`create table if not exists api_key (
+		api_key_id integer primary key autoincrement,
+		name text not null,
+		prefix text not null unique,
+		key_hash blob not null,
+		scopes text not null default '',
+		created_at integer not null default (unixepoch()),
+		created_by text not null default '',
+		expires_at integer,
+		last_used_at integer,
+		revoked_at integer
+	);`,
+	// Каноническую форму старых ссылок строит приложение, см. CanonicalizeURLs
+	`alter table url add column canonical_pending integer not null default 0;
+	update url set canonical_pending = 1 where canonical_url = url;
+	create index if not exists url_canonical_pending_idx on url(url_id) where canonical_pending;`,
+}
+
+func New(storagePath string) (*Storage, error) {
+	const operationPlace = "storage.sqlite.New"
+
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on", storagePath)
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	// SQLite не поддерживает параллельную запись,
+	// поэтому все запросы идут через одно соединение.
+	db.SetMaxOpenConns(1)
+
+	err = migrate(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	const operationPlace = "storage.sqlite.migrate"
+
+	var version int
+	err := db.QueryRow(`pragma user_version`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		if _, err = tx.Exec(migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: migration %d: %w", operationPlace, i+1, err)
+		}
+		if _, err = tx.Exec(fmt.Sprintf(`pragma user_version = %d`, i+1)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: migration %d: %w", operationPlace, i+1, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("%s: migration %d: %w", operationPlace, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// ReserveURLIds резервирует n значений url_id для ссылок, которые
+// будут сохранены позже. Зарезервированные id не выдаются повторно.
+func (s *Storage) ReserveURLIds(ctx context.Context, n int) ([]int, error) {
+	const operationPlace = "storage.sqlite.ReserveURLIds"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// url_id с autoincrement берется из sqlite_sequence, поэтому сдвиг
+	// счетчика резервирует id и для вставок без явного url_id. Строки
+	// в sqlite_sequence нет, пока в url ничего не вставляли.
+	_, err = tx.ExecContext(ctx, `insert into sqlite_sequence(name, seq)
+		select 'url', coalesce((select max(url_id) from url), 0)
+		where not exists (select 1 from sqlite_sequence where name = 'url')`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	var last int
+	err = tx.QueryRowContext(ctx, `update sqlite_sequence set seq = seq + ? where name = 'url' returning seq`, n).Scan(&last)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = last - n + 1 + i
+	}
+	return ids, nil
+}
+
+func (s *Storage) SaveURL(ctx context.Context, urlToSave storage.URLToSave) (int, error) {
+	const operationPlace = "storage.sqlite.SaveURL"
+	var insertedId int
+	var sqliteErr sqlite3.Error
+
+	query := `insert into url(url_id, url, alias, expires_at, created_at, updated_at, created_by, alias_generated, canonical_url, alias_key, canonical_pending)
+		values (nullif(?, 0), ?, ?, ?, unixepoch(), unixepoch(), ?, ?, ?, ?, ?) returning url_id`
+	err := s.db.QueryRowContext(ctx, query, urlToSave.Id, urlToSave.URL, urlToSave.Alias, toUnix(urlToSave.ExpiresAt), urlToSave.CreatedBy, urlToSave.AliasGenerated,
+		urlToSave.Canonical(), urlToSave.Key(), urlToSave.CanonicalURL == "").Scan(&insertedId)
+
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrAliasExists)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return insertedId, nil
+}
+
+// SaveURLs сохраняет пачку ссылок в одной транзакции. Ссылки с занятым
+// алиасом пропускаются, в их результате storage.ErrAliasExists. Если atomic
+// и хотя бы одна ссылка не сохранилась, не сохраняется ни одна.
+// Ошибка возвращается, только если пачку не удалось обработать целиком.
+func (s *Storage) SaveURLs(ctx context.Context, urls []storage.URLToSave, atomic bool) ([]storage.SaveResult, error) {
+	const operationPlace = "storage.sqlite.SaveURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Без указания индекса on conflict срабатывает и на занятый алиас, и на занятый ключ
+	stmt, err := tx.PrepareContext(ctx, `insert into url(url_id, url, alias, expires_at, created_at, updated_at, created_by, alias_generated, canonical_url, alias_key, canonical_pending)
+		values (nullif(?, 0), ?, ?, ?, unixepoch(), unixepoch(), ?, ?, ?, ?, ?) on conflict do nothing returning url_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer stmt.Close()
+
+	results := make([]storage.SaveResult, len(urls))
+	failed := false
+	for i, urlToSave := range urls {
+		err := stmt.QueryRowContext(ctx, urlToSave.Id, urlToSave.URL, urlToSave.Alias, toUnix(urlToSave.ExpiresAt), urlToSave.CreatedBy, urlToSave.AliasGenerated,
+			urlToSave.Canonical(), urlToSave.Key(), urlToSave.CanonicalURL == "").Scan(&results[i].Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			results[i].Err = storage.ErrAliasExists
+			failed = true
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+	}
+	if atomic && failed {
+		storage.AbortSaveResults(results)
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return results, nil
+}
+
+// GetURLByAlias возвращает URL ссылки с ключом алиаса aliasKey.
+func (s *Storage) GetURLByAlias(ctx context.Context, aliasKey string) (string, error) {
+	const operationPlace = "storage.sqlite.GetURLByAlias"
+	var urlByAlias string
+	var expiresAt sql.NullInt64
+
+	query := `select url, expires_at from url where alias_key=?`
+	err := s.db.QueryRowContext(ctx, query, aliasKey).Scan(&urlByAlias, &expiresAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if storage.IsExpired(fromUnix(expiresAt), time.Now()) {
+		return "", storage.ErrURLExpired
+	}
+
+	return urlByAlias, nil
+}
+
+// TakenAliasKeys возвращает те из ключей алиасов keys, которые уже заняты.
+func (s *Storage) TakenAliasKeys(ctx context.Context, keys []string) ([]string, error) {
+	const operationPlace = "storage.sqlite.TakenAliasKeys"
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(keys)), ",")
+	rows, err := s.db.QueryContext(ctx, `select alias_key from url where alias_key in (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer rows.Close()
+
+	var taken []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		taken = append(taken, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return taken, nil
+}
+
+func (s *Storage) DeleteURLByAlias(ctx context.Context, alias string) (int, error) {
+	const operationPlace = "storage.sqlite.DeleteURLByAlias"
+
+	var deletedRows int
+
+	query := `delete from url where alias=? returning url_id`
+	err := s.db.QueryRowContext(ctx, query, alias).Scan(&deletedRows)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return deletedRows, nil
+}
+
+func (s *Storage) DeleteURLByURL(ctx context.Context, canonicalURL string) (int, error) {
+	const operationPlace = "storage.sqlite.DeleteURLByURL"
+
+	var deletedRows int
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `select min(url_id) from url where canonical_url=?`
+	var minId sql.NullInt64
+	err = tx.QueryRowContext(ctx, query, canonicalURL).Scan(&minId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if !minId.Valid {
+		return 0, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	deletedRows = int(minId.Int64)
+
+	_, err = tx.ExecContext(ctx, `delete from url where canonical_url=?`, canonicalURL)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return deletedRows, nil
+}
+
+// GetURLsByURL возвращает все ссылки на URL с канонической формой
+// canonicalURL в порядке создания.
+// Если таких ссылок нет, возвращается пустой список.
+func (s *Storage) GetURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	const operationPlace = "storage.sqlite.GetURLsByURL"
+
+	rows, err := s.db.QueryContext(ctx, `select `+urlRecordColumns+` from url where canonical_url=? order by url_id`, canonicalURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	records, err := collectURLRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return records, nil
+}
+
+// DeleteURLsByURL удаляет все ссылки на URL с канонической формой
+// canonicalURL и возвращает их в порядке создания. Если таких ссылок нет, возвращается storage.ErrURLNotFound.
+func (s *Storage) DeleteURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error) {
+	const operationPlace = "storage.sqlite.DeleteURLsByURL"
+
+	rows, err := s.db.QueryContext(ctx, `delete from url where canonical_url=? returning `+urlRecordColumns, canonicalURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	records, err := collectURLRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	// returning не гарантирует порядок строк
+	slices.SortFunc(records, func(a, b storage.URLRecord) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	return records, nil
+}
+
+// FindGeneratedURL возвращает самую старую бессрочную ссылку со
+// сгенерированным алиасом и канонической формой URL canonicalURL.
+// Если такой нет, возвращается storage.ErrURLNotFound.
+func (s *Storage) FindGeneratedURL(ctx context.Context, canonicalURL string) (storage.URLRecord, error) {
+	const operationPlace = "storage.sqlite.FindGeneratedURL"
+
+	query := `select ` + urlRecordColumns + ` from url
+		where canonical_url=? and alias_generated and expires_at is null
+		order by url_id limit 1`
+	rows, err := s.db.QueryContext(ctx, query, canonicalURL)
+	if err != nil {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	records, err := collectURLRecords(rows)
+	if err != nil {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if len(records) == 0 {
+		return storage.URLRecord{}, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return records[0], nil
+}
+
+// urlRecordColumns колонки url в порядке полей, которые читает collectURLRecords.
+const urlRecordColumns = "url_id, url, alias, expires_at, version, created_at, updated_at, created_by, alias_generated"
+
+// collectURLRecords читает все строки с колонками urlRecordColumns и закрывает rows.
+func collectURLRecords(rows *sql.Rows) ([]storage.URLRecord, error) {
+	defer rows.Close()
+
+	records := []storage.URLRecord{}
+	for rows.Next() {
+		var record storage.URLRecord
+		var expiresAt sql.NullInt64
+		var createdAt, updatedAt int64
+		err := rows.Scan(&record.Id, &record.URL, &record.Alias, &expiresAt, &record.Version,
+			&createdAt, &updatedAt, &record.CreatedBy, &record.AliasGenerated)
+		if err != nil {
+			return nil, err
+		}
+		record.ExpiresAt = fromUnix(expiresAt)
+		record.CreatedAt = time.Unix(createdAt, 0)
+		record.UpdatedAt = time.Unix(updatedAt, 0)
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func (s *Storage) Truncate(ctx context.Context) error {
+	const operationPlace = "storage.sqlite.Truncate"
+	query := `delete from url`
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetURLIdByURL(ctx context.Context, canonicalURL string) (int, error) {
+	const operationPlace = "storage.sqlite.GetURLIdByURL"
+	var urlId int
+
+	query := `select url_id from url where canonical_url=? order by url_id limit 1`
+	err := s.db.QueryRowContext(ctx, query, canonicalURL).Scan(&urlId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return urlId, nil
+}
+
+// CanonicalizeURLs строит каноническую форму URL функцией canonical для
+// ссылок, у которых ее еще нет: созданных до появления канонической формы
+// или сохраненных без CanonicalURL. Ссылки обрабатываются пачками по
+// batchSize, каждая в своей транзакции. Возвращает число обработанных ссылок.
+func (s *Storage) CanonicalizeURLs(ctx context.Context, canonical func(rawURL string) string, batchSize int) (int, error) {
+	const operationPlace = "storage.sqlite.CanonicalizeURLs"
+
+	total := 0
+	for {
+		processed, err := s.canonicalizeBatch(ctx, canonical, batchSize)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		if processed == 0 {
+			return total, nil
+		}
+		total += processed
+	}
+}
+
+func (s *Storage) canonicalizeBatch(ctx context.Context, canonical func(rawURL string) string, batchSize int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `select url_id, url from url where canonical_pending order by url_id limit ?`, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	canonicalURLs := make(map[int]string, batchSize)
+	for rows.Next() {
+		var id int
+		var rawURL string
+		if err := rows.Scan(&id, &rawURL); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		canonicalURLs[id] = canonical(rawURL)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, canonicalURL := range canonicalURLs {
+		if _, err := tx.ExecContext(ctx, `update url set canonical_url = ?, canonical_pending = 0 where url_id = ?`, canonicalURL, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(canonicalURLs), nil
+}
+
+// DeleteExpiredURLs удаляет ссылки, срок жизни которых истек к моменту now.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int, error) {
+	const operationPlace = "storage.sqlite.DeleteExpiredURLs"
+
+	query := `delete from url where expires_at <= ?`
+	res, err := s.db.ExecContext(ctx, query, now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return int(deleted), nil
+}
+
+// UpdateURL меняет ссылку по алиасу и увеличивает ее версию. Если
+// expectedVersion больше нуля, ссылка меняется, только если ее текущая
+// версия совпадает с expectedVersion, иначе возвращается
+// storage.ErrVersionMismatch.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, update storage.URLUpdate, expectedVersion int64) (storage.URLRecord, error) {
+	const operationPlace = "storage.sqlite.UpdateURL"
+	var record storage.URLRecord
+	var expiresAt sql.NullInt64
+	var createdAt, updatedAt int64
+
+	query := `update url set
+			url = coalesce(nullif(?, ''), url),
+			canonical_url = coalesce(nullif(?, ''), canonical_url),
+			expires_at = case when ? then ? else expires_at end,
+			version = version + 1,
+			updated_at = unixepoch()
+		where alias=? and (? = 0 or version = ?)
+		returning url_id, url, alias, expires_at, version, created_at, updated_at, created_by, alias_generated`
+	err := s.db.QueryRowContext(ctx, query, update.URL, update.Canonical(), update.SetExpiresAt, toUnix(update.ExpiresAt),
+		alias, expectedVersion, expectedVersion).
+		Scan(&record.Id, &record.URL, &record.Alias, &expiresAt, &record.Version, &createdAt, &updatedAt, &record.CreatedBy, &record.AliasGenerated)
+	if err == nil {
+		record.ExpiresAt = fromUnix(expiresAt)
+		record.CreatedAt = time.Unix(createdAt, 0)
+		record.UpdatedAt = time.Unix(updatedAt, 0)
+		return record, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return record, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	// Ничего не обновилось: либо ссылки нет, либо версия не совпала
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `select exists(select 1 from url where alias=?)`, alias).Scan(&exists)
+	if err != nil {
+		return record, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if !exists {
+		return record, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	return record, fmt.Errorf("%s: %w", operationPlace, storage.ErrVersionMismatch)
+}
+
+// ArchiveExpiredURLs переносит ссылки, срок жизни которых
+// истек к моменту now, в таблицу url_archive.
+func (s *Storage) ArchiveExpiredURLs(ctx context.Context, now time.Time) (int, error) {
+	const operationPlace = "storage.sqlite.ArchiveExpiredURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `insert or ignore into url_archive(url_id, alias, url, expires_at)
+		select url_id, alias, url, expires_at from url where expires_at <= ?`
+	if _, err = tx.ExecContext(ctx, query, now.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	res, err := tx.ExecContext(ctx, `delete from url where expires_at <= ?`, now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return int(archived), nil
+}
+
+// SaveClick сохраняет переход по алиасу.
+func (s *Storage) SaveClick(ctx context.Context, click storage.Click) error {
+	const operationPlace = "storage.sqlite.SaveClick"
+
+	query := `insert into click(url_id, clicked_at, referrer, user_agent, request_id, ip)
+		select url_id, ?, ?, ?, ?, ? from url where alias_key=?`
+	res, err := s.db.ExecContext(ctx, query, click.ClickedAt.Unix(), click.Referrer, click.UserAgent, click.RequestID, click.IP, click.Alias)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	if inserted == 0 {
+		return fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+
+	return nil
+}
+
+// SaveClicks сохраняет пачку переходов в одной транзакции. Переходы
+// по алиасам, которых уже нет в хранилище, пропускаются. Возвращает
+// число сохраненных переходов.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) (int, error) {
+	const operationPlace = "storage.sqlite.SaveClicks"
+	if len(clicks) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, `insert into click(url_id, clicked_at, referrer, user_agent, request_id, ip)
+		select url_id, ?, ?, ?, ?, ? from url where alias_key=?`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer stmt.Close()
+
+	saved := 0
+	for _, click := range clicks {
+		res, err := stmt.ExecContext(ctx, click.ClickedAt.Unix(), click.Referrer, click.UserAgent, click.RequestID, click.IP, click.Alias)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		saved += int(inserted)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return saved, nil
+}
+
+// GetURLInfo возвращает ссылку по алиасу вместе с количеством
+// переходов. В отличие от GetURLByAlias истекшие ссылки тоже возвращаются.
+func (s *Storage) GetURLInfo(ctx context.Context, alias string) (storage.URLListItem, error) {
+	const operationPlace = "storage.sqlite.GetURLInfo"
+	var item storage.URLListItem
+	var expiresAt sql.NullInt64
+	var createdAt, updatedAt int64
+
+	query := `select url_id, url, alias, expires_at, version, created_at, updated_at, created_by, alias_generated,
+			(select count(*) from click where click.url_id = url.url_id)
+		from url where alias=?`
+	err := s.db.QueryRowContext(ctx, query, alias).Scan(&item.Id, &item.URL, &item.Alias, &expiresAt, &item.Version,
+		&createdAt, &updatedAt, &item.CreatedBy, &item.AliasGenerated, &item.Clicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return item, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return item, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	item.ExpiresAt = fromUnix(expiresAt)
+	item.CreatedAt = time.Unix(createdAt, 0)
+	item.UpdatedAt = time.Unix(updatedAt, 0)
+
+	return item, nil
+}
+
+// ListURLs возвращает страницу ссылок, подходящих под opts.Filter,
+// в порядке opts.SortBy начиная после opts.After.
+func (s *Storage) ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.URLListItem, error) {
+	const operationPlace = "storage.sqlite.ListURLs"
+
+	var where []string
+	var args []any
+
+	filter := opts.Filter
+	if filter.URLContains != "" {
+		where = append(where, "instr(lower(u.url), lower(?)) > 0")
+		args = append(args, filter.URLContains)
+	}
+	if filter.Domain != "" {
+		domain := strings.ToLower(filter.Domain)
+		where = append(where, "(url_host(u.url) = ? or substr(url_host(u.url), -length(?) - 1) = '.' || ?)")
+		args = append(args, domain, domain, domain)
+	}
+	if filter.AliasPrefix != "" {
+		where = append(where, "substr(u.alias, 1, length(?)) = ?")
+		args = append(args, filter.AliasPrefix, filter.AliasPrefix)
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, "u.created_at >= ?")
+		args = append(args, filter.CreatedFrom.Unix())
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, "u.created_at < ?")
+		args = append(args, filter.CreatedTo.Unix())
+	}
+
+	sortExpr := "u.created_at"
+	switch opts.SortBy {
+	case storage.SortAlias:
+		sortExpr = "u.alias"
+	case storage.SortClicks:
+		sortExpr = "coalesce(c.clicks, 0)"
+	}
+	direction, compare := "asc", ">"
+	if opts.Desc {
+		direction, compare = "desc", "<"
+	}
+	if opts.After != nil {
+		var value any = opts.After.CreatedAt.Unix()
+		switch opts.SortBy {
+		case storage.SortAlias:
+			value = opts.After.Alias
+		case storage.SortClicks:
+			value = opts.After.Clicks
+		}
+		where = append(where, fmt.Sprintf("(%s, u.url_id) %s (?, ?)", sortExpr, compare))
+		args = append(args, value, opts.After.Id)
+	}
+
+	query := `select u.url_id, u.url, u.alias, u.expires_at, u.version, u.created_at, u.updated_at,
+			u.created_by, u.alias_generated, coalesce(c.clicks, 0)
+		from url u
+		left join (select url_id, count(*) as clicks from click group by url_id) c on c.url_id = u.url_id`
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+	query += fmt.Sprintf(" order by %[1]s %[2]s, u.url_id %[2]s", sortExpr, direction)
+	if opts.Limit > 0 {
+		query += " limit ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer rows.Close()
+
+	var items []storage.URLListItem
+	for rows.Next() {
+		var item storage.URLListItem
+		var expiresAt sql.NullInt64
+		var createdAt, updatedAt int64
+		err := rows.Scan(&item.Id, &item.URL, &item.Alias, &expiresAt, &item.Version, &createdAt, &updatedAt,
+			&item.CreatedBy, &item.AliasGenerated, &item.Clicks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		item.ExpiresAt = fromUnix(expiresAt)
+		item.CreatedAt = time.Unix(createdAt, 0)
+		item.UpdatedAt = time.Unix(updatedAt, 0)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return items, nil
+}
+
+// GetStats возвращает статистику переходов по алиасу. Переходы по дням
+// считаются начиная с since, в топах не больше top значений.
+func (s *Storage) GetStats(ctx context.Context, alias string, since time.Time, top int) (storage.Stats, error) {
+	const operationPlace = "storage.sqlite.GetStats"
+	var stats storage.Stats
+	var urlId int
+
+	err := s.db.QueryRowContext(ctx, `select url_id from url where alias=?`, alias).Scan(&urlId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stats, fmt.Errorf("%s: %w", operationPlace, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	err = s.db.QueryRowContext(ctx, `select count(*) from click where url_id=?`, urlId).Scan(&stats.TotalClicks)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	query := `select date(clicked_at, 'unixepoch'), count(*)
+		from click where url_id=? and clicked_at >= ?
+		group by 1 order by 1`
+	rows, err := s.db.QueryContext(ctx, query, urlId, since.Unix())
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rawDay string
+		var day storage.DayClicks
+		if err := rows.Scan(&rawDay, &day.Clicks); err != nil {
+			return stats, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		day.Day, err = time.Parse(time.DateOnly, rawDay)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		stats.ClicksPerDay = append(stats.ClicksPerDay, day)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	stats.TopReferrers, err = s.topClickValues(ctx, "referrer", urlId, top)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	stats.TopUserAgents, err = s.topClickValues(ctx, "user_agent", urlId, top)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return stats, nil
+}
+
+// topClickValues возвращает самые частые непустые значения колонки
+// column таблицы click. column подставляется в запрос как есть,
+// поэтому передавать туда можно только константы.
+func (s *Storage) topClickValues(ctx context.Context, column string, urlId int, top int) ([]storage.ValueClicks, error) {
+	query := fmt.Sprintf(`select %[1]s, count(*) from click
+		where url_id=? and %[1]s <> ''
+		group by %[1]s order by count(*) desc, %[1]s limit ?`, column)
+	rows, err := s.db.QueryContext(ctx, query, urlId, top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []storage.ValueClicks
+	for rows.Next() {
+		var value storage.ValueClicks
+		if err := rows.Scan(&value.Value, &value.Clicks); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func toUnix(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
+func fromUnix(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0)
+	return &t
+}
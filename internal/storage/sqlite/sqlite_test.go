@@ -0,0 +1,59 @@
+//go:build smoke
+
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/sqlite"
+	"url-shortener/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		return newStorage(t)
+	})
+}
+
+func newStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+	strg, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"))
+	if err != nil {
+		t.Fatalf("cannot create storage: (%v)", err)
+	}
+	t.Cleanup(func() { _ = strg.Close() })
+	return strg
+}
+
+// TestReopenKeepsData проверяет, что повторное открытие
+// файла БД не пересоздает схему и сохраняет данные.
+func TestReopenKeepsData(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	strg, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("cannot create storage: (%v)", err)
+	}
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	if err != nil {
+		t.Errorf("cannot save url: (%v)", err)
+	}
+	_ = strg.Close()
+
+	strg, err = sqlite.New(path)
+	if err != nil {
+		t.Fatalf("cannot reopen storage: (%v)", err)
+	}
+	defer strg.Close()
+
+	url, err := strg.GetURLByAlias(ctx, "alias")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if url != "http://qwe.ru" {
+		t.Errorf("URL is not equal. Got %s, expected %s", url, "http://qwe.ru")
+	}
+}
@@ -2,9 +2,182 @@ package storage
 
 import (
 	"errors"
+	"time"
 )
 
 var (
 	ErrURLNotFound = errors.New("url not found")
 	ErrAliasExists = errors.New("alias exists")
+	ErrURLExpired  = errors.New("url expired")
+	// ErrUnavailable хранилище перегружено и не может выполнить запрос
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrVersionMismatch ссылку успели изменить после того,
+	// как клиент получил ее версию
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrBatchAborted ссылка не сохранена, потому что не сохранилась
+	// другая ссылка из той же пачки
+	ErrBatchAborted = errors.New("batch aborted")
+	// ErrAPIKeyNotFound ключа API с таким id или префиксом нет
+	ErrAPIKeyNotFound = errors.New("api key not found")
 )
+
+// URLToSave новая запись для сохранения.
+type URLToSave struct {
+	// Id url_id, зарезервированный через ReserveURLIds,
+	// 0 - хранилище назначит его само
+	Id    int
+	URL   string
+	Alias string
+	// ExpiresAt момент, после которого алиас перестает работать.
+	// nil - бессрочная ссылка.
+	ExpiresAt *time.Time
+	// CreatedBy пользователь, создавший ссылку
+	CreatedBy string
+	// AliasGenerated алиас сгенерирован, а не задан пользователем
+	AliasGenerated bool
+	// CanonicalURL каноническая форма URL, по которой ссылка ищется
+	// по URL. Пустое значение - используется URL как есть, пока
+	// каноническую форму не построит CanonicalizeURLs хранилища.
+	CanonicalURL string
+	// AliasKey ключ алиаса, по которому ищется ссылка при редиректе.
+	// Ключи, как и алиасы, уникальны. Пустое значение - используется
+	// Alias как есть.
+	AliasKey string
+}
+
+// Canonical возвращает ключ для поиска ссылки по URL.
+func (u URLToSave) Canonical() string {
+	return canonicalOrURL(u.CanonicalURL, u.URL)
+}
+
+// Key возвращает ключ алиаса.
+func (u URLToSave) Key() string {
+	if u.AliasKey == "" {
+		return u.Alias
+	}
+	return u.AliasKey
+}
+
+// SaveResult результат сохранения одной ссылки из пачки:
+// Id сохраненной ссылки или ошибка, из-за которой она не сохранилась.
+type SaveResult struct {
+	Id  int
+	Err error
+}
+
+// AbortSaveResults помечает сохраненные ссылки пачки как
+// несохраненные после отката транзакции.
+func AbortSaveResults(results []SaveResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = SaveResult{Err: ErrBatchAborted}
+		}
+	}
+}
+
+// URLRecord сохраненная ссылка вместе со служебными полями.
+type URLRecord struct {
+	Id        int
+	URL       string
+	Alias     string
+	ExpiresAt *time.Time
+	// Version увеличивается при каждом изменении ссылки
+	Version        int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	CreatedBy      string
+	AliasGenerated bool
+}
+
+// URLUpdate изменения ссылки. Нулевые значения
+// полей означают, что поле не меняется.
+type URLUpdate struct {
+	URL string
+	// SetExpiresAt - заменить срок жизни на ExpiresAt,
+	// nil в ExpiresAt делает ссылку бессрочной
+	SetExpiresAt bool
+	ExpiresAt    *time.Time
+	// CanonicalURL каноническая форма нового URL, см. URLToSave
+	CanonicalURL string
+}
+
+// Canonical возвращает новый ключ для поиска ссылки по URL
+// или пустую строку, если URL не меняется.
+func (u URLUpdate) Canonical() string {
+	if u.URL == "" {
+		return ""
+	}
+	return canonicalOrURL(u.CanonicalURL, u.URL)
+}
+
+func canonicalOrURL(canonical string, url string) string {
+	if canonical != "" {
+		return canonical
+	}
+	return url
+}
+
+// IsExpired сообщает, истек ли срок жизни ссылки к моменту now.
+func IsExpired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(now)
+}
+
+// Click переход по короткой ссылке.
+type Click struct {
+	// Alias ключ алиаса, по которому был переход, см. URLToSave.AliasKey
+	Alias     string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	RequestID string
+	// IP анонимизированный адрес клиента
+	IP string
+}
+
+// Stats статистика переходов по алиасу.
+type Stats struct {
+	TotalClicks   int
+	ClicksPerDay  []DayClicks
+	TopReferrers  []ValueClicks
+	TopUserAgents []ValueClicks
+}
+
+// DayClicks количество переходов за сутки (UTC).
+type DayClicks struct {
+	Day    time.Time
+	Clicks int
+}
+
+// ValueClicks количество переходов с одним значением
+// поля, например с одним referrer.
+type ValueClicks struct {
+	Value  string
+	Clicks int
+}
+
+// APIKeyToSave новый ключ API. Сам ключ не хранится, только его хеш.
+type APIKeyToSave struct {
+	Name string
+	// Prefix открытая часть ключа, по которой он ищется. Уникален.
+	Prefix string
+	Hash   []byte
+	Scopes []string
+	// ExpiresAt nil - бессрочный ключ
+	ExpiresAt *time.Time
+	CreatedBy string
+}
+
+// APIKey сохраненный ключ API.
+type APIKey struct {
+	Id         int
+	Name       string
+	Prefix     string
+	Hash       []byte
+	Scopes     []string
+	CreatedAt  time.Time
+	CreatedBy  string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	// RevokedAt nil - ключ не отозван
+	RevokedAt *time.Time
+}
@@ -0,0 +1,1218 @@
+// Package storagetest содержит общий набор тестов, которому
+// должна соответствовать любая реализация хранилища.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Storage interface {
+	SaveURL(ctx context.Context, urlToSave storage.URLToSave) (int, error)
+	SaveURLs(ctx context.Context, urls []storage.URLToSave, atomic bool) ([]storage.SaveResult, error)
+	ReserveURLIds(ctx context.Context, n int) ([]int, error)
+	GetURLByAlias(ctx context.Context, aliasKey string) (string, error)
+	TakenAliasKeys(ctx context.Context, keys []string) ([]string, error)
+	DeleteURLByAlias(ctx context.Context, alias string) (int, error)
+	DeleteURLByURL(ctx context.Context, canonicalURL string) (int, error)
+	GetURLIdByURL(ctx context.Context, canonicalURL string) (int, error)
+	CanonicalizeURLs(ctx context.Context, canonical func(rawURL string) string, batchSize int) (int, error)
+	GetURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error)
+	FindGeneratedURL(ctx context.Context, canonicalURL string) (storage.URLRecord, error)
+	DeleteURLsByURL(ctx context.Context, canonicalURL string) ([]storage.URLRecord, error)
+	Truncate(ctx context.Context) error
+	DeleteExpiredURLs(ctx context.Context, now time.Time) (int, error)
+	ArchiveExpiredURLs(ctx context.Context, now time.Time) (int, error)
+	SaveClick(ctx context.Context, click storage.Click) error
+	SaveClicks(ctx context.Context, clicks []storage.Click) (int, error)
+	GetStats(ctx context.Context, alias string, since time.Time, top int) (storage.Stats, error)
+	UpdateURL(ctx context.Context, alias string, update storage.URLUpdate, expectedVersion int64) (storage.URLRecord, error)
+	ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.URLListItem, error)
+	GetURLInfo(ctx context.Context, alias string) (storage.URLListItem, error)
+	SaveAPIKey(ctx context.Context, key storage.APIKeyToSave) (storage.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (storage.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]storage.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int, now time.Time) (storage.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error
+}
+
+// Factory возвращает пустое хранилище. Вызывается
+// для каждого теста из набора.
+type Factory func(t *testing.T) Storage
+
+// Run прогоняет набор тестов на хранилище, созданном newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, strg Storage)
+	}{
+		{"SaveAndGetURL", testSaveAndGetURL},
+		{"SaveReturnsDistinctIds", testSaveReturnsDistinctIds},
+		{"DuplicateAlias", testDuplicateAlias},
+		{"GetURLNotFound", testGetURLNotFound},
+		{"SaveURLsBestEffort", testSaveURLsBestEffort},
+		{"SaveURLsAtomic", testSaveURLsAtomic},
+		{"DeleteURLByAlias", testDeleteURLByAlias},
+		{"DeleteURLByURL", testDeleteURLByURL},
+		{"GetURLIdByURL", testGetURLIdByURL},
+		{"GetURLsByURL", testGetURLsByURL},
+		{"DeleteURLsByURL", testDeleteURLsByURL},
+		{"FindGeneratedURL", testFindGeneratedURL},
+		{"CanonicalURL", testCanonicalURL},
+		{"CanonicalizeURLs", testCanonicalizeURLs},
+		{"AliasKey", testAliasKey},
+		{"TakenAliasKeys", testTakenAliasKeys},
+		{"APIKeys", testAPIKeys},
+		{"RevokeAPIKey", testRevokeAPIKey},
+		{"TouchAPIKey", testTouchAPIKey},
+		{"ReserveURLIds", testReserveURLIds},
+		{"Truncate", testTruncate},
+		{"ExpiredURL", testExpiredURL},
+		{"DeleteExpiredURLs", testDeleteExpiredURLs},
+		{"ArchiveExpiredURLs", testArchiveExpiredURLs},
+		{"ClickStats", testClickStats},
+		{"ClickStatsNotFound", testClickStatsNotFound},
+		{"ClickStatsAfterDelete", testClickStatsAfterDelete},
+		{"SaveClicksBatch", testSaveClicksBatch},
+		{"UpdateURL", testUpdateURL},
+		{"UpdateURLExpiresAt", testUpdateURLExpiresAt},
+		{"UpdateURLVersionMismatch", testUpdateURLVersionMismatch},
+		{"UpdateURLNotFound", testUpdateURLNotFound},
+		{"GetURLInfo", testGetURLInfo},
+		{"GetURLInfoExpired", testGetURLInfoExpired},
+		{"GetURLInfoNotFound", testGetURLInfoNotFound},
+		{"ListURLsSort", testListURLsSort},
+		{"ListURLsPagination", testListURLsPagination},
+		{"ListURLsFilter", testListURLsFilter},
+		{"ConcurrentSaveAndGet", testConcurrentSaveAndGet},
+		{"ConcurrentDuplicateAlias", testConcurrentDuplicateAlias},
+		{"ConcurrentUpdateSameVersion", testConcurrentUpdateSameVersion},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStorage(t))
+		})
+	}
+}
+
+// testSaveAndGetURL проверяет, что сохраненный
+// URL можно получить по алиасу.
+func testSaveAndGetURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+	assert.Positive(t, id)
+
+	url, err := strg.GetURLByAlias(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, "http://qwe.ru", url)
+}
+
+// testSaveReturnsDistinctIds проверяет, что
+// каждая сохраненная запись получает свой id.
+func testSaveReturnsDistinctIds(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	id1, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias1"})
+	require.NoError(t, err)
+	id2, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias2"})
+	require.NoError(t, err)
+
+	assert.NotEqual(t, id1, id2)
+}
+
+// testDuplicateAlias проверяет, что повторное сохранение алиаса
+// возвращает storage.ErrAliasExists и не меняет запись.
+func testDuplicateAlias(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "alias"})
+	assert.ErrorIs(t, err, storage.ErrAliasExists)
+	assert.Zero(t, id)
+
+	url, err := strg.GetURLByAlias(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, "http://qwe.ru", url)
+}
+
+// testSaveURLsBestEffort проверяет, что в пачке сохраняются все ссылки,
+// кроме тех, чей алиас уже занят или повторяется в самой пачке.
+func testSaveURLsBestEffort(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "taken"})
+	require.NoError(t, err)
+
+	results, err := strg.SaveURLs(ctx, []storage.URLToSave{
+		{URL: "http://asd.ru", Alias: "first", CreatedBy: "admin"},
+		{URL: "http://zxc.ru", Alias: "taken"},
+		{URL: "http://rty.ru", Alias: "first"},
+		{URL: "http://fgh.ru", Alias: "second"},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.NoError(t, results[0].Err)
+	assert.NotZero(t, results[0].Id)
+	assert.ErrorIs(t, results[1].Err, storage.ErrAliasExists)
+	assert.ErrorIs(t, results[2].Err, storage.ErrAliasExists)
+	assert.NoError(t, results[3].Err)
+	assert.NotEqual(t, results[0].Id, results[3].Id)
+
+	url, err := strg.GetURLByAlias(ctx, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "http://asd.ru", url)
+	url, err = strg.GetURLByAlias(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "http://qwe.ru", url)
+	_, err = strg.GetURLByAlias(ctx, "second")
+	assert.NoError(t, err)
+}
+
+// testSaveURLsAtomic проверяет, что атомарная пачка
+// не сохраняется, если не сохранилась хотя бы одна ссылка.
+func testSaveURLsAtomic(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "taken"})
+	require.NoError(t, err)
+
+	results, err := strg.SaveURLs(ctx, []storage.URLToSave{
+		{URL: "http://asd.ru", Alias: "first"},
+		{URL: "http://zxc.ru", Alias: "taken"},
+	}, true)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+	assert.Zero(t, results[0].Id)
+	assert.ErrorIs(t, results[1].Err, storage.ErrAliasExists)
+
+	_, err = strg.GetURLByAlias(ctx, "first")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	results, err = strg.SaveURLs(ctx, []storage.URLToSave{
+		{URL: "http://asd.ru", Alias: "first"},
+		{URL: "http://zxc.ru", Alias: "second"},
+	}, true)
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		assert.NotZero(t, result.Id)
+	}
+	_, err = strg.GetURLByAlias(ctx, "second")
+	assert.NoError(t, err)
+}
+
+// testGetURLNotFound проверяет, что поиск по несуществующему
+// алиасу возвращает storage.ErrURLNotFound.
+func testGetURLNotFound(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	url, err := strg.GetURLByAlias(ctx, "alias")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	assert.Empty(t, url)
+}
+
+// testDeleteURLByAlias проверяет, что удаление по алиасу возвращает
+// id записи, а повторное удаление - storage.ErrURLNotFound.
+func testDeleteURLByAlias(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	insertedId, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	deletedId, err := strg.DeleteURLByAlias(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, insertedId, deletedId)
+
+	_, err = strg.GetURLByAlias(ctx, "alias")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = strg.DeleteURLByAlias(ctx, "alias")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+// testDeleteURLByURL проверяет, что удаление по URL удаляет
+// все алиасы этого URL и не трогает остальные записи.
+func testDeleteURLByURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	firstId, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias1"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias2"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "alias3"})
+	require.NoError(t, err)
+
+	deletedId, err := strg.DeleteURLByURL(ctx, "http://qwe.ru")
+	require.NoError(t, err)
+	assert.Equal(t, firstId, deletedId)
+
+	for _, alias := range []string{"alias1", "alias2"} {
+		_, err = strg.GetURLByAlias(ctx, alias)
+		assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	}
+	url, err := strg.GetURLByAlias(ctx, "alias3")
+	require.NoError(t, err)
+	assert.Equal(t, "http://asd.ru", url)
+
+	_, err = strg.DeleteURLByURL(ctx, "http://qwe.ru")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+// testGetURLsByURL проверяет, что по URL находятся
+// все его алиасы в порядке создания.
+func testGetURLsByURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	for _, alias := range []string{"alias1", "alias2"} {
+		_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: alias, CreatedBy: "admin"})
+		require.NoError(t, err)
+	}
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "alias3"})
+	require.NoError(t, err)
+
+	records, err := strg.GetURLsByURL(ctx, "http://qwe.ru")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "alias1", records[0].Alias)
+	assert.Equal(t, "alias2", records[1].Alias)
+	assert.Less(t, records[0].Id, records[1].Id)
+	assert.Equal(t, "http://qwe.ru", records[1].URL)
+	assert.Equal(t, "admin", records[1].CreatedBy)
+
+	records, err = strg.GetURLsByURL(ctx, "http://zxc.ru")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+// testDeleteURLsByURL проверяет, что удаление по URL
+// удаляет и возвращает все его алиасы.
+func testDeleteURLsByURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	for _, alias := range []string{"alias1", "alias2"} {
+		_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: alias})
+		require.NoError(t, err)
+	}
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "alias3"})
+	require.NoError(t, err)
+
+	records, err := strg.DeleteURLsByURL(ctx, "http://qwe.ru")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alias1", "alias2"}, []string{records[0].Alias, records[1].Alias})
+
+	_, err = strg.GetURLByAlias(ctx, "alias1")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = strg.GetURLByAlias(ctx, "alias3")
+	assert.NoError(t, err)
+
+	_, err = strg.DeleteURLsByURL(ctx, "http://qwe.ru")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+// testFindGeneratedURL проверяет, что дубль ищется по каноническому
+// URL только среди бессрочных ссылок со сгенерированным алиасом.
+func testFindGeneratedURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru/news", Alias: "custom"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru/news", Alias: "temporary", AliasGenerated: true, ExpiresAt: &future})
+	require.NoError(t, err)
+
+	_, err = strg.FindGeneratedURL(ctx, "http://qwe.ru/news")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{URL: "HTTP://QWE.ru:80/news/", Alias: "generated", AliasGenerated: true,
+		CanonicalURL: "http://qwe.ru/news"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru/news", Alias: "generated2", AliasGenerated: true})
+	require.NoError(t, err)
+
+	record, err := strg.FindGeneratedURL(ctx, "http://qwe.ru/news")
+	require.NoError(t, err)
+	assert.Equal(t, id, record.Id)
+	assert.Equal(t, "generated", record.Alias)
+
+	// После смены URL ссылка ищется по новому адресу
+	_, err = strg.UpdateURL(ctx, "generated", storage.URLUpdate{URL: "http://ASD.ru/", CanonicalURL: "http://asd.ru"}, 0)
+	require.NoError(t, err)
+	record, err = strg.FindGeneratedURL(ctx, "http://asd.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "generated", record.Alias)
+	record, err = strg.FindGeneratedURL(ctx, "http://qwe.ru/news")
+	require.NoError(t, err)
+	assert.Equal(t, "generated2", record.Alias)
+}
+
+// testCanonicalURL проверяет, что поиск по URL идет по канонической
+// форме, а переход - по исходному URL.
+func testCanonicalURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://QWE.ru/news/?utm_source=tg", Alias: "alias1",
+		CanonicalURL: "http://qwe.ru/news"})
+	require.NoError(t, err)
+	results, err := strg.SaveURLs(ctx, []storage.URLToSave{
+		{URL: "http://qwe.ru/news", Alias: "alias2", CanonicalURL: "http://qwe.ru/news"},
+	}, false)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+
+	url, err := strg.GetURLByAlias(ctx, "alias1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://QWE.ru/news/?utm_source=tg", url)
+
+	foundId, err := strg.GetURLIdByURL(ctx, "http://qwe.ru/news")
+	require.NoError(t, err)
+	assert.Equal(t, id, foundId)
+	_, err = strg.GetURLIdByURL(ctx, "http://QWE.ru/news/?utm_source=tg")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	records, err := strg.GetURLsByURL(ctx, "http://qwe.ru/news")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "http://QWE.ru/news/?utm_source=tg", records[0].URL)
+
+	// Смена URL меняет и ключ поиска
+	_, err = strg.UpdateURL(ctx, "alias2", storage.URLUpdate{URL: "http://asd.ru/?fbclid=1", CanonicalURL: "http://asd.ru"}, 0)
+	require.NoError(t, err)
+	records, err = strg.DeleteURLsByURL(ctx, "http://asd.ru")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "alias2", records[0].Alias)
+
+	deletedId, err := strg.DeleteURLByURL(ctx, "http://qwe.ru/news")
+	require.NoError(t, err)
+	assert.Equal(t, id, deletedId)
+}
+
+// testCanonicalizeURLs проверяет, что ссылки, сохраненные без
+// канонической формы, как до ее появления, после CanonicalizeURLs
+// находятся по канонической форме, а остальные ссылки не меняются.
+func testCanonicalizeURLs(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	canonical := func(rawURL string) string {
+		return strings.TrimSuffix(strings.ToLower(rawURL), "/")
+	}
+
+	legacyIds := make([]int, 0, 3)
+	for i, rawURL := range []string{"http://qwe.ru/", "http://QWE.ru/news/", "http://asd.ru"} {
+		id, err := strg.SaveURL(ctx, storage.URLToSave{URL: rawURL, Alias: fmt.Sprintf("legacy%d", i)})
+		require.NoError(t, err)
+		legacyIds = append(legacyIds, id)
+	}
+	freshId, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://ZXC.ru/", Alias: "fresh", CanonicalURL: "http://ZXC.ru/"})
+	require.NoError(t, err)
+
+	_, err = strg.GetURLIdByURL(ctx, "http://qwe.ru")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	processed, err := strg.CanonicalizeURLs(ctx, canonical, 2)
+	require.NoError(t, err)
+	assert.Equal(t, len(legacyIds), processed)
+
+	for i, canonicalURL := range []string{"http://qwe.ru", "http://qwe.ru/news", "http://asd.ru"} {
+		id, err := strg.GetURLIdByURL(ctx, canonicalURL)
+		require.NoError(t, err)
+		assert.Equal(t, legacyIds[i], id)
+	}
+	id, err := strg.GetURLIdByURL(ctx, "http://ZXC.ru/")
+	require.NoError(t, err)
+	assert.Equal(t, freshId, id)
+	// Редирект идет по исходному URL
+	url, err := strg.GetURLByAlias(ctx, "legacy0")
+	require.NoError(t, err)
+	assert.Equal(t, "http://qwe.ru/", url)
+
+	processed, err = strg.CanonicalizeURLs(ctx, canonical, 2)
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+}
+
+// testAliasKey проверяет, что ссылка ищется по ключу алиаса,
+// а ключ, как и алиас, нельзя занять дважды.
+func testAliasKey(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "Alias0", AliasKey: "aliaso"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "ALIASO", AliasKey: "aliaso"})
+	assert.ErrorIs(t, err, storage.ErrAliasExists)
+	results, err := strg.SaveURLs(ctx, []storage.URLToSave{
+		{URL: "http://asd.ru", Alias: "aliasO", AliasKey: "aliaso"},
+		{URL: "http://zxc.ru", Alias: "other1", AliasKey: "otherl"},
+		{URL: "http://zxc.ru", Alias: "OTHERl", AliasKey: "otherl"},
+	}, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, storage.ErrAliasExists)
+	assert.NoError(t, results[1].Err)
+	assert.ErrorIs(t, results[2].Err, storage.ErrAliasExists)
+
+	url, err := strg.GetURLByAlias(ctx, "aliaso")
+	require.NoError(t, err)
+	assert.Equal(t, "http://qwe.ru", url)
+	_, err = strg.GetURLByAlias(ctx, "Alias0")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// Переходы тоже сохраняются по ключу
+	require.NoError(t, strg.SaveClick(ctx, storage.Click{Alias: "aliaso", ClickedAt: time.Now()}))
+	saved, err := strg.SaveClicks(ctx, []storage.Click{{Alias: "aliaso", ClickedAt: time.Now()}, {Alias: "otherl", ClickedAt: time.Now()}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, saved)
+	stats, err := strg.GetStats(ctx, "Alias0", time.Now().Add(-time.Hour), 5)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalClicks)
+
+	// После удаления ключ освобождается
+	_, err = strg.DeleteURLByAlias(ctx, "Alias0")
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "ALIASO", AliasKey: "aliaso"})
+	assert.NoError(t, err)
+}
+
+// testTakenAliasKeys проверяет, что из списка ключей
+// возвращаются только занятые.
+func testTakenAliasKeys(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "Promo", AliasKey: "promo"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "promo-2"})
+	require.NoError(t, err)
+
+	taken, err := strg.TakenAliasKeys(ctx, []string{"promo", "Promo", "promo-2", "promo-3"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"promo", "promo-2"}, taken)
+
+	taken, err = strg.TakenAliasKeys(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, taken)
+}
+
+// apiKeyPrefix возвращает префикс ключа, уникальный между запусками:
+// Truncate не удаляет ключи API.
+func apiKeyPrefix(name string) string {
+	return fmt.Sprintf("%s%d", name, time.Now().UnixNano())
+}
+
+// testAPIKeys проверяет сохранение ключей API и поиск по префиксу.
+func testAPIKeys(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	ci, err := strg.SaveAPIKey(ctx, storage.APIKeyToSave{
+		Name:      "ci",
+		Prefix:    apiKeyPrefix("ci"),
+		Hash:      []byte{1, 2, 3},
+		Scopes:    []string{"links:create", "links:read"},
+		ExpiresAt: &expiresAt,
+		CreatedBy: "admin",
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, ci.Id)
+	assert.WithinDuration(t, time.Now(), ci.CreatedAt, time.Minute)
+	bot, err := strg.SaveAPIKey(ctx, storage.APIKeyToSave{Name: "bot", Prefix: apiKeyPrefix("bot"), Hash: []byte{4}, Scopes: []string{"stats:read"}})
+	require.NoError(t, err)
+
+	found, err := strg.GetAPIKeyByPrefix(ctx, ci.Prefix)
+	require.NoError(t, err)
+	assert.Equal(t, ci.Id, found.Id)
+	assert.Equal(t, "ci", found.Name)
+	assert.Equal(t, []byte{1, 2, 3}, found.Hash)
+	assert.Equal(t, []string{"links:create", "links:read"}, found.Scopes)
+	assert.Equal(t, "admin", found.CreatedBy)
+	require.NotNil(t, found.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*found.ExpiresAt))
+	assert.Nil(t, found.LastUsedAt)
+	assert.Nil(t, found.RevokedAt)
+
+	_, err = strg.GetAPIKeyByPrefix(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+	_, err = strg.SaveAPIKey(ctx, storage.APIKeyToSave{Name: "copy", Prefix: ci.Prefix, Hash: []byte{5}})
+	assert.Error(t, err)
+
+	keys, err := strg.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	var ids []int
+	for _, key := range keys {
+		if key.Id == ci.Id || key.Id == bot.Id {
+			ids = append(ids, key.Id)
+		}
+	}
+	assert.Equal(t, []int{ci.Id, bot.Id}, ids)
+}
+
+// testRevokeAPIKey проверяет, что повторный отзыв ключа не меняет
+// время первого.
+func testRevokeAPIKey(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	key, err := strg.SaveAPIKey(ctx, storage.APIKeyToSave{Name: "ci", Prefix: apiKeyPrefix("ci"), Hash: []byte{1}})
+	require.NoError(t, err)
+
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	revoked, err := strg.RevokeAPIKey(ctx, key.Id, revokedAt)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.True(t, revokedAt.Equal(*revoked.RevokedAt))
+
+	revoked, err = strg.RevokeAPIKey(ctx, key.Id, time.Now())
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(*revoked.RevokedAt))
+	found, err := strg.GetAPIKeyByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	require.NotNil(t, found.RevokedAt)
+	assert.True(t, revokedAt.Equal(*found.RevokedAt))
+
+	_, err = strg.RevokeAPIKey(ctx, 1<<30, time.Now())
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+}
+
+// testTouchAPIKey проверяет, что время использования ключа
+// только увеличивается.
+func testTouchAPIKey(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	key, err := strg.SaveAPIKey(ctx, storage.APIKeyToSave{Name: "ci", Prefix: apiKeyPrefix("ci"), Hash: []byte{1}})
+	require.NoError(t, err)
+
+	usedAt := time.Now().Truncate(time.Second)
+	require.NoError(t, strg.TouchAPIKey(ctx, key.Id, usedAt))
+	require.NoError(t, strg.TouchAPIKey(ctx, key.Id, usedAt.Add(-time.Hour)))
+	found, err := strg.GetAPIKeyByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	require.NotNil(t, found.LastUsedAt)
+	assert.True(t, usedAt.Equal(*found.LastUsedAt))
+
+	assert.ErrorIs(t, strg.TouchAPIKey(ctx, 1<<30, usedAt), storage.ErrAPIKeyNotFound)
+}
+
+// testReserveURLIds проверяет, что ссылка сохраняется с
+// зарезервированным url_id, а ссылкам без url_id зарезервированные
+// id не выдаются.
+func testReserveURLIds(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	firstId, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias1"})
+	require.NoError(t, err)
+	ids, err := strg.ReserveURLIds(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	assert.Greater(t, ids[0], firstId)
+	assert.Equal(t, ids[0]+1, ids[1])
+	assert.Equal(t, ids[1]+1, ids[2])
+
+	autoId, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias2"})
+	require.NoError(t, err)
+	assert.Greater(t, autoId, ids[2])
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{Id: ids[1], URL: "http://qwe.ru", Alias: "alias3"})
+	require.NoError(t, err)
+	assert.Equal(t, ids[1], id)
+	results, err := strg.SaveURLs(ctx, []storage.URLToSave{
+		{Id: ids[0], URL: "http://qwe.ru", Alias: "alias4"},
+		{URL: "http://qwe.ru", Alias: "alias5"},
+	}, true)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], results[0].Id)
+	assert.Greater(t, results[1].Id, autoId)
+
+	records, err := strg.GetURLsByURL(ctx, "http://qwe.ru")
+	require.NoError(t, err)
+	gotIds := make([]int, 0, len(records))
+	for _, record := range records {
+		gotIds = append(gotIds, record.Id)
+	}
+	assert.Equal(t, []int{firstId, ids[0], ids[1], autoId, results[1].Id}, gotIds)
+}
+
+// testGetURLIdByURL проверяет поиск url_id по URL, в том
+// числе -1 и storage.ErrURLNotFound для несуществующего URL.
+func testGetURLIdByURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	insertedId, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	id, err := strg.GetURLIdByURL(ctx, "http://qwe.ru")
+	require.NoError(t, err)
+	assert.Equal(t, insertedId, id)
+
+	id, err = strg.GetURLIdByURL(ctx, "http://asd.ru")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	assert.Equal(t, -1, id)
+}
+
+// testTruncate проверяет, что после очистки
+// хранилища записей не остается.
+func testTruncate(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	require.NoError(t, strg.Truncate(ctx))
+
+	_, err = strg.GetURLByAlias(ctx, "alias")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	assert.NoError(t, err)
+}
+
+// testExpiredURL проверяет, что по алиасу с истекшим сроком
+// возвращается storage.ErrURLExpired, а с неистекшим - URL.
+func testExpiredURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "expired", ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "active", ExpiresAt: &future})
+	require.NoError(t, err)
+
+	url, err := strg.GetURLByAlias(ctx, "expired")
+	assert.ErrorIs(t, err, storage.ErrURLExpired)
+	assert.Empty(t, url)
+
+	url, err = strg.GetURLByAlias(ctx, "active")
+	require.NoError(t, err)
+	assert.Equal(t, "http://asd.ru", url)
+}
+
+// testGetURLInfo проверяет, что по алиасу возвращается
+// вся сохраненная информация о ссылке и число переходов.
+func testGetURLInfo(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{
+		URL:            "http://qwe.ru",
+		Alias:          "alias",
+		ExpiresAt:      &future,
+		CreatedBy:      "admin",
+		AliasGenerated: true,
+	})
+	require.NoError(t, err)
+	_, err = strg.SaveClicks(ctx, []storage.Click{
+		{Alias: "alias", ClickedAt: time.Now()},
+		{Alias: "alias", ClickedAt: time.Now()},
+	})
+	require.NoError(t, err)
+
+	item, err := strg.GetURLInfo(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, id, item.Id)
+	assert.Equal(t, "alias", item.Alias)
+	assert.Equal(t, "http://qwe.ru", item.URL)
+	require.NotNil(t, item.ExpiresAt)
+	assert.True(t, future.Equal(*item.ExpiresAt))
+	assert.Equal(t, int64(1), item.Version)
+	assert.False(t, item.CreatedAt.IsZero())
+	assert.Equal(t, "admin", item.CreatedBy)
+	assert.True(t, item.AliasGenerated)
+	assert.Equal(t, 2, item.Clicks)
+
+	// Изменение ссылки не трогает автора
+	record, err := strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: "http://asd.ru"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", record.CreatedBy)
+	assert.True(t, record.AliasGenerated)
+}
+
+// testGetURLInfoExpired проверяет, что информацию
+// об истекшей ссылке все еще можно получить.
+func testGetURLInfoExpired(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "expired", ExpiresAt: &past})
+	require.NoError(t, err)
+
+	item, err := strg.GetURLInfo(ctx, "expired")
+	require.NoError(t, err)
+	assert.True(t, storage.IsExpired(item.ExpiresAt, time.Now()))
+	assert.Empty(t, item.CreatedBy)
+	assert.False(t, item.AliasGenerated)
+	assert.Zero(t, item.Clicks)
+}
+
+// testGetURLInfoNotFound проверяет, что для несуществующего
+// алиаса возвращается storage.ErrURLNotFound.
+func testGetURLInfoNotFound(t *testing.T, strg Storage) {
+	_, err := strg.GetURLInfo(context.Background(), "missing")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+// testDeleteExpiredURLs проверяет, что удаляются
+// только ссылки с истекшим сроком.
+func testDeleteExpiredURLs(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "expired", ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "active", ExpiresAt: &future})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://zxc.ru", Alias: "forever"})
+	require.NoError(t, err)
+
+	deleted, err := strg.DeleteExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = strg.GetURLByAlias(ctx, "expired")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	for _, alias := range []string{"active", "forever"} {
+		_, err = strg.GetURLByAlias(ctx, alias)
+		assert.NoError(t, err)
+	}
+}
+
+// testArchiveExpiredURLs проверяет, что ссылки с истекшим
+// сроком убираются из хранилища, а алиас освобождается.
+func testArchiveExpiredURLs(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "expired", ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://zxc.ru", Alias: "forever"})
+	require.NoError(t, err)
+
+	archived, err := strg.ArchiveExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, archived)
+
+	_, err = strg.GetURLByAlias(ctx, "expired")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = strg.GetURLByAlias(ctx, "forever")
+	assert.NoError(t, err)
+
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "expired"})
+	assert.NoError(t, err)
+}
+
+// testClickStats проверяет подсчет переходов: общее количество,
+// разбивку по дням начиная с since и топы с ограничением top.
+func testClickStats(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	twoDaysAgo := today.AddDate(0, 0, -2)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "other"})
+	require.NoError(t, err)
+
+	clicks := []storage.Click{
+		{Alias: "alias", ClickedAt: twoDaysAgo.Add(time.Hour), Referrer: "http://a.ru", UserAgent: "curl"},
+		{Alias: "alias", ClickedAt: twoDaysAgo.Add(2 * time.Hour), Referrer: "http://a.ru", UserAgent: "curl"},
+		{Alias: "alias", ClickedAt: today.Add(time.Hour), Referrer: "http://b.ru", UserAgent: "curl"},
+		{Alias: "alias", ClickedAt: today.Add(2 * time.Hour), UserAgent: "firefox", RequestID: "req", IP: "10.0.0.0"},
+		{Alias: "other", ClickedAt: today.Add(time.Hour), Referrer: "http://c.ru", UserAgent: "chrome"},
+	}
+	for _, click := range clicks {
+		require.NoError(t, strg.SaveClick(ctx, click))
+	}
+
+	stats, err := strg.GetStats(ctx, "alias", twoDaysAgo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.TotalClicks)
+	require.Len(t, stats.ClicksPerDay, 2)
+	assert.Equal(t, twoDaysAgo.Format(time.DateOnly), stats.ClicksPerDay[0].Day.Format(time.DateOnly))
+	assert.Equal(t, 2, stats.ClicksPerDay[0].Clicks)
+	assert.Equal(t, today.Format(time.DateOnly), stats.ClicksPerDay[1].Day.Format(time.DateOnly))
+	assert.Equal(t, 2, stats.ClicksPerDay[1].Clicks)
+	assert.Equal(t, []storage.ValueClicks{{Value: "http://a.ru", Clicks: 2}}, stats.TopReferrers)
+	assert.Equal(t, []storage.ValueClicks{{Value: "curl", Clicks: 3}}, stats.TopUserAgents)
+
+	stats, err = strg.GetStats(ctx, "alias", today, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.TotalClicks)
+	require.Len(t, stats.ClicksPerDay, 1)
+	assert.Len(t, stats.TopReferrers, 2)
+	assert.Len(t, stats.TopUserAgents, 2)
+}
+
+// testClickStatsNotFound проверяет, что переход и статистика по
+// несуществующему алиасу возвращают storage.ErrURLNotFound.
+func testClickStatsNotFound(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	err := strg.SaveClick(ctx, storage.Click{Alias: "alias", ClickedAt: time.Now()})
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = strg.GetStats(ctx, "alias", time.Now(), 10)
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+// testSaveClicksBatch проверяет, что пачка переходов сохраняется
+// целиком, а переходы по несуществующим алиасам пропускаются.
+func testSaveClicksBatch(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "other"})
+	require.NoError(t, err)
+
+	saved, err := strg.SaveClicks(ctx, nil)
+	require.NoError(t, err)
+	assert.Zero(t, saved)
+
+	saved, err = strg.SaveClicks(ctx, []storage.Click{
+		{Alias: "alias", ClickedAt: now, Referrer: "http://a.ru"},
+		{Alias: "missing", ClickedAt: now},
+		{Alias: "other", ClickedAt: now},
+		{Alias: "alias", ClickedAt: now, UserAgent: "curl"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, saved)
+
+	stats, err := strg.GetStats(ctx, "alias", now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalClicks)
+	stats, err = strg.GetStats(ctx, "other", now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalClicks)
+}
+
+// testClickStatsAfterDelete проверяет, что переходы удаляются
+// вместе со ссылкой и не достаются новой ссылке с тем же алиасом.
+func testClickStatsAfterDelete(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+	require.NoError(t, strg.SaveClick(ctx, storage.Click{Alias: "alias", ClickedAt: time.Now()}))
+
+	_, err = strg.DeleteURLByAlias(ctx, "alias")
+	require.NoError(t, err)
+	_, err = strg.SaveURL(ctx, storage.URLToSave{URL: "http://asd.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	stats, err := strg.GetStats(ctx, "alias", time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+	assert.Empty(t, stats.ClicksPerDay)
+}
+
+// testConcurrentSaveAndGet проверяет, что хранилище можно
+// одновременно использовать из нескольких горутин.
+func testConcurrentSaveAndGet(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	const workers = 20
+
+	var wg sync.WaitGroup
+	ids := make([]int, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			alias := fmt.Sprintf("alias%d", i)
+			ids[i], errs[i] = strg.SaveURL(ctx, storage.URLToSave{URL: fmt.Sprintf("http://qwe%d.ru", i), Alias: alias})
+			if errs[i] != nil {
+				return
+			}
+			_, errs[i] = strg.GetURLByAlias(ctx, alias)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool, workers)
+	for i := 0; i < workers; i++ {
+		require.NoError(t, errs[i])
+		assert.False(t, seen[ids[i]], "duplicate id %d", ids[i])
+		seen[ids[i]] = true
+	}
+}
+
+// testConcurrentDuplicateAlias проверяет, что при одновременном
+// сохранении одного алиаса успешно только одно сохранение.
+func testConcurrentDuplicateAlias(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	const workers = 20
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = strg.SaveURL(ctx, storage.URLToSave{URL: fmt.Sprintf("http://qwe%d.ru", i), Alias: "alias"})
+		}(i)
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+		assert.True(t, errors.Is(err, storage.ErrAliasExists), "unexpected error: %v", err)
+	}
+	assert.Equal(t, 1, saved)
+}
+
+// testUpdateURL проверяет, что ссылку можно перенаправить
+// на новый URL без смены id, а версия растет с каждым изменением.
+func testUpdateURL(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	id, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	record, err := strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: "http://asd.ru"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, id, record.Id)
+	assert.Equal(t, "alias", record.Alias)
+	assert.Equal(t, "http://asd.ru", record.URL)
+	assert.Equal(t, int64(2), record.Version)
+	assert.False(t, record.CreatedAt.IsZero())
+	assert.False(t, record.UpdatedAt.Before(record.CreatedAt))
+
+	url, err := strg.GetURLByAlias(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, "http://asd.ru", url)
+
+	// Пустое изменение не трогает URL, но увеличивает версию
+	record, err = strg.UpdateURL(ctx, "alias", storage.URLUpdate{}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "http://asd.ru", record.URL)
+	assert.Equal(t, int64(3), record.Version)
+}
+
+// testUpdateURLExpiresAt проверяет, что срок жизни
+// можно как задать, так и снять.
+func testUpdateURLExpiresAt(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	record, err := strg.UpdateURL(ctx, "alias", storage.URLUpdate{SetExpiresAt: true, ExpiresAt: &past}, 0)
+	require.NoError(t, err)
+	require.NotNil(t, record.ExpiresAt)
+	assert.Equal(t, past.Unix(), record.ExpiresAt.Unix())
+	_, err = strg.GetURLByAlias(ctx, "alias")
+	assert.ErrorIs(t, err, storage.ErrURLExpired)
+
+	record, err = strg.UpdateURL(ctx, "alias", storage.URLUpdate{SetExpiresAt: true}, 0)
+	require.NoError(t, err)
+	assert.Nil(t, record.ExpiresAt)
+	_, err = strg.GetURLByAlias(ctx, "alias")
+	assert.NoError(t, err)
+}
+
+// testUpdateURLVersionMismatch проверяет, что изменение с устаревшей
+// версией возвращает storage.ErrVersionMismatch и не меняет ссылку.
+func testUpdateURLVersionMismatch(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+	_, err = strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: "http://asd.ru"}, 1)
+	require.NoError(t, err)
+
+	_, err = strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: "http://zxc.ru"}, 1)
+	assert.ErrorIs(t, err, storage.ErrVersionMismatch)
+
+	url, err := strg.GetURLByAlias(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, "http://asd.ru", url)
+}
+
+// testUpdateURLNotFound проверяет, что изменение несуществующего
+// алиаса возвращает storage.ErrURLNotFound даже с версией.
+func testUpdateURLNotFound(t *testing.T, strg Storage) {
+	ctx := context.Background()
+
+	_, err := strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: "http://qwe.ru"}, 0)
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: "http://qwe.ru"}, 1)
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+// testConcurrentUpdateSameVersion проверяет, что из нескольких
+// одновременных изменений одной версии проходит ровно одно.
+func testConcurrentUpdateSameVersion(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	const workers = 20
+
+	_, err := strg.SaveURL(ctx, storage.URLToSave{URL: "http://qwe.ru", Alias: "alias"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = strg.UpdateURL(ctx, "alias", storage.URLUpdate{URL: fmt.Sprintf("http://qwe%d.ru", i)}, 1)
+		}(i)
+	}
+	wg.Wait()
+
+	updated := 0
+	for _, err := range errs {
+		if err == nil {
+			updated++
+			continue
+		}
+		assert.True(t, errors.Is(err, storage.ErrVersionMismatch), "unexpected error: %v", err)
+	}
+	assert.Equal(t, 1, updated)
+}
+
+// saveListFixture сохраняет ссылки для тестов списка. Переходов
+// у ссылки столько, сколько указано в clicks.
+func saveListFixture(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	fixture := []struct {
+		alias  string
+		url    string
+		clicks int
+	}{
+		{"delta", "https://example.com/a", 2},
+		{"alpha", "https://sub.example.com/b?q=Test", 0},
+		{"charlie", "http://other.org/c", 3},
+		{"bravo", "https://notexample.com/d", 1},
+		{"alpine", "https://example.com:8080/e", 0},
+	}
+	for _, f := range fixture {
+		_, err := strg.SaveURL(ctx, storage.URLToSave{URL: f.url, Alias: f.alias})
+		require.NoError(t, err)
+		for range f.clicks {
+			require.NoError(t, strg.SaveClick(ctx, storage.Click{Alias: f.alias, ClickedAt: time.Now()}))
+		}
+	}
+}
+
+func aliasesOf(items []storage.URLListItem) []string {
+	aliases := make([]string, 0, len(items))
+	for _, item := range items {
+		aliases = append(aliases, item.Alias)
+	}
+	return aliases
+}
+
+// testListURLsSort проверяет порядок ссылок для каждого поля
+// сортировки и количество переходов в списке.
+func testListURLsSort(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	saveListFixture(t, strg)
+
+	cases := []struct {
+		sortBy string
+		desc   bool
+		want   []string
+	}{
+		{storage.SortCreated, false, []string{"delta", "alpha", "charlie", "bravo", "alpine"}},
+		{storage.SortCreated, true, []string{"alpine", "bravo", "charlie", "alpha", "delta"}},
+		{"", false, []string{"delta", "alpha", "charlie", "bravo", "alpine"}},
+		{storage.SortAlias, false, []string{"alpha", "alpine", "bravo", "charlie", "delta"}},
+		{storage.SortAlias, true, []string{"delta", "charlie", "bravo", "alpine", "alpha"}},
+		// При равном количестве переходов порядок по id
+		{storage.SortClicks, false, []string{"alpha", "alpine", "bravo", "delta", "charlie"}},
+		{storage.SortClicks, true, []string{"charlie", "delta", "bravo", "alpine", "alpha"}},
+	}
+	for _, tc := range cases {
+		items, err := strg.ListURLs(ctx, storage.ListOptions{SortBy: tc.sortBy, Desc: tc.desc})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, aliasesOf(items), "sort %q desc %v", tc.sortBy, tc.desc)
+	}
+
+	items, err := strg.ListURLs(ctx, storage.ListOptions{SortBy: storage.SortAlias, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "https://sub.example.com/b?q=Test", items[0].URL)
+	assert.Equal(t, 0, items[0].Clicks)
+	assert.Equal(t, int64(1), items[0].Version)
+	assert.False(t, items[0].CreatedAt.IsZero())
+
+	items, err = strg.ListURLs(ctx, storage.ListOptions{SortBy: storage.SortClicks, Desc: true, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, 3, items[0].Clicks)
+}
+
+// testListURLsPagination проверяет, что постраничный обход
+// по курсору дает тот же порядок без пропусков и повторов.
+func testListURLsPagination(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	saveListFixture(t, strg)
+
+	for _, sortBy := range []string{storage.SortCreated, storage.SortAlias, storage.SortClicks} {
+		for _, desc := range []bool{false, true} {
+			all, err := strg.ListURLs(ctx, storage.ListOptions{SortBy: sortBy, Desc: desc})
+			require.NoError(t, err)
+
+			var paged []storage.URLListItem
+			opts := storage.ListOptions{SortBy: sortBy, Desc: desc, Limit: 2}
+			for range len(all) + 1 {
+				page, err := strg.ListURLs(ctx, opts)
+				require.NoError(t, err)
+				if len(page) == 0 {
+					break
+				}
+				paged = append(paged, page...)
+				cursor := page[len(page)-1].Cursor()
+				opts.After = &cursor
+			}
+			assert.Equal(t, aliasesOf(all), aliasesOf(paged), "sort %q desc %v", sortBy, desc)
+		}
+	}
+}
+
+// testListURLsFilter проверяет отбор ссылок по подстроке URL,
+// домену, началу алиаса и времени создания.
+func testListURLsFilter(t *testing.T, strg Storage) {
+	ctx := context.Background()
+	saveListFixture(t, strg)
+	hourAgo := time.Now().Add(-time.Hour)
+	inHour := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		caseName string
+		filter   storage.ListFilter
+		want     []string
+	}{
+		{"URL substring ignores case", storage.ListFilter{URLContains: "q=test"}, []string{"alpha"}},
+		{"Domain with subdomains", storage.ListFilter{Domain: "Example.com"}, []string{"alpha", "alpine", "delta"}},
+		{"Subdomain only", storage.ListFilter{Domain: "sub.example.com"}, []string{"alpha"}},
+		{"Alias prefix", storage.ListFilter{AliasPrefix: "alp"}, []string{"alpha", "alpine"}},
+		{"Alias prefix is case sensitive", storage.ListFilter{AliasPrefix: "Alp"}, []string{}},
+		{"Combined", storage.ListFilter{Domain: "example.com", AliasPrefix: "al"}, []string{"alpha", "alpine"}},
+		{"Created from", storage.ListFilter{CreatedFrom: &hourAgo}, []string{"alpha", "alpine", "bravo", "charlie", "delta"}},
+		{"Created to", storage.ListFilter{CreatedTo: &hourAgo}, []string{}},
+		{"Created range", storage.ListFilter{CreatedFrom: &hourAgo, CreatedTo: &inHour}, []string{"alpha", "alpine", "bravo", "charlie", "delta"}},
+		{"Created in future", storage.ListFilter{CreatedFrom: &inHour}, []string{}},
+	}
+	for _, tc := range cases {
+		items, err := strg.ListURLs(ctx, storage.ListOptions{Filter: tc.filter, SortBy: storage.SortAlias})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, aliasesOf(items), tc.caseName)
+	}
+}
@@ -0,0 +1,245 @@
+// Package users хранит пользователей API и проверяет их пароли.
+// Пользователи читаются из файла в формате htpasswd с bcrypt-хешами
+// и перечитываются без перезапуска сервера.
+package users
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/internal/lib/logger/xslog"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// staticCost стоимость bcrypt для паролей, заданных в конфиге открытым
+// текстом: хеш нужен только для единообразной проверки, стойкость ему
+// не важна, так как пароль и так лежит в конфиге.
+const staticCost = bcrypt.MinCost
+
+// dummyHash возвращает хеш, с которым сравнивается пароль неизвестного
+// пользователя, чтобы по времени ответа нельзя было узнать, есть ли он.
+// Стоимость берется самая большая из хешей users: проверка неизвестного
+// пользователя не должна быть быстрее или медленнее проверки известного.
+func dummyHash(users map[string][]byte) ([]byte, error) {
+	cost := staticCost
+	for _, hash := range users {
+		hashCost, err := bcrypt.Cost(hash)
+		if err != nil {
+			return nil, err
+		}
+		cost = max(cost, hashCost)
+	}
+	return bcrypt.GenerateFromPassword([]byte("dummy password"), cost)
+}
+
+// Store пользователи API. Безопасен для конкурентного использования.
+type Store struct {
+	log *slog.Logger
+	// path файл htpasswd, пустой - пользователи заданы в коде
+	path string
+
+	mu    sync.RWMutex
+	users map[string][]byte
+	// dummy хеш для проверки неизвестных пользователей, см. dummyHash
+	dummy   []byte
+	modTime time.Time
+}
+
+// New читает пользователей из htpasswd-файла path.
+func New(log *slog.Logger, path string) (*Store, error) {
+	const operationPlace = "users.New"
+
+	s := &Store{
+		log:  log.With(slog.String("component", "users")),
+		path: path,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	return s, nil
+}
+
+// NewStatic создает хранилище из пар имя-пароль открытым текстом.
+// Файла у такого хранилища нет, Reload ничего не делает.
+func NewStatic(log *slog.Logger, passwords map[string]string) (*Store, error) {
+	const operationPlace = "users.NewStatic"
+
+	users := make(map[string][]byte, len(passwords))
+	for name, password := range passwords {
+		if name == "" || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("%s: invalid user name %q", operationPlace, name)
+		}
+		if password == "" {
+			return nil, fmt.Errorf("%s: empty password for user %q", operationPlace, name)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), staticCost)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operationPlace, err)
+		}
+		users[name] = hash
+	}
+	dummy, err := dummyHash(users)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	return &Store{
+		log:   log.With(slog.String("component", "users")),
+		users: users,
+		dummy: dummy,
+	}, nil
+}
+
+// Authenticate проверяет пароль пользователя. Для неизвестного
+// пользователя пароль все равно сравнивается с хешем, поэтому время
+// ответа не зависит от того, есть ли такой пользователь.
+func (s *Store) Authenticate(name, password string) bool {
+	s.mu.RLock()
+	hash, ok := s.users[name]
+	dummy := s.dummy
+	s.mu.RUnlock()
+
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummy, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// Cost возвращает стоимость bcrypt, с которой проверяются пароли
+// неизвестных пользователей.
+func (s *Store) Cost() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cost, _ := bcrypt.Cost(s.dummy)
+	return cost
+}
+
+// Len возвращает число пользователей.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+// Reload перечитывает файл пользователей. Если файл не разбирается
+// или пуст, остаются прежние пользователи.
+func (s *Store) Reload() error {
+	const operationPlace = "users.Reload"
+
+	if s.path == "" {
+		return nil
+	}
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+	users, err := Parse(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", operationPlace, s.path, err)
+	}
+	// Пустой файл скорее всего еще не дописан, а без пользователей
+	// сервер отклонит все запросы
+	if len(users) == 0 {
+		return fmt.Errorf("%s: %s: no users", operationPlace, s.path)
+	}
+	dummy, err := dummyHash(users)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	s.mu.Lock()
+	s.users = users
+	s.dummy = dummy
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	s.log.Info("users loaded", slog.String("path", s.path), slog.Int("count", len(users)))
+	return nil
+}
+
+// Run раз в interval проверяет время изменения файла пользователей
+// и перечитывает его, если файл изменился. Работает до отмены ctx.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reloadIfChanged(); err != nil {
+				s.log.Error("failed to reload users", xslog.Err(err))
+			}
+		}
+	}
+}
+
+func (s *Store) reloadIfChanged() error {
+	const operationPlace = "users.reloadIfChanged"
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operationPlace, err)
+	}
+
+	s.mu.RLock()
+	changed := !info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return s.Reload()
+}
+
+// Parse читает пользователей в формате htpasswd: строки "имя:хеш",
+// пустые строки и строки, начинающиеся с #, пропускаются. Поддерживаются
+// только bcrypt-хеши ($2a$, $2b$, $2y$), например из htpasswd -B.
+func Parse(r io.Reader) (map[string][]byte, error) {
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, hash, ok := strings.Cut(text, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: expected name:hash", line)
+		}
+		if _, ok := users[name]; ok {
+			return nil, fmt.Errorf("line %d: duplicate user %s", line, name)
+		}
+		if !strings.HasPrefix(hash, "$2") {
+			return nil, fmt.Errorf("line %d: user %s: only bcrypt hashes are supported", line, name)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("line %d: user %s: %w", line, name, err)
+		}
+		users[name] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
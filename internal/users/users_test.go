@@ -0,0 +1,173 @@
+//go:build smoke
+
+package users_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
+	"url-shortener/internal/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hash(t *testing.T, password string) string {
+	return hashWithCost(t, password, bcrypt.MinCost)
+}
+
+func hashWithCost(t *testing.T, password string, cost int) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func writeUsers(t *testing.T, path string, lines ...string) {
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+}
+
+func TestParse(t *testing.T) {
+	aliceHash := hash(t, "alice-secret")
+	cases := []struct {
+		caseName string
+		content  string
+		expected []string
+		wantErr  string
+	}{
+		{
+			caseName: "Comments and empty lines",
+			content:  "# команда\n\nalice:" + aliceHash + "\n  bob:" + strings.Replace(hash(t, "bob"), "$2a$", "$2y$", 1) + "  \n",
+			expected: []string{"alice", "bob"},
+		},
+		{
+			caseName: "Not bcrypt",
+			content:  "alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=",
+			wantErr:  "line 1: user alice: only bcrypt hashes are supported",
+		},
+		{
+			caseName: "Broken bcrypt",
+			content:  "alice:$2y$10$short",
+			wantErr:  "line 1: user alice",
+		},
+		{
+			caseName: "Without hash",
+			content:  "# команда\nalice",
+			wantErr:  "line 2: expected name:hash",
+		},
+		{
+			caseName: "Duplicate user",
+			content:  "alice:" + aliceHash + "\nalice:" + aliceHash,
+			wantErr:  "line 2: duplicate user alice",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			parsed, err := users.Parse(strings.NewReader(tc.content))
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			names := make([]string, 0, len(parsed))
+			for name := range parsed {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, tc.expected, names)
+		})
+	}
+}
+
+// TestAuthenticate проверяет, что пропускаются только пользователи
+// из файла с верными паролями.
+func TestAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsers(t, path, "alice:"+hash(t, "alice-secret"), "bob:"+hash(t, "bob-secret"))
+
+	store, err := users.New(slogdiscard.NewDiscardLogger(), path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.Len())
+
+	assert.True(t, store.Authenticate("alice", "alice-secret"))
+	assert.True(t, store.Authenticate("bob", "bob-secret"))
+	assert.False(t, store.Authenticate("alice", "bob-secret"))
+	assert.False(t, store.Authenticate("carol", "alice-secret"))
+	assert.False(t, store.Authenticate("", ""))
+}
+
+// TestReload проверяет, что измененный файл перечитывается, а
+// испорченный не заменяет загруженных пользователей.
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsers(t, path, "alice:"+hash(t, "alice-secret"))
+	store, err := users.New(slogdiscard.NewDiscardLogger(), path)
+	require.NoError(t, err)
+
+	writeUsers(t, path, "alice:"+hash(t, "alice-secret"), "bob:"+hash(t, "bob-secret"))
+	require.NoError(t, store.Reload())
+	assert.True(t, store.Authenticate("bob", "bob-secret"))
+
+	writeUsers(t, path, "carol")
+	require.Error(t, store.Reload())
+	writeUsers(t, path)
+	require.Error(t, store.Reload())
+	assert.True(t, store.Authenticate("alice", "alice-secret"))
+	assert.True(t, store.Authenticate("bob", "bob-secret"))
+}
+
+// TestRun проверяет, что изменения файла подхватываются без Reload.
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsers(t, path, "alice:"+hash(t, "alice-secret"))
+	store, err := users.New(slogdiscard.NewDiscardLogger(), path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Run(ctx, 10*time.Millisecond)
+
+	writeUsers(t, path, "bob:"+hash(t, "bob-secret"))
+	// Время изменения могло совпасть с прежним на грубых файловых системах
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	require.Eventually(t, func() bool {
+		return store.Authenticate("bob", "bob-secret")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, store.Authenticate("alice", "alice-secret"))
+}
+
+// TestCost проверяет, что неизвестные пользователи проверяются с той
+// же стоимостью bcrypt, что и самый дорогой хеш загруженных пользователей.
+func TestCost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsers(t, path, "alice:"+hash(t, "alice-secret"), "bob:"+hashWithCost(t, "bob-secret", 5))
+	store, err := users.New(slogdiscard.NewDiscardLogger(), path)
+	require.NoError(t, err)
+	assert.Equal(t, 5, store.Cost())
+
+	writeUsers(t, path, "alice:"+hashWithCost(t, "alice-secret", 6))
+	require.NoError(t, store.Reload())
+	assert.Equal(t, 6, store.Cost())
+
+	static, err := users.NewStatic(slogdiscard.NewDiscardLogger(), map[string]string{"admin": "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, static.Cost())
+}
+
+func TestNewStatic(t *testing.T) {
+	store, err := users.NewStatic(slogdiscard.NewDiscardLogger(), map[string]string{"admin": "secret"})
+	require.NoError(t, err)
+	assert.True(t, store.Authenticate("admin", "secret"))
+	assert.False(t, store.Authenticate("admin", "Secret"))
+	require.NoError(t, store.Reload())
+
+	_, err = users.NewStatic(slogdiscard.NewDiscardLogger(), map[string]string{"": "secret"})
+	assert.Error(t, err)
+	_, err = users.NewStatic(slogdiscard.NewDiscardLogger(), map[string]string{"admin": ""})
+	assert.Error(t, err)
+}
@@ -6,17 +6,32 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+	"url-shortener/internal/clicks"
+	"url-shortener/internal/config"
+	"url-shortener/internal/http-server/handlers/apikey/issue"
 	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/url/batch"
 	"url-shortener/internal/http-server/handlers/url/delete"
 	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/router"
+	"url-shortener/internal/lib/aliasrule"
 	"url-shortener/internal/lib/api"
 	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogpretty/slogdiscard"
 	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlnorm"
 	errStorage "url-shortener/internal/storage"
+	"url-shortener/internal/storage/memory"
 	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
+	"url-shortener/internal/users"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/gavv/httpexpect/v2"
@@ -32,23 +47,94 @@ var (
 	}
 )
 
-const (
+// testStorage методы хранилища, которые
+// используются в тестах для подготовки данных.
+type testStorage interface {
+	SaveURL(ctx context.Context, urlToSave errStorage.URLToSave) (int, error)
+	GetURLByAlias(ctx context.Context, alias string) (string, error)
+	GetURLIdByURL(ctx context.Context, canonicalURL string) (int, error)
+	Truncate(ctx context.Context) error
+}
+
+var (
 	host = "127.0.0.1:8082"
+	strg testStorage
+	// normalizer строит ключи поиска по URL так же, как сервер
+	normalizer = urlnorm.New(urlnorm.DefaultStripParams)
 )
 
+// TestMain поднимает окружение для тестов. При STORAGE_DRIVER=memory
+// или STORAGE_DRIVER=sqlite сервер запускается внутри теста, иначе
+// тесты идут к запущенному приложению и PostgreSQL.
 func TestMain(m *testing.M) {
 	logger := log.New(os.Stdout, "", log.LstdFlags)
+	ctx := context.Background()
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "memory", "sqlite":
+		var storage router.Storage
+		if driver == "memory" {
+			storage = memory.New()
+		} else {
+			dir, err := os.MkdirTemp("", "url-shortener")
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			sqliteStorage, err := sqlite.New(filepath.Join(dir, "storage.db"))
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer sqliteStorage.Close()
+			storage = sqliteStorage
+		}
+		cfgServer := &config.Config{
+			Storage: config.Storage{QueryTimeout: 3 * time.Second},
+			Batch:   config.Batch{MaxItems: 10},
+			Save:    config.Save{StripParams: urlnorm.DefaultStripParams},
+			Alias:   config.Alias{Suggestions: 3},
+			APIKeys: config.APIKeys{Admins: []string{cfg["username"]}},
+		}
+		clickPipeline := clicks.New(slogdiscard.NewDiscardLogger(), storage.(clicks.ClickSaver), config.Clicks{FlushInterval: 10 * time.Millisecond})
+		defer clickPipeline.Close(ctx)
+		aliases, err := random.NewAliases(random.AliasesOptions{
+			Strategy:         random.StrategyRandom,
+			Retries:          3,
+			Random:           random.Options{Alphabet: random.DefaultAlphabet, Length: 6, MaxLength: 12, GrowAfter: 3},
+			HashidsSalt:      "tests",
+			HashidsMinLength: 6,
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		rules, err := aliasrule.New(aliasrule.Options{Charset: aliasrule.DefaultCharset, MinLength: 3, MaxLength: 64})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		apiUsers, err := users.NewStatic(slogdiscard.NewDiscardLogger(), map[string]string{cfg["username"]: cfg["password"]})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		server := httptest.NewServer(router.New(slogdiscard.NewDiscardLogger(), cfgServer, storage, clickPipeline, aliases, rules, apiUsers))
+		defer server.Close()
+		host = strings.TrimPrefix(server.URL, "http://")
+		strg = storage.(testStorage)
+		logger.Printf("TEST SERVER WITH %s STORAGE STARTED", driver)
+		// Код возврата m.Run будет передан в os.Exit после выхода из TestMain
+		m.Run()
+		return
+	}
+
 	err := godotenv.Load("../.env.local")
 	if err != nil {
 		logger.Fatalf("cannot load env: %v", err)
 	}
-	ctx := context.Background()
 	dbPath := os.Getenv("DATABASE_URL")
-	storage, cancel, err := postgres.MustNewConnection(ctx, dbPath)
-	defer cancel(*storage)
+	storage, cancel, err := postgres.MustNewConnection(ctx, dbPath, postgres.Options{})
 	if err != nil {
 		logger.Fatal(err)
 	}
+	strg = storage
 	logger.Println("TEST DATABASE CREATED SUCCESS")
 	exitVal := m.Run()
 	logger.Println("TESTS COMPLETED")
@@ -57,6 +143,7 @@ func TestMain(m *testing.M) {
 		logger.Fatal(err)
 	}
 	logger.Println("TEST DATABASE TRUNCATE")
+	cancel(*storage)
 	os.Exit(exitVal)
 }
 
@@ -79,10 +166,7 @@ func TestSaveURLSuccess(t *testing.T) {
 		JSON().Object().
 		ContainsKey("alias").ContainsValue(req.Alias)
 
-	conn, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	require.NoError(t, err)
-	defer cancel(*conn)
-	URL, err := conn.GetURLByAlias(ctx, req.Alias)
+	URL, err := strg.GetURLByAlias(ctx, req.Alias)
 	require.NoError(t, err)
 	assert.Equal(t, req.URL, URL)
 }
@@ -105,10 +189,7 @@ func TestSaveURLWithAliasByAutoGenerate(t *testing.T) {
 		JSON().
 		Object().
 		ContainsKey("status").ContainsValue(response.StatusOK)
-	conn, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	require.NoError(t, err)
-	defer cancel(*conn)
-	urlId, err := conn.GetURLIdByURL(ctx, req.URL)
+	urlId, err := strg.GetURLIdByURL(ctx, normalizer.Canonical(req.URL))
 	require.NoError(t, err)
 	assert.NotEqual(t, urlId, -1)
 }
@@ -137,13 +218,16 @@ func TestCannotSaveInvalidURL(t *testing.T) {
 		URL:   "URL_TestCannotSaveInvalidURL",
 		Alias: random.NewRandomString(10),
 	}
-	e.POST("/url").WithJSON(req).
+	resp := e.POST("/url").WithJSON(req).
 		WithBasicAuth(cfg["username"], cfg["password"]).
 		Expect().
+		Status(http.StatusBadRequest).
 		JSON().
-		Object().
-		ContainsKey("error").
-		ContainsValue("field is not a valid URL. Field: URL")
+		Object()
+	resp.ContainsKey("code").ContainsValue(response.CodeValidation)
+	resp.Value("details").Array().Value(0).Object().
+		ContainsValue("url").
+		ContainsValue(response.FieldCodeInvalidURL)
 }
 
 // TestCannotSaveTwoEqaulURLs проверяет, что при
@@ -151,11 +235,8 @@ func TestCannotSaveInvalidURL(t *testing.T) {
 // сервер вернет ошибку.
 func TestCannotSaveTwoEqaulAliases(t *testing.T) {
 	ctx := context.Background()
-	storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	require.NoError(t, err)
-	defer cancel(*storage)
 	alias := "ALIAS_TestCannotSaveTwoEqaulAliases"
-	_, err = storage.SaveURL(ctx, "http://qwe.ru", alias)
+	_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: "http://qwe.ru", Alias: alias})
 	require.NoError(t, err)
 
 	u := url.URL{Scheme: "http", Host: host}
@@ -166,10 +247,12 @@ func TestCannotSaveTwoEqaulAliases(t *testing.T) {
 	}
 	e.POST("/url").WithJSON(req).WithBasicAuth(cfg["username"], cfg["password"]).
 		Expect().
+		Status(http.StatusConflict).
 		JSON().
 		Object().
 		ContainsKey("error").
-		ContainsValue("alias already exists")
+		ContainsValue(save.ErrMsgAliasExists).
+		ContainsValue(response.CodeAliasExists)
 
 }
 
@@ -178,14 +261,11 @@ func TestCannotSaveTwoEqaulAliases(t *testing.T) {
 // соответсвует алиас.
 func TestRedirectSuccess(t *testing.T) {
 	ctx := context.Background()
-	storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	require.NoError(t, err)
-	defer cancel(*storage)
 
 	URL := "https://google.com"
 	alias := "ALIAS_TestRedirectSuccess"
 
-	_, err = storage.SaveURL(ctx, URL, alias)
+	_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: URL, Alias: alias})
 	require.NoError(t, err)
 
 	u := url.URL{Scheme: "http", Host: host, Path: alias}
@@ -209,14 +289,11 @@ func TestCannotRedirectUndefinedAlias(t *testing.T) {
 // запроса с алиасом, который есть в БД, произойдет удаление.
 func TestDeleteSuccess(t *testing.T) {
 	ctx := context.Background()
-	storage, cancel, err := postgres.MustNewConnection(ctx, os.Getenv("DATABASE_URL"))
-	require.NoError(t, err)
-	defer cancel(*storage)
 
 	URL := "https://google.com"
 	alias := "ALIAS_TestDeleteSuccess"
 
-	_, err = storage.SaveURL(ctx, URL, alias)
+	_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: URL, Alias: alias})
 	require.NoError(t, err)
 
 	u := url.URL{Scheme: "http", Host: host, Path: "url"}
@@ -227,7 +304,7 @@ func TestDeleteSuccess(t *testing.T) {
 		Object().
 		ContainsKey("status").ContainsValue("OK")
 
-	id, err := storage.GetURLByAlias(ctx, alias)
+	id, err := strg.GetURLByAlias(ctx, alias)
 	require.Error(t, err, errStorage.ErrURLNotFound)
 	require.Equal(t, id, "")
 }
@@ -242,9 +319,11 @@ func TestCannotDeleteBecauseNoRow(t *testing.T) {
 	e := httpexpect.Default(t, u.String())
 	e.DELETE("/"+alias).WithBasicAuth(cfg["username"], cfg["password"]).
 		Expect().
+		Status(http.StatusNotFound).
 		JSON().
 		Object().
-		ContainsKey("error").ContainsValue(delete.ErrNothingToDelete)
+		ContainsKey("error").ContainsValue(delete.ErrNothingToDelete).
+		ContainsValue(response.CodeNotFound)
 }
 
 // TestCannotDeleteRowWithOutAuth проверяет,
@@ -255,3 +334,446 @@ func TestCannotDeleteRowWithOutAuth(t *testing.T) {
 	alias := "TestCannotDeleteRowWithOutAuth"
 	e.DELETE("/" + alias).Expect().Status(http.StatusUnauthorized)
 }
+
+// TestRedirectExpiredAlias проверяет, что по алиасу
+// с истекшим сроком жизни сервер вернет 410 вместо редиректа.
+func TestRedirectExpiredAlias(t *testing.T) {
+	ctx := context.Background()
+	alias := "ALIAS_TestRedirectExpiredAlias"
+	expiresAt := time.Now().Add(-time.Minute)
+
+	_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: "https://google.com", Alias: alias, ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	e.GET("/" + alias).
+		Expect().
+		Status(http.StatusGone).
+		JSON().
+		Object().
+		ContainsKey("error").ContainsValue(redirect.ErrMsgURLExpired)
+}
+
+// TestSaveURLWithTTL проверяет, что ссылку
+// можно создать с ограниченным временем жизни.
+func TestSaveURLWithTTL(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	req := save.Request{
+		URL:   gofakeit.URL(),
+		Alias: random.NewRandomString(10),
+		TTL:   3600,
+	}
+	e.POST("/url").WithJSON(req).WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("expires_at")
+}
+
+// TestRedirectRecordsClick проверяет, что переход по алиасу
+// попадает в статистику.
+func TestRedirectRecordsClick(t *testing.T) {
+	ctx := context.Background()
+	alias := random.NewRandomString(10)
+
+	_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: "https://google.com", Alias: alias})
+	require.NoError(t, err)
+
+	u := url.URL{Scheme: "http", Host: host}
+	for range 2 {
+		_, err := api.GetRedirect(u.String() + "/" + alias)
+		require.NoError(t, err)
+	}
+
+	e := httpexpect.Default(t, u.String())
+	// Переходы сохраняются в фоне, поэтому ждем, пока они появятся
+	require.Eventually(t, func() bool {
+		totalClicks := e.GET("/url/"+alias+"/stats").WithBasicAuth(cfg["username"], cfg["password"]).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("total_clicks").Number().Raw()
+		return totalClicks == 2
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// TestCannotGetStatsWithOutAuth проверяет,
+// что статистика недоступна без авторизации.
+func TestCannotGetStatsWithOutAuth(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host, Path: "url"}
+	e := httpexpect.Default(t, u.String())
+	e.GET("/TestCannotGetStatsWithOutAuth/stats").Expect().Status(http.StatusUnauthorized)
+}
+
+// TestUpdateURLWithIfMatch проверяет, что алиас можно перенаправить
+// на новый URL, а изменение с устаревшим ETag отклоняется.
+func TestUpdateURLWithIfMatch(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	alias := random.NewRandomString(10)
+
+	etag := e.POST("/url").WithJSON(save.Request{URL: "https://google.com", Alias: alias}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		Header("ETag").Raw()
+
+	newEtag := e.PATCH("/url/"+alias).WithJSON(map[string]string{"url": "https://ya.ru"}).
+		WithHeader("If-Match", etag).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		Header("ETag").Raw()
+	assert.NotEqual(t, etag, newEtag)
+
+	redirectTo, err := api.GetRedirect(u.String() + "/" + alias)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", redirectTo)
+
+	e.PUT("/url/"+alias).WithJSON(map[string]string{"url": "https://bing.com"}).
+		WithHeader("If-Match", etag).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusPreconditionFailed).
+		JSON().Object().
+		ContainsValue(response.CodeVersionMismatch)
+}
+
+// TestListURLs проверяет постраничный список ссылок
+// с фильтром по началу алиаса.
+func TestListURLs(t *testing.T) {
+	ctx := context.Background()
+	prefix := random.NewRandomString(8)
+	for _, suffix := range []string{"a", "b", "c"} {
+		_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: "https://google.com", Alias: prefix + suffix})
+		require.NoError(t, err)
+	}
+
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	page := e.GET("/url").
+		WithQuery("alias_prefix", prefix).WithQuery("sort", "alias").WithQuery("limit", 2).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	page.Value("items").Array().Length().IsEqual(2)
+	page.Value("items").Array().Value(0).Object().Value("alias").IsEqual(prefix + "a")
+	cursor := page.Value("next_cursor").String().NotEmpty().Raw()
+
+	page = e.GET("/url").
+		WithQuery("alias_prefix", prefix).WithQuery("sort", "alias").WithQuery("limit", 2).WithQuery("cursor", cursor).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	page.Value("items").Array().Length().IsEqual(1)
+	page.Value("items").Array().Value(0).Object().Value("alias").IsEqual(prefix + "c")
+	page.NotContainsKey("next_cursor")
+}
+
+// TestGetURLInfo проверяет, что информацию о ссылке можно
+// получить без редиректа, а автор и сгенерированный алиас сохраняются.
+func TestGetURLInfo(t *testing.T) {
+	target := gofakeit.URL()
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	alias := e.POST("/url").WithJSON(save.Request{URL: target}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("alias").String().Raw()
+
+	resp := e.GET("/url/"+alias).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK)
+	resp.Header("ETag").IsEqual(`"1"`)
+	info := resp.JSON().Object()
+	info.Value("alias").IsEqual(alias)
+	info.Value("url").IsEqual(target)
+	info.Value("created_by").IsEqual(cfg["username"])
+	info.Value("clicks").IsEqual(0)
+	info.Value("flags").Object().Value("generated").IsEqual(true)
+	info.Value("flags").Object().Value("expired").IsEqual(false)
+
+	e.GET("/url/"+random.NewRandomString(10)).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET("/url/" + alias).
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+// TestSaveURLBatch проверяет, что пачка ссылок сохраняется
+// с отдельным результатом для каждой ссылки.
+func TestSaveURLBatch(t *testing.T) {
+	ctx := context.Background()
+	alias := random.NewRandomString(10)
+	req := batch.Request{Items: []save.Request{
+		{URL: gofakeit.URL(), Alias: alias},
+		{URL: "not a url"},
+		{URL: gofakeit.URL()},
+	}}
+
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	resp := e.POST("/url/batch").WithJSON(req).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	resp.Value("saved").IsEqual(2)
+	resp.Value("failed").IsEqual(1)
+	items := resp.Value("items").Array()
+	items.Value(0).Object().Value("alias").IsEqual(alias)
+	items.Value(1).Object().Value("code").IsEqual(response.CodeValidation)
+
+	savedURL, err := strg.GetURLByAlias(ctx, alias)
+	require.NoError(t, err)
+	assert.Equal(t, req.Items[0].URL, savedURL)
+
+	// Атомарная пачка с занятым алиасом не сохраняется целиком
+	req = batch.Request{Atomic: true, Items: []save.Request{
+		{URL: gofakeit.URL(), Alias: alias},
+		{URL: gofakeit.URL()},
+	}}
+	resp = e.POST("/url/batch").WithJSON(req).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object()
+	resp.Value("code").IsEqual(response.CodeBatchAborted)
+	resp.Value("saved").IsEqual(0)
+	_, err = strg.GetURLIdByURL(ctx, normalizer.Canonical(req.Items[1].URL))
+	assert.ErrorIs(t, err, errStorage.ErrURLNotFound)
+}
+
+// TestLookupAndDeleteByURL проверяет поиск всех алиасов URL
+// и их удаление, сначала в режиме dry_run.
+func TestLookupAndDeleteByURL(t *testing.T) {
+	ctx := context.Background()
+	target := gofakeit.URL() + "?q=" + random.NewRandomString(8)
+	aliases := []string{random.NewRandomString(10), random.NewRandomString(10)}
+	for _, alias := range aliases {
+		_, err := strg.SaveURL(ctx, errStorage.URLToSave{URL: target, Alias: alias, CanonicalURL: normalizer.Canonical(target)})
+		require.NoError(t, err)
+	}
+
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	// Параметры отслеживания не мешают найти ссылки
+	items := e.GET("/url/by-target").WithQuery("url", target+"&utm_source=tg").
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("items").Array()
+	items.Length().IsEqual(2)
+	items.Value(0).Object().Value("alias").IsEqual(aliases[0])
+
+	e.DELETE("/url/by-target").WithQuery("url", target).WithQuery("dry_run", true).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("items").Array().Length().IsEqual(2)
+	_, err := strg.GetURLByAlias(ctx, aliases[0])
+	require.NoError(t, err)
+
+	e.DELETE("/url/by-target").WithQuery("url", target).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("items").Array().Length().IsEqual(2)
+	for _, alias := range aliases {
+		_, err = strg.GetURLByAlias(ctx, alias)
+		assert.ErrorIs(t, err, errStorage.ErrURLNotFound)
+	}
+
+	e.DELETE("/url/by-target").WithQuery("url", target).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET("/url/by-target").WithQuery("url", target).
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+// TestSaveURLDedupe проверяет, что с dedupe для того же URL
+// в другой форме возвращается уже сгенерированный алиас.
+func TestSaveURLDedupe(t *testing.T) {
+	path := random.NewRandomString(10)
+	dedupe := true
+
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	alias := e.POST("/url").WithJSON(save.Request{URL: "https://example.com/" + path}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("alias").String().Raw()
+
+	resp := e.POST("/url").WithJSON(save.Request{URL: "HTTPS://Example.com:443/" + path + "/?utm_source=tg", Dedupe: &dedupe}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	resp.Value("alias").IsEqual(alias)
+	resp.Value("existing").IsEqual(true)
+
+	// Без флага по умолчанию создается новая ссылка
+	e.POST("/url").WithJSON(save.Request{URL: "https://example.com/" + path}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("alias").NotEqual(alias)
+}
+
+// TestSaveURLAliasStrategy проверяет, что ссылки, созданные по разным
+// стратегиям, открываются по выданным алиасам.
+func TestSaveURLAliasStrategy(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+
+	for _, strategy := range []string{random.StrategyRandom, random.StrategyBase62, random.StrategyHashids, random.StrategyWords} {
+		t.Run(strategy, func(t *testing.T) {
+			target := gofakeit.URL() + "?q=" + random.NewRandomString(8)
+			alias := e.POST("/url").WithJSON(save.Request{URL: target, AliasStrategy: strategy}).
+				WithBasicAuth(cfg["username"], cfg["password"]).
+				Expect().
+				Status(http.StatusOK).
+				JSON().Object().Value("alias").String().NotEmpty().Raw()
+
+			redirectTo, err := api.GetRedirect((&url.URL{Scheme: "http", Host: host, Path: alias}).String())
+			require.NoError(t, err)
+			require.Equal(t, target, redirectTo)
+		})
+	}
+}
+
+// TestSaveURLInvalidAlias проверяет, что нельзя занять алиас, который
+// совпадает с путем роутера или нарушает правила.
+func TestSaveURLInvalidAlias(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+
+	for _, alias := range []string{"url", "URL", "qwe/asd", "ab"} {
+		t.Run(alias, func(t *testing.T) {
+			resp := e.POST("/url").WithJSON(save.Request{URL: gofakeit.URL(), Alias: alias}).
+				WithBasicAuth(cfg["username"], cfg["password"]).
+				Expect().
+				Status(http.StatusBadRequest).
+				JSON().Object()
+			resp.Value("code").IsEqual(response.CodeValidation)
+			resp.Value("details").Array().Value(0).Object().Value("field").IsEqual("alias")
+		})
+	}
+}
+
+// TestSuggestAlias проверяет, что для занятого алиаса предлагаются
+// свободные варианты, которые затем можно занять.
+func TestSuggestAlias(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+	alias := "promo" + random.NewRandomString(6)
+
+	resp := e.GET("/url/suggest").WithQuery("alias", alias).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	resp.Value("available").IsEqual(true)
+
+	e.POST("/url").WithJSON(save.Request{URL: gofakeit.URL(), Alias: alias}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK)
+
+	resp = e.GET("/url/suggest").WithQuery("alias", alias).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	resp.Value("available").IsEqual(false)
+	resp.Value("suggestions").Array().Length().IsEqual(3)
+
+	conflict := e.POST("/url").WithJSON(save.Request{URL: gofakeit.URL(), Alias: alias}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusConflict).
+		JSON().Object()
+	conflict.Value("code").IsEqual(response.CodeAliasExists)
+	suggested := conflict.Value("suggestions").Array().Value(0).String().NotEmpty().Raw()
+
+	e.POST("/url").WithJSON(save.Request{URL: gofakeit.URL(), Alias: suggested}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK)
+}
+
+// TestAPIKey проверяет полный цикл ключа API: выпуск админом,
+// работу с правами ключа и отказ после отзыва.
+func TestAPIKey(t *testing.T) {
+	u := url.URL{Scheme: "http", Host: host}
+	e := httpexpect.Default(t, u.String())
+
+	issued := e.POST("/admin/keys").
+		WithJSON(issue.Request{Name: "ci-" + random.NewRandomString(6), Scopes: []string{"links:create", "links:read"}, TTL: 3600}).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	key := issued.Value("key").String().NotEmpty().Raw()
+	id := int(issued.Value("api_key").Object().Value("id").Number().Raw())
+	issued.Value("api_key").Object().Value("created_by").IsEqual(cfg["username"])
+
+	alias := random.NewRandomString(10)
+	e.POST("/url").WithJSON(save.Request{URL: gofakeit.URL(), Alias: alias}).
+		WithHeader("X-API-Key", key).
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/url/{alias}", alias).
+		WithHeader("Authorization", "Bearer "+key).
+		Expect().
+		Status(http.StatusOK)
+
+	e.DELETE("/url/{alias}", alias).
+		WithHeader("X-API-Key", key).
+		Expect().
+		Status(http.StatusForbidden).
+		JSON().Object().Value("code").IsEqual(response.CodeForbidden)
+
+	e.GET("/admin/keys").
+		WithHeader("X-API-Key", key).
+		Expect().
+		Status(http.StatusForbidden)
+
+	listed := e.GET("/admin/keys").
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("items").Array()
+	found := false
+	for _, item := range listed.Iter() {
+		if int(item.Object().Value("id").Number().Raw()) == id {
+			item.Object().Value("last_used_at").NotNull()
+			item.Object().NotContainsKey("hash")
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	e.DELETE("/admin/keys/{id}", id).
+		WithBasicAuth(cfg["username"], cfg["password"]).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("api_key").Object().Value("active").IsEqual(false)
+
+	e.GET("/url/{alias}", alias).
+		WithHeader("X-API-Key", key).
+		Expect().
+		Status(http.StatusUnauthorized)
+}